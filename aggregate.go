@@ -0,0 +1,177 @@
+package bgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// aggregatePercentiles are the percentiles returned for the weights of the edges
+var aggregatePercentiles = []float64{25, 50, 75, 90, 99}
+
+// weightStats summarizes a set of edge weights
+type weightStats struct {
+	Count  int     `json:"count"`
+	Sum    float64 `json:"sum"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P25    float64 `json:"p25"`
+	P50    float64 `json:"p50"`
+	P75    float64 `json:"p75"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+
+	sum *big.Int // exact sum, min and max of integer weights
+	min int64
+	max int64
+}
+
+// MarshalJSON will write the exact sum, min and max in place of the floats
+// when the weights are integers
+func (s weightStats) MarshalJSON() ([]byte, error) {
+	var sum, min, max interface{} = s.Sum, s.Min, s.Max
+	if s.sum != nil {
+		sum, min, max = s.sum, s.min, s.max
+	}
+	return json.Marshal(struct {
+		Count  int         `json:"count"`
+		Sum    interface{} `json:"sum"`
+		Min    interface{} `json:"min"`
+		Max    interface{} `json:"max"`
+		Mean   float64     `json:"mean"`
+		StdDev float64     `json:"stddev"`
+		P25    float64     `json:"p25"`
+		P50    float64     `json:"p50"`
+		P75    float64     `json:"p75"`
+		P90    float64     `json:"p90"`
+		P99    float64     `json:"p99"`
+	}{s.Count, sum, min, max, s.Mean, s.StdDev, s.P25, s.P50, s.P75, s.P90, s.P99})
+}
+
+// percentile returns the p-th percentile of the sorted weights, interpolating
+// linearly between the closest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// aggregateWeights will summarize the weights of the edges, nil is returned
+// when there are no edges
+func aggregateWeights(edges map[string]float64) *weightStats {
+	if len(edges) == 0 {
+		return nil
+	}
+
+	weights := make([]float64, 0, len(edges))
+	stats := &weightStats{Count: len(edges)}
+	for _, weight := range edges {
+		weights = append(weights, weight)
+		stats.Sum += weight
+	}
+	sort.Float64s(weights)
+
+	stats.Min = weights[0]
+	stats.Max = weights[len(weights)-1]
+	stats.Mean = stats.Sum / float64(stats.Count)
+	variance := float64(0)
+	for _, weight := range weights {
+		variance += (weight - stats.Mean) * (weight - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(stats.Count))
+
+	percentiles := []*float64{&stats.P25, &stats.P50, &stats.P75, &stats.P90, &stats.P99}
+	for i, p := range aggregatePercentiles {
+		*percentiles[i] = percentile(weights, p)
+	}
+	return stats
+}
+
+// aggregateExactWeights will summarize the exact weights of the edges of a
+// graph with integer weights, the sum, min and max are kept exact while the
+// other statistics are computed exactly before being rounded to a float
+func aggregateExactWeights(edges map[string]int64) *weightStats {
+	if len(edges) == 0 {
+		return nil
+	}
+
+	weights := make([]int64, 0, len(edges))
+	sum, squares := new(big.Int), new(big.Int)
+	for _, weight := range edges {
+		weights = append(weights, weight)
+		w := big.NewInt(weight)
+		sum.Add(sum, w)
+		squares.Add(squares, w.Mul(w, w))
+	}
+	sort.Slice(weights, func(i, j int) bool { return weights[i] < weights[j] })
+
+	n := big.NewInt(int64(len(weights)))
+	stats := &weightStats{Count: len(weights), sum: sum, min: weights[0], max: weights[len(weights)-1]}
+	stats.Sum, _ = new(big.Float).SetInt(sum).Float64()
+	stats.Min, stats.Max = float64(stats.min), float64(stats.max)
+	stats.Mean, _ = new(big.Rat).SetFrac(sum, n).Float64()
+
+	// the variance is (n * sum of squares - sum^2) / n^2
+	variance := new(big.Int).Mul(n, squares)
+	variance.Sub(variance, new(big.Int).Mul(sum, sum))
+	v, _ := new(big.Rat).SetFrac(variance, n.Mul(n, n)).Float64()
+	stats.StdDev = math.Sqrt(v)
+
+	percentiles := []*float64{&stats.P25, &stats.P50, &stats.P75, &stats.P90, &stats.P99}
+	for i, p := range aggregatePercentiles {
+		rank := p / 100 * float64(len(weights)-1)
+		lower, upper := weights[int(math.Floor(rank))], weights[int(math.Ceil(rank))]
+		value := new(big.Float).SetPrec(256).SetInt(new(big.Int).Sub(big.NewInt(upper), big.NewInt(lower)))
+		value.Mul(value, big.NewFloat(rank-math.Floor(rank)))
+		value.Add(value, new(big.Float).SetInt64(lower))
+		*percentiles[i], _ = value.Float64()
+	}
+	return stats
+}
+
+// AggregateEdges will return the count, sum, min, max, mean, standard
+// deviation and percentiles of the weights of the edges from each vertex
+func (b *BGraphBackend) AggregateEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("eagg takes at least 1 parameter (eagg vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	// graphs with integer weights aggregate their exact weights
+	integer := db.getWeightMode() == weightInteger
+	results := make(map[string]*weightStats)
+	for _, k := range d {
+		key := string(k)
+		var stats *weightStats
+		if integer {
+			edges, err := db.findExactEdges(key)
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
+			stats = aggregateExactWeights(edges)
+		} else {
+			stats = aggregateWeights(db.findEdges(key))
+		}
+		if stats != nil {
+			results[key] = stats
+		}
+	}
+
+	if len(results) > 0 {
+		client.WriteJson(results)
+	} else {
+		client.WriteNull()
+	}
+	client.Flush()
+	return nil
+}
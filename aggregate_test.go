@@ -0,0 +1,44 @@
+package bgraph
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{25, 1.75},
+		{50, 2.5},
+		{100, 4},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile([]float64{7}, 99); got != 7 {
+		t.Errorf("percentile of a single weight = %v, want 7", got)
+	}
+}
+
+func TestAggregateWeights(t *testing.T) {
+	if stats := aggregateWeights(nil); stats != nil {
+		t.Errorf("aggregateWeights(nil) = %v, want nil", stats)
+	}
+
+	stats := aggregateWeights(map[string]float64{"a": 2, "b": 4, "c": 4, "d": 4, "e": 5, "f": 5, "g": 7, "h": 9})
+	if stats.Count != 8 || stats.Sum != 40 || stats.Min != 2 || stats.Max != 9 || stats.Mean != 5 {
+		t.Errorf("aggregates = %+v", stats)
+	}
+	if stats.StdDev != 2 {
+		t.Errorf("stddev = %v, want 2", stats.StdDev)
+	}
+	if stats.P25 != 4 || stats.P50 != 4.5 || stats.P75 != 5.5 || math.Abs(stats.P99-8.86) > 1e-9 {
+		t.Errorf("percentiles = %v %v %v %v", stats.P25, stats.P50, stats.P75, stats.P99)
+	}
+}
@@ -2,32 +2,50 @@ package bgraph
 
 import (
 	"errors"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/nyxtom/broadcast/server"
 )
 
+// sweepInterval is how often the background sweeper reclaims expired entries
+const sweepInterval = time.Second
+
+// compactInterval is how often the background sweeper removes weights at or below epsilon
+const compactInterval = time.Minute
+
 type BGraphBackend struct {
 	server.Backend
+	sync.RWMutex
 
-	app *server.BroadcastServer
-	db  DB
+	app      *server.BroadcastServer
+	config   Config
+	graphs   map[string]DB           // named graphs guarded by the backend lock
+	commands map[string]graphCommand // graph commands that can be run through GRAPH
+	scripts  map[string]*scriptProgram
+	exec     sync.RWMutex   // held exclusively by scripts so that they run atomically
+	negative negativePolicy // negative weight policy of newly created graphs
+	quit     chan struct{}
 }
 
 // SetDEdge will set the directed edge weight for the data passed into it
-func (b *BGraphBackend) SetDEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) SetDEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.setEdge(from, to, weight)
+			if err := db.setEdge(from, to, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -35,19 +53,20 @@ func (b *BGraphBackend) SetDEdge(data interface{}, client server.ProtocolClient)
 	return nil
 }
 
-func (b *BGraphBackend) IncrDEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) IncrDEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.incrEdge(from, to, weight)
+			if err := db.incrEdge(from, to, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -55,19 +74,20 @@ func (b *BGraphBackend) IncrDEdge(data interface{}, client server.ProtocolClient
 	return nil
 }
 
-func (b *BGraphBackend) DecrDEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) DecrDEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.decrEdge(from, to, weight)
+			if err := db.decrEdge(from, to, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -75,20 +95,23 @@ func (b *BGraphBackend) DecrDEdge(data interface{}, client server.ProtocolClient
 	return nil
 }
 
-func (b *BGraphBackend) SetEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) SetEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.setEdge(from, to, weight)
-			b.db.setEdge(to, from, weight)
+			if err := db.setEdge(from, to, weight); err != nil {
+				return err
+			}
+			if err := db.setEdge(to, from, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -96,20 +119,23 @@ func (b *BGraphBackend) SetEdge(data interface{}, client server.ProtocolClient)
 	return nil
 }
 
-func (b *BGraphBackend) IncrEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) IncrEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.incrEdge(from, to, weight)
-			b.db.incrEdge(to, from, weight)
+			if err := db.incrEdge(from, to, weight); err != nil {
+				return err
+			}
+			if err := db.incrEdge(to, from, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -117,20 +143,23 @@ func (b *BGraphBackend) IncrEdge(data interface{}, client server.ProtocolClient)
 	return nil
 }
 
-func (b *BGraphBackend) DecrEdge(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) DecrEdge(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 3 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var from string
 		var to string
 		for i < (len(d) - 2) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			from = string(d[i+1])
 			to = string(d[i+2])
 
-			b.db.decrEdge(from, to, weight)
-			b.db.decrEdge(to, from, weight)
+			if err := db.decrEdge(from, to, weight); err != nil {
+				return err
+			}
+			if err := db.decrEdge(to, from, weight); err != nil {
+				return err
+			}
 			i += 3
 		}
 	}
@@ -138,17 +167,18 @@ func (b *BGraphBackend) DecrEdge(data interface{}, client server.ProtocolClient)
 	return nil
 }
 
-func (b *BGraphBackend) SetVertex(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) SetVertex(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 2 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var vertex string
 		for i < (len(d) - 1) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			vertex = string(d[i+1])
 
-			b.db.setVertex(vertex, weight)
+			if err := db.setVertex(vertex, weight); err != nil {
+				return err
+			}
 			i += 2
 		}
 	}
@@ -156,17 +186,18 @@ func (b *BGraphBackend) SetVertex(data interface{}, client server.ProtocolClient
 	return nil
 }
 
-func (b *BGraphBackend) IncrVertex(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) IncrVertex(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 2 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var vertex string
 		for i < (len(d) - 1) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			vertex = string(d[i+1])
 
-			b.db.incrVertex(vertex, weight)
+			if err := db.incrVertex(vertex, weight); err != nil {
+				return err
+			}
 			i += 2
 		}
 	}
@@ -174,17 +205,18 @@ func (b *BGraphBackend) IncrVertex(data interface{}, client server.ProtocolClien
 	return nil
 }
 
-func (b *BGraphBackend) DecrVertex(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) DecrVertex(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) >= 2 {
 		i := 0
-		var weight float64
+		var weight weightArg
 		var vertex string
 		for i < (len(d) - 1) {
-			weight, _ = strconv.ParseFloat(string(d[i]), 64)
+			weight, _ = parseWeight(string(d[i]))
 			vertex = string(d[i+1])
 
-			b.db.decrVertex(vertex, weight)
+			if err := db.decrVertex(vertex, weight); err != nil {
+				return err
+			}
 			i += 2
 		}
 	}
@@ -192,18 +224,31 @@ func (b *BGraphBackend) DecrVertex(data interface{}, client server.ProtocolClien
 	return nil
 }
 
-func (b *BGraphBackend) FindEdges(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) FindEdges(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) < 1 {
 		client.WriteError(errors.New("*e takes at least 1 parameter (*e vertex [vertex ...])"))
 		client.Flush()
 		return nil
 	}
 
-	vertexEdges := make(map[string]map[string]float64)
+	// graphs with integer weights reply with their exact weights
+	integer := db.getWeightMode() == weightInteger
+	vertexEdges := make(map[string]interface{})
 	for _, k := range d {
 		key := string(k)
-		edges := b.db.findEdges(key)
+		if integer {
+			edges, err := db.findExactEdges(key)
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
+			if edges != nil {
+				vertexEdges[key] = edges
+			}
+			continue
+		}
+		edges := db.findEdges(key)
 		if edges != nil {
 			vertexEdges[key] = edges
 		}
@@ -219,8 +264,7 @@ func (b *BGraphBackend) FindEdges(data interface{}, client server.ProtocolClient
 	return nil
 }
 
-func (b *BGraphBackend) IntersectEdges(data interface{}, client server.ProtocolClient) error {
-	d, _ := data.([][]byte)
+func (b *BGraphBackend) IntersectEdges(db DB, d [][]byte, client server.ProtocolClient) error {
 	if len(d) < 2 {
 		client.WriteError(errors.New("&e takes at least 2 parameters (&e vertex [vertex ...])"))
 		client.Flush()
@@ -232,7 +276,20 @@ func (b *BGraphBackend) IntersectEdges(data interface{}, client server.ProtocolC
 		keys[i] = string(k)
 	}
 
-	results := b.db.sumIntersectEdges(keys)
+	if db.getWeightMode() == weightInteger {
+		results, err := db.sumIntersectExactEdges(keys)
+		if err != nil {
+			client.WriteError(err)
+		} else if len(results) > 0 {
+			client.WriteJson(results)
+		} else {
+			client.WriteNull()
+		}
+		client.Flush()
+		return nil
+	}
+
+	results := db.sumIntersectEdges(keys)
 	if results != nil && len(results) > 0 {
 		client.WriteJson(results)
 		client.Flush()
@@ -244,31 +301,908 @@ func (b *BGraphBackend) IntersectEdges(data interface{}, client server.ProtocolC
 	return nil
 }
 
+// parseCoreMode will determine whether core numbers use weighted degrees
+func parseCoreMode(mode string) (bool, error) {
+	switch strings.ToLower(mode) {
+	case "degree":
+		return false, nil
+	case "weight":
+		return true, nil
+	}
+	return false, errors.New("invalid core mode " + mode + " (expected degree or weight)")
+}
+
+// CoreNumbers will return the k-core number of each of the specified vertices
+func (b *BGraphBackend) CoreNumbers(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("core takes at least 2 parameters (core degree|weight vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	weighted, err := parseCoreMode(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	cores := db.coreNumbers(weighted)
+	results := make(map[string]float64)
+	for _, k := range d[1:] {
+		key := string(k)
+		if core, ok := cores[key]; ok {
+			results[key] = core
+		}
+	}
+
+	if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// KCore will return the members of the k-core along with their core numbers
+func (b *BGraphBackend) KCore(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) != 2 {
+		client.WriteError(errors.New("kcore takes 2 parameters (kcore degree|weight k)"))
+		client.Flush()
+		return nil
+	}
+
+	weighted, err := parseCoreMode(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	k, err := strconv.ParseFloat(string(d[1]), 64)
+	if err != nil {
+		client.WriteError(errors.New("kcore k must be a number"))
+		client.Flush()
+		return nil
+	}
+
+	results := make(map[string]float64)
+	for vertex, core := range db.coreNumbers(weighted) {
+		if core >= k {
+			results[vertex] = core
+		}
+	}
+
+	if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// FindCycle will return an example cycle in the directed graph reachable from the vertices
+func (b *BGraphBackend) FindCycle(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("cycle takes at least 1 parameter (cycle vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	keys := make([]string, len(d))
+	for i, k := range d {
+		keys[i] = string(k)
+	}
+
+	cycle := db.findCycle(keys)
+	if cycle != nil {
+		client.WriteJson(cycle)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// TopologicalSort will return the topological ordering of the directed graph reachable from the vertices
+func (b *BGraphBackend) TopologicalSort(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("topo takes at least 1 parameter (topo vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	keys := make([]string, len(d))
+	for i, k := range d {
+		keys[i] = string(k)
+	}
+
+	order, cycle := db.topologicalSort(keys)
+	if cycle != nil {
+		client.WriteError(errors.New("graph contains a cycle (" + strings.Join(cycle, " -> ") + ")"))
+		client.Flush()
+	} else if len(order) > 0 {
+		client.WriteJson(order)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// SpanningForest will return the minimum or maximum spanning forest over the symmetric edges
+func (b *BGraphBackend) SpanningForest(db DB, d [][]byte, client server.ProtocolClient) error {
+	maximum := false
+	if len(d) > 0 {
+		switch strings.ToLower(string(d[0])) {
+		case "min":
+		case "max":
+			maximum = true
+		default:
+			client.WriteError(errors.New("invalid spanning forest mode " + string(d[0]) + " (expected min or max)"))
+			client.Flush()
+			return nil
+		}
+	}
+
+	forest := db.spanningForest(maximum)
+	if len(forest.Edges) > 0 {
+		client.WriteJson(forest)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// MaxFlow will return the maximum flow and the minimum cut edges between a source and sink
+func (b *BGraphBackend) MaxFlow(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) != 2 {
+		client.WriteError(errors.New("maxflow takes 2 parameters (maxflow source sink)"))
+		client.Flush()
+		return nil
+	}
+
+	source, sink := string(d[0]), string(d[1])
+	if source == sink {
+		client.WriteError(errors.New("maxflow source and sink must be different vertices"))
+		client.Flush()
+		return nil
+	}
+
+	result, err := db.maxFlow(source, sink)
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+	} else if result != nil {
+		client.WriteJson(result)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// InducedSubgraph will return the vertices, vertex weights and edges induced by the specified vertices
+func (b *BGraphBackend) InducedSubgraph(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("subgraph takes at least 1 parameter (subgraph vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	keys := make([]string, len(d))
+	for i, k := range d {
+		keys[i] = string(k)
+	}
+
+	result := db.inducedSubgraph(keys)
+	if result != nil {
+		client.WriteJson(result)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// EgoSubgraph will return the subgraph of the vertices reachable from a vertex within a radius
+func (b *BGraphBackend) EgoSubgraph(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) != 2 {
+		client.WriteError(errors.New("ego takes 2 parameters (ego radius vertex)"))
+		client.Flush()
+		return nil
+	}
+
+	radius, err := strconv.Atoi(string(d[0]))
+	if err != nil || radius < 0 {
+		client.WriteError(errors.New("ego radius must be a non-negative integer"))
+		client.Flush()
+		return nil
+	}
+
+	result := db.egoSubgraph(string(d[1]), radius)
+	if result != nil {
+		client.WriteJson(result)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// parseTTL will convert a number of seconds into a duration
+func parseTTL(value []byte) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return 0, errors.New("invalid number of seconds " + string(value))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// ExpireVertex will set the time to live in seconds of the given vertices
+func (b *BGraphBackend) ExpireVertex(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 2 {
+		i := 0
+		for i < (len(d) - 1) {
+			ttl, err := parseTTL(d[i])
+			if err != nil {
+				return err
+			}
+			db.expireVertex(string(d[i+1]), ttl)
+			i += 2
+		}
+	}
+
+	return nil
+}
+
+// ExpireEdge will set the time to live in seconds of the given directed edges
+func (b *BGraphBackend) ExpireEdge(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 3 {
+		i := 0
+		for i < (len(d) - 2) {
+			ttl, err := parseTTL(d[i])
+			if err != nil {
+				return err
+			}
+			db.expireEdge(string(d[i+1]), string(d[i+2]), ttl)
+			i += 3
+		}
+	}
+
+	return nil
+}
+
+// VertexTTL will return the seconds left before each of the vertices expire
+func (b *BGraphBackend) VertexTTL(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("ttl takes at least 1 parameter (ttl vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	results := make(map[string]float64)
+	for _, k := range d {
+		key := string(k)
+		if ttl, ok := db.vertexTTL(key); ok {
+			results[key] = ttl
+		}
+	}
+
+	if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// EdgeTTL will return the seconds left before each of the directed edges expire
+func (b *BGraphBackend) EdgeTTL(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("ttl> takes at least 2 parameters (ttl> from to [from to ...])"))
+		client.Flush()
+		return nil
+	}
+
+	results := make(map[string]map[string]float64)
+	i := 0
+	for i < (len(d) - 1) {
+		from, to := string(d[i]), string(d[i+1])
+		if ttl, ok := db.edgeTTL(from, to); ok {
+			if _, ok := results[from]; !ok {
+				results[from] = make(map[string]float64)
+			}
+			results[from][to] = ttl
+		}
+		i += 2
+	}
+
+	if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// HalfLife will set or return the decay half-life in seconds of the graph weights
+func (b *BGraphBackend) HalfLife(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		client.WriteJson(db.getHalfLife().Seconds())
+		client.Flush()
+		return nil
+	}
+
+	halfLife, err := parseTTL(d[0])
+	if err != nil || len(d) > 1 {
+		client.WriteError(errors.New("decay takes at most 1 numeric parameter (decay [seconds])"))
+		client.Flush()
+		return nil
+	}
+
+	if halfLife > 0 && db.getWeightMode() == weightInteger {
+		client.WriteError(errors.New("decay requires float weights as decayed weights are fractions"))
+		client.Flush()
+		return nil
+	}
+
+	db.setHalfLife(halfLife)
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// parseTimeSpec will parse either a single unix time in seconds or a window
+// of two unix times separated by a colon (from:to)
+func parseTimeSpec(value string) (timeSpec, error) {
+	parts := strings.SplitN(value, ":", 2)
+	times := make([]int64, len(parts))
+	for i, p := range parts {
+		seconds, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return timeSpec{}, errors.New("invalid time " + value + " (expected unix seconds or from:to)")
+		}
+		times[i] = int64(seconds * float64(time.Second))
+	}
+
+	if len(times) == 1 {
+		return timeSpec{from: times[0], to: times[0]}, nil
+	}
+	if times[1] < times[0] {
+		return timeSpec{}, errors.New("invalid time window " + value + " (from must not be after to)")
+	}
+	return timeSpec{times[0], times[1], true}, nil
+}
+
+// FindEdgesAt will return the edges from the vertices as of a time or their change within a window
+func (b *BGraphBackend) FindEdgesAt(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("*e@ takes at least 2 parameters (*e@ time|from:to vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	spec, err := parseTimeSpec(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	vertexEdges := make(map[string]map[string]float64)
+	for _, k := range d[1:] {
+		key := string(k)
+		edges, err := db.findEdgesAt(key, spec)
+		if err != nil {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+		if edges != nil {
+			vertexEdges[key] = edges
+		}
+	}
+
+	if len(vertexEdges) > 0 {
+		client.WriteJson(vertexEdges)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// IntersectEdgesAt will return the intersection of the edges between the vertices as of a time or within a window
+func (b *BGraphBackend) IntersectEdgesAt(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 3 {
+		client.WriteError(errors.New("&e@ takes at least 3 parameters (&e@ time|from:to vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	spec, err := parseTimeSpec(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	keys := make([]string, len(d)-1)
+	for i, k := range d[1:] {
+		keys[i] = string(k)
+	}
+
+	results, err := db.sumIntersectEdgesAt(keys, spec)
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+	} else if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// History will set or return the number of versions and the seconds of edge history retained
+func (b *BGraphBackend) History(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		limit, age := db.getHistory()
+		client.WriteJson(map[string]float64{"versions": float64(limit), "seconds": age.Seconds()})
+		client.Flush()
+		return nil
+	}
+
+	limit, err := strconv.Atoi(string(d[0]))
+	if err != nil || len(d) > 2 {
+		client.WriteError(errors.New("history takes at most 2 parameters (history [versions [seconds]])"))
+		client.Flush()
+		return nil
+	}
+
+	var age time.Duration
+	if len(d) == 2 {
+		if age, err = parseTTL(d[1]); err != nil {
+			client.WriteError(errors.New("history takes at most 2 parameters (history [versions [seconds]])"))
+			client.Flush()
+			return nil
+		}
+	}
+
+	db.setHistory(limit, age)
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// IncrWindowDEdge will increment the sliding window counter of the directed edges
+func (b *BGraphBackend) IncrWindowDEdge(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 3 {
+		i := 0
+		var weight weightArg
+		var from string
+		var to string
+		for i < (len(d) - 2) {
+			weight, _ = parseWeight(string(d[i]))
+			from = string(d[i+1])
+			to = string(d[i+2])
+
+			if err := db.incrWindowEdge(from, to, weight); err != nil {
+				return err
+			}
+			i += 3
+		}
+	}
+
+	return nil
+}
+
+// IncrWindowEdge will increment the sliding window counter of the symmetric edges
+func (b *BGraphBackend) IncrWindowEdge(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 3 {
+		i := 0
+		var weight weightArg
+		var from string
+		var to string
+		for i < (len(d) - 2) {
+			weight, _ = parseWeight(string(d[i]))
+			from = string(d[i+1])
+			to = string(d[i+2])
+
+			if err := db.incrWindowEdge(from, to, weight); err != nil {
+				return err
+			}
+			if err := db.incrWindowEdge(to, from, weight); err != nil {
+				return err
+			}
+			i += 3
+		}
+	}
+
+	return nil
+}
+
+// Window will set or return the span in seconds and number of buckets of the sliding window counters
+func (b *BGraphBackend) Window(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		span, buckets := db.getWindow()
+		client.WriteJson(map[string]float64{"seconds": span.Seconds(), "buckets": float64(buckets)})
+		client.Flush()
+		return nil
+	}
+
+	if len(d) != 2 {
+		client.WriteError(errors.New("window takes 0 or 2 parameters (window [seconds buckets])"))
+		client.Flush()
+		return nil
+	}
+
+	span, err := parseTTL(d[0])
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+	buckets, err := strconv.Atoi(string(d[1]))
+	if err != nil {
+		client.WriteError(errors.New("invalid number of buckets " + string(d[1])))
+		client.Flush()
+		return nil
+	}
+
+	if err := db.setWindow(span, buckets); err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// FlushGraph will remove every vertex and edge from the graph
+func (b *BGraphBackend) FlushGraph(db DB, d [][]byte, client server.ProtocolClient) error {
+	db.flushGraph()
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// DropEdges will remove every edge from the graph while keeping its vertices
+func (b *BGraphBackend) DropEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	db.flushEdges()
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// DropWeights will remove the weight of every vertex in the graph
+func (b *BGraphBackend) DropWeights(db DB, d [][]byte, client server.ProtocolClient) error {
+	db.flushVertexWeights()
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// SetVertexLabel will set the label (type) of the given vertices, an empty label removes it
+func (b *BGraphBackend) SetVertexLabel(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 2 {
+		label := string(d[0])
+		for _, k := range d[1:] {
+			db.setVertexLabel(label, string(k))
+		}
+	}
+
+	return nil
+}
+
+// SetVertexProperties will set the string or numeric properties of a vertex
+func (b *BGraphBackend) SetVertexProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 3 {
+		props := make(properties)
+		i := 1
+		for i < (len(d) - 1) {
+			props[string(d[i])] = parsePropertyValue(string(d[i+1]))
+			i += 2
+		}
+		db.setVertexProperties(string(d[0]), props)
+	}
+
+	return nil
+}
+
+// DelVertexProperties will remove the given properties of a vertex
+func (b *BGraphBackend) DelVertexProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 2 {
+		keys := make([]string, len(d)-1)
+		for i, k := range d[1:] {
+			keys[i] = string(k)
+		}
+		db.delVertexProperties(string(d[0]), keys)
+	}
+
+	return nil
+}
+
+// GetVertexProperties will return the label and the properties of a vertex, or only the given properties
+func (b *BGraphBackend) GetVertexProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("vget takes at least 1 parameter (vget vertex [key ...])"))
+		client.Flush()
+		return nil
+	}
+
+	label, props, ok := db.getVertexProperties(string(d[0]))
+	if !ok {
+		client.WriteNull()
+		client.Flush()
+		return nil
+	}
+
+	if len(d) > 1 {
+		selected := make(properties)
+		for _, k := range d[1:] {
+			if value, ok := props[string(k)]; ok {
+				selected[string(k)] = value
+			}
+		}
+		props = selected
+	}
+
+	client.WriteJson(map[string]interface{}{"label": label, "properties": props})
+	client.Flush()
+	return nil
+}
+
+// SetEdgeProperties will set string or numeric properties of the directed edge
+func (b *BGraphBackend) SetEdgeProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 4 {
+		props := make(properties)
+		i := 2
+		for i < (len(d) - 1) {
+			props[string(d[i])] = parsePropertyValue(string(d[i+1]))
+			i += 2
+		}
+		db.setEdgeProperties(string(d[0]), string(d[1]), props)
+	}
+
+	return nil
+}
+
+// DelEdgeProperties will remove the given properties of the directed edge
+func (b *BGraphBackend) DelEdgeProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) >= 3 {
+		keys := make([]string, len(d)-2)
+		for i, k := range d[2:] {
+			keys[i] = string(k)
+		}
+		db.delEdgeProperties(string(d[0]), string(d[1]), keys)
+	}
+
+	return nil
+}
+
+// GetEdgeProperties will return the weight and the properties of the directed edge, or only the given properties
+func (b *BGraphBackend) GetEdgeProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("eget takes at least 2 parameters (eget from to [key ...])"))
+		client.Flush()
+		return nil
+	}
+
+	detail, ok := db.getEdgeProperties(string(d[0]), string(d[1]))
+	if !ok {
+		client.WriteNull()
+		client.Flush()
+		return nil
+	}
+
+	if len(d) > 2 {
+		selected := make(properties)
+		for _, k := range d[2:] {
+			if value, ok := detail.Properties[string(k)]; ok {
+				selected[string(k)] = value
+			}
+		}
+		detail.Properties = selected
+	}
+
+	client.WriteJson(detail)
+	client.Flush()
+	return nil
+}
+
+// FindEdgesWithProperties will return the edges from the vertices along with their weights and properties
+func (b *BGraphBackend) FindEdgesWithProperties(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("*ep takes at least 1 parameter (*ep vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	vertexEdges := make(map[string]map[string]edgeDetail)
+	for _, k := range d {
+		key := string(k)
+		edges, err := db.findEdgesWithProperties(key)
+		if err != nil {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+		if edges != nil {
+			vertexEdges[key] = edges
+		}
+	}
+
+	if len(vertexEdges) > 0 {
+		client.WriteJson(vertexEdges)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
+
+// sweep will periodically remove expired vertices and edges, and compact the
+// graphs that prune weights, until quit is closed
+func (b *BGraphBackend) sweep(quit chan struct{}) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	compactTicker := time.NewTicker(compactInterval)
+	defer compactTicker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.eachGraph(func(name string, db DB) {
+				defer b.lockCommand("sweep")()
+				db.sweepExpired()
+			})
+		case <-compactTicker.C:
+			b.eachGraph(func(name string, db DB) {
+				defer b.lockCommand("compact")()
+				db.compactWeights()
+			})
+		case <-quit:
+			return
+		}
+	}
+}
+
 func RegisterBackend(app *server.BroadcastServer) (server.Backend, error) {
+	return RegisterBackendConfig(app, DefaultConfig())
+}
+
+func RegisterBackendConfig(app *server.BroadcastServer, config Config) (server.Backend, error) {
 	backend := new(BGraphBackend)
-	db, _ := NewMemoryGraphDb()
-	backend.db = db
-
-	app.RegisterCommand(server.Command{"=>", "Sets the directed edge weight", "=> weight from to [from to ...]", true}, backend.SetDEdge)
-	app.RegisterCommand(server.Command{"+>", "Increments the directed edge weight", "+> weight from to [from to ...]", true}, backend.IncrDEdge)
-	app.RegisterCommand(server.Command{"->", "Decrements the directed edge weight", "-> weight from to [from to ...]", true}, backend.DecrDEdge)
-	app.RegisterCommand(server.Command{"<=>", "Sets the symmetric edge weight", "<=> weight from to [from to ...]", true}, backend.SetEdge)
-	app.RegisterCommand(server.Command{"<+>", "Increments the symmetric edge weight", "<+> weight from to [from to ...]", true}, backend.IncrEdge)
-	app.RegisterCommand(server.Command{"<->", "Decrements the symmetric edge weight", "<-> weight from to [from to ...]", true}, backend.DecrEdge)
-	app.RegisterCommand(server.Command{"=", "Sets a given vertex's own weight", "= weight vertex [weight vertex ...]", true}, backend.SetVertex)
-	app.RegisterCommand(server.Command{"+", "Increments a given vertex's own weight", "+ weight vertex [weight vertex ...]", true}, backend.IncrVertex)
-	app.RegisterCommand(server.Command{"-", "Decrements a given vertex's own weight", "- weight vertex [weight vertex ...]", true}, backend.DecrVertex)
-	app.RegisterCommand(server.Command{"*e", "Returns a list of all edges from the specified vertices", "*e vertex [vertex ...]", false}, backend.FindEdges)
-	app.RegisterCommand(server.Command{"&e", "Returns the intersection of all edges between the set of vertices with the sum of the weights", "&e vertex [vertex ...]", false}, backend.IntersectEdges)
 	backend.app = app
+	backend.config = config
+	backend.negative = negativeAllow
+	if config.Negative != "" {
+		policy, err := parseNegativePolicy(config.Negative)
+		if err != nil {
+			return nil, err
+		}
+		backend.negative = policy
+	}
+	backend.graphs = make(map[string]DB)
+	backend.commands = make(map[string]graphCommand)
+	backend.scripts = make(map[string]*scriptProgram)
+	backend.graph(defaultGraph)
+
+	backend.register(server.Command{"=>", "Sets the directed edge weight", "=> weight from to [from to ...]", true}, backend.SetDEdge)
+	backend.register(server.Command{"+>", "Increments the directed edge weight", "+> weight from to [from to ...]", true}, backend.IncrDEdge)
+	backend.register(server.Command{"->", "Decrements the directed edge weight", "-> weight from to [from to ...]", true}, backend.DecrDEdge)
+	backend.register(server.Command{"<=>", "Sets the symmetric edge weight", "<=> weight from to [from to ...]", true}, backend.SetEdge)
+	backend.register(server.Command{"<+>", "Increments the symmetric edge weight", "<+> weight from to [from to ...]", true}, backend.IncrEdge)
+	backend.register(server.Command{"<->", "Decrements the symmetric edge weight", "<-> weight from to [from to ...]", true}, backend.DecrEdge)
+	backend.register(server.Command{"=", "Sets a given vertex's own weight", "= weight vertex [weight vertex ...]", true}, backend.SetVertex)
+	backend.register(server.Command{"+", "Increments a given vertex's own weight", "+ weight vertex [weight vertex ...]", true}, backend.IncrVertex)
+	backend.register(server.Command{"-", "Decrements a given vertex's own weight", "- weight vertex [weight vertex ...]", true}, backend.DecrVertex)
+	backend.register(server.Command{"*e", "Returns a list of all edges from the specified vertices", "*e vertex [vertex ...]", false}, backend.FindEdges)
+	backend.register(server.Command{"&e", "Returns the intersection of all edges between the set of vertices with the sum of the weights", "&e vertex [vertex ...]", false}, backend.IntersectEdges)
+	backend.register(server.Command{"core", "Returns the k-core number of the specified vertices using unweighted or weighted degree", "core degree|weight vertex [vertex ...]", false}, backend.CoreNumbers)
+	backend.register(server.Command{"kcore", "Returns the vertices and core numbers belonging to the k-core", "kcore degree|weight k", false}, backend.KCore)
+	backend.register(server.Command{"cycle", "Returns an example cycle in the directed graph reachable from the vertices", "cycle vertex [vertex ...]", false}, backend.FindCycle)
+	backend.register(server.Command{"topo", "Returns the topological ordering of the directed graph reachable from the vertices", "topo vertex [vertex ...]", false}, backend.TopologicalSort)
+	backend.register(server.Command{"mst", "Returns the minimum or maximum spanning forest over the symmetric edges with its total weight", "mst [min|max]", false}, backend.SpanningForest)
+	backend.register(server.Command{"maxflow", "Returns the maximum flow and minimum cut edges between two vertices using edge weights as capacities", "maxflow source sink", false}, backend.MaxFlow)
+	backend.register(server.Command{"subgraph", "Returns the vertices, vertex weights and edges of the subgraph induced by the vertices", "subgraph vertex [vertex ...]", false}, backend.InducedSubgraph)
+	backend.register(server.Command{"ego", "Returns the subgraph induced by the vertices within radius outgoing edges of the vertex", "ego radius vertex", false}, backend.EgoSubgraph)
+	backend.register(server.Command{"expire", "Sets the time to live in seconds of a vertex and its edges (0 removes the expiration)", "expire seconds vertex [seconds vertex ...]", true}, backend.ExpireVertex)
+	backend.register(server.Command{"expire>", "Sets the time to live in seconds of the directed edge (0 removes the expiration)", "expire> seconds from to [seconds from to ...]", true}, backend.ExpireEdge)
+	backend.register(server.Command{"ttl", "Returns the seconds left before the vertices expire (-1 if they never expire)", "ttl vertex [vertex ...]", false}, backend.VertexTTL)
+	backend.register(server.Command{"ttl>", "Returns the seconds left before the directed edges expire (-1 if they never expire)", "ttl> from to [from to ...]", false}, backend.EdgeTTL)
+	backend.register(server.Command{"decay", "Sets or returns the half-life in seconds used to exponentially decay all weights (0 disables decay)", "decay [seconds]", false}, backend.HalfLife)
+	backend.register(server.Command{"history", "Sets or returns the number of weight versions and seconds of history retained per edge (0 versions disables history)", "history [versions [seconds]]", false}, backend.History)
+	backend.register(server.Command{"*e@", "Returns the edges from the vertices as of a unix time, or their change in weight within a time window", "*e@ time|from:to vertex [vertex ...]", false}, backend.FindEdgesAt)
+	backend.register(server.Command{"&e@", "Returns the intersection of all edges between the vertices with the sum of the weights as of a unix time or within a time window", "&e@ time|from:to vertex [vertex ...]", false}, backend.IntersectEdgesAt)
+	backend.register(server.Command{"~>", "Increments the directed edge weight within the sliding window", "~> weight from to [weight from to ...]", true}, backend.IncrWindowDEdge)
+	backend.register(server.Command{"<~>", "Increments the symmetric edge weight within the sliding window", "<~> weight from to [weight from to ...]", true}, backend.IncrWindowEdge)
+	backend.register(server.Command{"window", "Sets or returns the span in seconds and number of buckets (at most 3600) of the sliding window edge counters", "window [seconds buckets]", false}, backend.Window)
+	backend.register(server.Command{"stats", "Returns the number of vertices and edges along with the settings of the graph", "", false}, backend.Stats)
+	backend.register(server.Command{"flushgraph", "Removes every vertex and edge from the graph, memory is reclaimed in the background", "", false}, backend.FlushGraph)
+	backend.register(server.Command{"dropedges", "Removes every edge from the graph while keeping the vertices and their weights", "", false}, backend.DropEdges)
+	backend.register(server.Command{"dropweights", "Removes the weight of every vertex while keeping the vertices and edges", "", false}, backend.DropWeights)
+	backend.register(server.Command{"label", "Runs an edge command against the edges with a label, a list of labels or * to aggregate across labels", "label name[,name ...]|* command [arg ...]", false}, backend.Label)
+	backend.register(server.Command{"labels", "Returns the weight of the edge between two vertices for each of its labels", "labels from to", false}, backend.EdgeLabels)
+	backend.register(server.Command{"vlabel", "Sets the label (type) of the vertices, an empty label removes it", "vlabel label vertex [vertex ...]", true}, backend.SetVertexLabel)
+	backend.register(server.Command{"vset", "Sets string or numeric properties of a vertex", "vset vertex key value [key value ...]", true}, backend.SetVertexProperties)
+	backend.register(server.Command{"vdel", "Removes properties of a vertex", "vdel vertex key [key ...]", true}, backend.DelVertexProperties)
+	backend.register(server.Command{"vget", "Returns the label and properties of a vertex, or only the given properties", "vget vertex [key ...]", false}, backend.GetVertexProperties)
+	backend.register(server.Command{"eset", "Sets string or numeric properties of the directed edge, creating it with a weight of zero if needed", "eset from to key value [key value ...]", true}, backend.SetEdgeProperties)
+	backend.register(server.Command{"edel", "Removes properties of the directed edge", "edel from to key [key ...]", true}, backend.DelEdgeProperties)
+	backend.register(server.Command{"eget", "Returns the weight and properties of the directed edge, or only the given properties", "eget from to [key ...]", false}, backend.GetEdgeProperties)
+	backend.register(server.Command{"*ep", "Returns a list of all edges from the specified vertices with their weights and properties", "*ep vertex [vertex ...]", false}, backend.FindEdgesWithProperties)
+	backend.register(server.Command{"eagg", "Returns the count, sum, min, max, mean, stddev and percentiles of the weights of the edges from each vertex", "eagg vertex [vertex ...]", false}, backend.AggregateEdges)
+	backend.register(server.Command{"*en", "Returns the edges from the vertices with each weight divided by the total weight of the edges of the vertex (transition probabilities)", "*en vertex [vertex ...]", false}, backend.TransitionEdges)
+	backend.register(server.Command{"normalize", "Rewrites the weights of the edges from the vertices so that they sum to one, failing on negative weights", "normalize vertex [vertex ...]", true}, backend.NormalizeEdges)
+	backend.register(server.Command{"scale", "Multiplies the weight of every edge, or of the edges from the vertices, by a factor", "scale factor [vertex ...]", false}, backend.ScaleEdges)
+	backend.register(server.Command{"clamp", "Limits the weight of every edge, or of the edges from the vertices, to the range min to max", "clamp min max [vertex ...]", false}, backend.ClampEdges)
+	backend.register(server.Command{"prune", "Removes every edge, or the edges from the vertices, whose weight is below the threshold", "prune threshold [vertex ...]", false}, backend.PruneEdges)
+	backend.register(server.Command{"negative", "Sets or returns the negative weight policy of the graph, or runs a weight write with the policy instead", "negative [allow|clamp|reject|delete [command arg ...]]", false}, backend.NegativePolicy)
+	backend.register(server.Command{"epsilon", "Sets or returns the magnitude at or below which edge and vertex weights are removed, off disables the removal", "epsilon [value|off]", false}, backend.Epsilon)
+	backend.register(server.Command{"compact", "Removes the edges and vertex weights at or below epsilon", "", false}, backend.Compact)
+	backend.register(server.Command{"weights", "Sets or returns whether the weights of the graph are floats or exact integers that fail to write rather than overflow", "weights [float|integer]", false}, backend.WeightMode)
+	backend.register(server.Command{"vprefix", "Returns a page of the vertex names starting with the prefix in lexicographic order", "vprefix prefix [offset [count]]", false}, backend.PrefixVertices)
+	backend.register(server.Command{"vbetween", "Returns a page of the vertex names between min and max inclusive in lexicographic order, - and + leave the range unbounded", "vbetween min|- max|+ [offset [count]]", false}, backend.RangeVertices)
+	backend.register(server.Command{"vindex", "Declares a hash (equality) or sorted (range) index over a vertex property or the vertex label, or returns the declared indexes", "vindex [key [hash|sorted]]", false}, backend.Index)
+	backend.register(server.Command{"vunindex", "Removes the indexes over the vertex properties", "vunindex key [key ...]", false}, backend.DropIndex)
+	backend.register(server.Command{"vfind", "Returns a page of the vertices whose indexed property equals the value", "vfind key value [offset [count]]", false}, backend.FindIndexed)
+	backend.register(server.Command{"vrange", "Returns a page of the vertices whose property lies within min and max inclusive using a sorted index", "vrange key min max [offset [count]]", false}, backend.RangeIndexed)
+	backend.register(server.Command{"query", "Matches a path pattern such as (a)-[w>5]->(b)<--(c) filtering on weights and properties, with aggregates, ordering and a limit, EXPLAIN returns the plan", "query [explain] match pattern [where cond [and cond ...]] return item [, item ...] [order by item [asc|desc]] [limit n]", false}, backend.Query)
+	backend.register(server.Command{"eval", "Runs a script atomically against the graph, the script is cached by its sha1", "eval script [arg ...]", false}, backend.Eval)
+	backend.register(server.Command{"evalsha", "Runs a cached script atomically against the graph", "evalsha sha [arg ...]", false}, backend.EvalSha)
+	backend.register(server.Command{"where", "Runs a neighbor or traversal query returning only the vertices matching the label and property predicates", "where predicate[,predicate ...] command [arg ...]", false}, backend.Where)
+	backend.register(server.Command{"like", "Runs a neighbor or traversal query returning only the vertices whose names match any of the glob patterns, where * and ? also match /", "like pattern[,pattern ...] command [arg ...]", false}, backend.Like)
+	app.RegisterCommand(server.Command{"graph", "Runs a command against the named graph instead of the default graph", "graph name command [arg ...]", false}, backend.Graph)
+	app.RegisterCommand(server.Command{"graphs", "Returns the statistics of every named graph", "", false}, backend.Graphs)
+	app.RegisterCommand(server.Command{"dropgraph", "Deletes the named graphs and their snapshots", "dropgraph name [name ...]", false}, backend.DropGraph)
+	app.RegisterCommand(server.Command{"script", "Loads a script into the cache returning its sha1, checks which scripts are cached or flushes the cache", "script load script|exists sha [sha ...]|flush", false}, backend.Script)
+	app.RegisterCommand(server.Command{"save", "Persists every graph, or the named graphs, to the data directory", "save [name ...]", false}, backend.Save)
 
 	return backend, nil
 }
 
 func (b *BGraphBackend) Load() error {
+	if err := b.loadGraphs(); err != nil {
+		return err
+	}
+
+	b.quit = make(chan struct{})
+	go b.sweep(b.quit)
 	return nil
 }
 
 func (b *BGraphBackend) Unload() error {
-	return nil
+	if b.quit != nil {
+		close(b.quit)
+		b.quit = nil
+	}
+
+	defer b.lockCommand("save")()
+	return b.saveGraphs()
 }
@@ -15,6 +15,54 @@ import (
 var helpCommands = [][]string{}
 var helpCommandsMap = make(map[string]int)
 
+// completionLimit is the number of vertex names offered when completing arguments
+const completionLimit = 20
+
+// vertexSlots are the names given to vertex arguments in command usages
+var vertexSlots = map[string]bool{"vertex": true, "from": true, "to": true, "source": true, "sink": true}
+
+// vertexCompleter looks up the vertex names starting with a prefix and keeps
+// them until the next command runs, a longer prefix of one whose names all
+// fit in a single page is answered from that page without asking the server
+type vertexCompleter struct {
+	lookup func(prefix string) []string
+	pages  map[string][]string
+}
+
+// completer offers the vertex names when completing arguments
+var completer = newVertexCompleter(func(prefix string) []string { return nil })
+
+func newVertexCompleter(lookup func(prefix string) []string) *vertexCompleter {
+	return &vertexCompleter{lookup, make(map[string][]string)}
+}
+
+// complete returns the vertex names starting with the prefix
+func (c *vertexCompleter) complete(prefix string) []string {
+	if names, ok := c.pages[prefix]; ok {
+		return names
+	}
+	for p, names := range c.pages {
+		if len(names) < completionLimit && strings.HasPrefix(prefix, p) {
+			var matched []string
+			for _, name := range names {
+				if strings.HasPrefix(name, prefix) {
+					matched = append(matched, name)
+				}
+			}
+			c.pages[prefix] = matched
+			return matched
+		}
+	}
+	names := c.lookup(prefix)
+	c.pages[prefix] = names
+	return names
+}
+
+// reset forgets the names looked up as the graph may have changed
+func (c *vertexCompleter) reset() {
+	c.pages = make(map[string][]string)
+}
+
 func main() {
 	var ip = flag.String("h", "127.0.0.1", "bgraph server ip (default 127.0.0.1)")
 	var port = flag.Int("p", 7331, "bgraph server port (default 7331)")
@@ -37,6 +85,22 @@ func main() {
 		printReply("cmds", reply, "")
 	}
 
+	completer = newVertexCompleter(func(prefix string) []string {
+		reply, err := c.Do("VPREFIX", prefix, 0, completionLimit)
+		if err != nil {
+			return nil
+		}
+		page, _ := reply.(map[string]interface{})
+		vertices, _ := page["vertices"].([]interface{})
+		names := make([]string, 0, len(vertices))
+		for _, v := range vertices {
+			if name, ok := v.(string); ok {
+				names = append(names, name)
+			}
+		}
+		return names
+	})
+
 	SetCompletionHandler(completionHandler)
 	setHistoryCapacity(100)
 
@@ -81,6 +145,7 @@ func main() {
 			} else if cmd == "CMDS" {
 				printCmds()
 			} else {
+				completer.reset()
 				async := isCmdAsync(cmd)
 				if async {
 					c.DoAsync(cmd, args...)
@@ -229,7 +294,71 @@ func printHelp(cmds []string) {
 	}
 }
 
+// usageSlots returns the argument names of the usage after the command name
+// and the names repeated by a trailing [arg ...] group, inline alternatives
+// such as name[,name ...] are kept as a single argument
+func usageSlots(usage string) ([]string, []string) {
+	var slots []string
+	fields := strings.Fields(usage)
+	group, inline := 0, 0
+	for _, field := range fields[1:] {
+		if inline > 0 {
+			inline += strings.Count(field, "[") - strings.Count(field, "]")
+			continue
+		}
+		if strings.HasPrefix(field, "[") {
+			group = len(slots)
+		}
+		name := strings.Trim(field, "[]")
+		if name == "..." {
+			return slots, slots[group:]
+		}
+		if i := strings.Index(name, "["); i > 0 {
+			inline = strings.Count(name, "[") - strings.Count(name, "]")
+			name = name[:i]
+		}
+		slots = append(slots, name)
+	}
+	return slots, nil
+}
+
+// vertexArgument reports whether the argument at the position after the
+// command takes a vertex according to the usage of the command, following
+// commands such as label that run another command
+func vertexArgument(args []string, position int) bool {
+	i, ok := helpCommandsMap[strings.ToUpper(args[0])]
+	if !ok {
+		return false
+	}
+	slots, repeat := usageSlots(helpCommands[i][1])
+	for c, slot := range slots {
+		if slot == "command" && position > c {
+			return vertexArgument(args[c+1:], position-c-1)
+		}
+	}
+	if position < len(slots) {
+		return vertexSlots[slots[position]]
+	}
+	if len(repeat) > 0 {
+		return vertexSlots[repeat[(position-len(slots))%len(repeat)]]
+	}
+	return false
+}
+
 func completionHandler(in string) []string {
+	// complete the last argument as a vertex name where the command takes one
+	if i := strings.LastIndex(in, " "); i >= 0 {
+		args := strings.Fields(in[:i+1])
+		if len(args) == 0 || !vertexArgument(args, len(args)-1) {
+			return nil
+		}
+		var lines []string
+		for _, name := range completer.complete(in[i+1:]) {
+			lines = append(lines, in[:i+1]+name)
+		}
+		return lines
+	}
+
 	var keywords []string
 	for _, i := range helpCommands {
 		if strings.HasPrefix(i[0], strings.ToUpper(in)) {
@@ -21,9 +21,11 @@ import (
 )
 
 type Configuration struct {
-	port      int    // port of the server
-	host      string // host of the server
-	bprotocol string // broadcast protocol configuration
+	Port      int    `toml:"port"`      // port of the server
+	Host      string `toml:"host"`      // host of the server
+	BProtocol string `toml:"bprotocol"` // broadcast protocol configuration
+	Dir       string `toml:"dir"`       // directory graph snapshots are saved to
+	Negative  string `toml:"negative"`  // negative weight policy of new graphs
 }
 
 var LogoHeader = `
@@ -44,10 +46,12 @@ func main() {
 	var bprotocol = flag.String("bprotocol", "redis", "Broadcast protocol configuration")
 	var configFile = flag.String("config", "", "bgraph configuration file (/etc/bgraph.conf)")
 	var cpuProfile = flag.String("cpuprofile", "", "write cpu profile to file")
+	var dir = flag.String("dir", "", "bgraph directory graph snapshots are saved to and loaded from (none when empty)")
+	var negative = flag.String("negative", "allow", "bgraph negative weight policy of new graphs (allow, clamp, reject or delete)")
 
 	flag.Parse()
 
-	cfg := &Configuration{*port, *host, *bprotocol}
+	cfg := &Configuration{*port, *host, *bprotocol, *dir, *negative}
 	if len(*configFile) == 0 {
 		fmt.Printf("[%d] %s # WARNING: no config file specified, using the default config\n", os.Getpid(), time.Now().Format(time.RFC822))
 	} else {
@@ -65,14 +69,14 @@ func main() {
 
 	// locate the protocol specified (if there is one)
 	var serverProtocol server.BroadcastServerProtocol
-	if cfg.bprotocol == "" {
+	if cfg.BProtocol == "" {
 		serverProtocol = server.NewDefaultBroadcastServerProtocol()
-	} else if cfg.bprotocol == "redis" {
+	} else if cfg.BProtocol == "redis" {
 		serverProtocol = redisProtocol.NewRedisProtocol()
-	} else if cfg.bprotocol == "line" {
+	} else if cfg.BProtocol == "line" {
 		serverProtocol = lineProtocol.NewLineProtocol()
 	} else {
-		fmt.Println(errors.New("Invalid protocol " + cfg.bprotocol + " specified"))
+		fmt.Println(errors.New("Invalid protocol " + cfg.BProtocol + " specified"))
 		return
 	}
 
@@ -86,7 +90,7 @@ func main() {
 	}
 
 	// create a new broadcast server
-	app, err := server.ListenProtocol(cfg.port, cfg.host, serverProtocol)
+	app, err := server.ListenProtocol(cfg.Port, cfg.Host, serverProtocol)
 	app.Header = ""
 	app.Name = "BGraph"
 	app.Version = "0.1.0"
@@ -105,7 +109,7 @@ func main() {
 	app.LoadBackend(backend)
 
 	// setup bgraph backend
-	backend, err = bgraph.RegisterBackend(app)
+	backend, err = bgraph.RegisterBackendConfig(app, bgraph.Config{Dir: cfg.Dir, Negative: cfg.Negative})
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -0,0 +1,154 @@
+package bgraph
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// compactResult is the number of edges and vertex weights removed by a compaction
+type compactResult struct {
+	Edges   int `json:"edges"`
+	Weights int `json:"weights"`
+}
+
+// negligible returns whether the weight should be removed rather than kept,
+// which is when pruning is enabled and its magnitude is at most epsilon
+func (m *MemoryGraphDb) negligible(weight float64) bool {
+	return m.prune && math.Abs(weight) <= m.epsilon
+}
+
+// negligibleEdge returns whether the edge should be removed, edges holding
+// properties are kept regardless of their weight
+func (m *MemoryGraphDb) negligibleEdge(edgeIndex int64, weight float64) bool {
+	return m.negligible(weight) && len(m.edgeProps[edgeIndex]) == 0
+}
+
+// setEpsilon will enable or disable the removal of edges and vertex weights
+// whose magnitude is at most epsilon, existing weights are removed by the
+// next compaction
+func (m *MemoryGraphDb) setEpsilon(prune bool, epsilon float64) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.prune = prune
+	m.epsilon = math.Abs(epsilon)
+}
+
+// getEpsilon returns the epsilon of the graph along with whether pruning is enabled
+func (m *MemoryGraphDb) getEpsilon() (float64, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.epsilon, m.prune
+}
+
+// compactWeights will remove the edges without properties and the vertex
+// weights at or below epsilon, including weights that decayed or whose sliding
+// window emptied since they were written. Vertices are kept as they may still
+// hold a label or properties, or be written again. The vertices are processed
+// in batches, releasing the lock in between, so that other commands run while
+// a whole graph is compacted.
+func (m *MemoryGraphDb) compactWeights() compactResult {
+	var result compactResult
+	m.Lock()
+	if !m.prune {
+		m.Unlock()
+		return result
+	}
+
+	// walk the vertices in name order, looking the next name up again once the
+	// lock is taken back as vertices may be created or removed in between
+	x := m.vertexNames.at(0)
+	batch := 0
+	for x != nil {
+		if batch >= transformBatch {
+			next := entryString(x.entry)
+			m.Unlock()
+			runtime.Gosched()
+			m.Lock()
+			if !m.prune {
+				break
+			}
+			x = m.vertexNames.at(m.vertexNames.countBefore(func(e indexEntry) bool { return entryString(e) < next }))
+			batch = 0
+			continue
+		}
+
+		name := entryString(x.entry)
+		x = x.next[0].node
+		f, ok := m.liveVertex(name)
+		if !ok {
+			continue
+		}
+
+		m.purgeEdges(f)
+		m.eachAdjacency(func(label string, adj edgeMap) {
+			for t, edgeIndex := range adj[f] {
+				batch++
+				if m.negligibleEdge(edgeIndex, m.edgeWeight(edgeIndex)) {
+					m.removeEdge(label, f, t)
+					result.Edges++
+				}
+			}
+		})
+		batch++
+		if _, ok := m.vertexWeights[f]; ok && m.negligible(m.vertexWeight(f)) {
+			delete(m.vertexWeights, f)
+			delete(m.vertexInts, f)
+			delete(m.vertexTimes, f)
+			result.Weights++
+		}
+	}
+	m.Unlock()
+
+	return result
+}
+
+// Epsilon will set or return the magnitude at or below which edges and
+// vertex weights are removed, off disables the removal
+func (b *BGraphBackend) Epsilon(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		if epsilon, ok := db.getEpsilon(); ok {
+			client.WriteJson(epsilon)
+		} else {
+			client.WriteNull()
+		}
+		client.Flush()
+		return nil
+	}
+
+	if len(d) > 1 {
+		client.WriteError(errors.New("epsilon takes at most 1 parameter (epsilon [value|off])"))
+		client.Flush()
+		return nil
+	}
+
+	if strings.ToLower(string(d[0])) == "off" {
+		db.setEpsilon(false, 0)
+	} else {
+		epsilon, err := strconv.ParseFloat(string(d[0]), 64)
+		if err != nil || math.IsNaN(epsilon) {
+			client.WriteError(errors.New("invalid epsilon " + string(d[0]) + " (epsilon [value|off])"))
+			client.Flush()
+			return nil
+		}
+		db.setEpsilon(true, epsilon)
+	}
+
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// Compact will remove the edges and vertex weights at or below epsilon,
+// returning how many were removed
+func (b *BGraphBackend) Compact(db DB, d [][]byte, client server.ProtocolClient) error {
+	client.WriteJson(db.compactWeights())
+	client.Flush()
+	return nil
+}
@@ -0,0 +1,114 @@
+package bgraph
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestCompactWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		prune   bool
+		epsilon float64
+		build   func(m *MemoryGraphDb)
+		result  compactResult
+		edges   map[string]float64 // remaining unlabeled edges from a
+	}{
+		{
+			name:    "pruning disabled",
+			prune:   false,
+			epsilon: 1,
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("a", "b", floatWeight(0))
+				m.setVertex("a", floatWeight(0.5))
+			},
+			result: compactResult{},
+			edges:  map[string]float64{"b": 0},
+		},
+		{
+			name:    "edges at or below epsilon",
+			prune:   true,
+			epsilon: 0.5,
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("a", "b", floatWeight(0.5))
+				m.setEdge("a", "c", floatWeight(-0.25))
+				m.setEdge("a", "d", floatWeight(0.75))
+				m.setEdge("a", "e", floatWeight(-1))
+			},
+			result: compactResult{Edges: 2},
+			edges:  map[string]float64{"d": 0.75, "e": -1},
+		},
+		{
+			name:    "edges with properties are kept",
+			prune:   true,
+			epsilon: 0,
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("a", "b", floatWeight(0))
+				m.setEdgeProperties("a", "c", properties{"source": "import"})
+			},
+			result: compactResult{Edges: 1},
+			edges:  map[string]float64{"c": 0},
+		},
+		{
+			name:    "labeled edges and vertex weights",
+			prune:   true,
+			epsilon: 0.1,
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("a", "b", floatWeight(1))
+				m.setLabeledEdge("likes", "a", "b", floatWeight(0.1))
+				m.setLabeledEdge("likes", "c", "a", floatWeight(2))
+				m.setVertex("a", floatWeight(0.05))
+				m.setVertex("b", floatWeight(3))
+			},
+			result: compactResult{Edges: 1, Weights: 1},
+			edges:  map[string]float64{"b": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.build(m)
+			vertices := m.info().Vertices
+			m.setEpsilon(tt.prune, tt.epsilon)
+
+			if got := m.compactWeights(); got != tt.result {
+				t.Errorf("compactWeights = %+v, want %+v", got, tt.result)
+			}
+			got := m.findEdges("a")
+			if len(got) != len(tt.edges) {
+				t.Errorf("edges from a = %v, want %v", got, tt.edges)
+			}
+			for to, weight := range tt.edges {
+				if w, ok := got[to]; !ok || w != weight {
+					t.Errorf("edges from a = %v, want %v", got, tt.edges)
+				}
+			}
+			if info := m.info(); info.Vertices != vertices {
+				t.Errorf("compaction removed vertices, %d left of %d", info.Vertices, vertices)
+			}
+		})
+	}
+}
+
+func TestCompactWeightsBatches(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	n := 3*transformBatch + 7
+	for i := 0; i < n; i++ {
+		v := "v" + strconv.Itoa(i)
+		m.setEdge(v, "hub", floatWeight(float64(i%2)))
+		m.setVertex(v, floatWeight(float64(i%2)))
+	}
+	m.setEpsilon(true, 0)
+
+	want := compactResult{Edges: (n + 1) / 2, Weights: (n + 1) / 2}
+	if got := m.compactWeights(); got != want {
+		t.Errorf("compactWeights = %+v, want %+v", got, want)
+	}
+	if info := m.info(); info.Edges != int64(n/2) || info.Vertices != int64(n+1) {
+		t.Errorf("info = %+v, want %d edges and %d vertices", info, n/2, n+1)
+	}
+	if got := m.compactWeights(); got != (compactResult{}) {
+		t.Errorf("second compactWeights = %+v, want nothing removed", got)
+	}
+}
@@ -0,0 +1,13 @@
+package bgraph
+
+// Config is the configuration of the bgraph backend as read from bgraph.conf
+type Config struct {
+	Dir      string `toml:"dir"`      // directory graph snapshots are saved to and loaded from, none are when empty
+	Negative string `toml:"negative"` // negative weight policy of new graphs (allow, clamp, reject or delete)
+}
+
+// DefaultConfig returns the configuration used when none is specified, which
+// keeps the graphs in memory only
+func DefaultConfig() Config {
+	return Config{Negative: string(negativeAllow)}
+}
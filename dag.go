@@ -0,0 +1,104 @@
+package bgraph
+
+import "sort"
+
+const (
+	dagUnvisited = iota
+	dagVisiting
+	dagDone
+)
+
+// dagFrame is a single entry of the explicit depth first search stack
+type dagFrame struct {
+	vertex    int64
+	neighbors []int64
+	next      int
+}
+
+// sortedNeighbors will return the outgoing neighbors of a vertex in index
+// order so that traversals are deterministic between calls
+func (m *MemoryGraphDb) sortedNeighbors(vertex int64) []int64 {
+	vertexEdges := m.edges[vertex]
+	neighbors := make([]int64, 0, len(vertexEdges))
+	for t := range vertexEdges {
+		neighbors = append(neighbors, t)
+	}
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i] < neighbors[j] })
+	return neighbors
+}
+
+// orderReachable will walk the directed graph reachable from the given
+// vertices. It returns the topological order of the reachable vertices, or
+// the first cycle found as a closed path (the first vertex is repeated last).
+func (m *MemoryGraphDb) orderReachable(vertices []string) ([]string, []string) {
+	state := make(map[int64]int)
+	postorder := make([]int64, 0)
+
+	for _, name := range vertices {
+		start, ok := m.vertices[name]
+		if !ok || state[start] != dagUnvisited {
+			continue
+		}
+
+		state[start] = dagVisiting
+		stack := []*dagFrame{{start, m.sortedNeighbors(start), 0}}
+		for len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if top.next == len(top.neighbors) {
+				state[top.vertex] = dagDone
+				postorder = append(postorder, top.vertex)
+				stack = stack[:len(stack)-1]
+				continue
+			}
+
+			n := top.neighbors[top.next]
+			top.next++
+			switch state[n] {
+			case dagUnvisited:
+				state[n] = dagVisiting
+				stack = append(stack, &dagFrame{n, m.sortedNeighbors(n), 0})
+			case dagVisiting:
+				// n is on the current stack, unwind back to it for the cycle
+				cycle := []string{m.r_vertices[n]}
+				i := len(stack) - 1
+				for stack[i].vertex != n {
+					i--
+				}
+				for _, frame := range stack[i+1:] {
+					cycle = append(cycle, m.r_vertices[frame.vertex])
+				}
+				cycle = append(cycle, m.r_vertices[n])
+				return nil, cycle
+			}
+		}
+	}
+
+	order := make([]string, len(postorder))
+	for i, index := range postorder {
+		order[len(postorder)-1-i] = m.r_vertices[index]
+	}
+	return order, nil
+}
+
+// findCycle will return an example directed cycle reachable from the
+// vertices or nil when the reachable graph is acyclic
+func (m *MemoryGraphDb) findCycle(vertices []string) []string {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	_, cycle := m.orderReachable(vertices)
+	return cycle
+}
+
+// topologicalSort will return the topological order of the directed graph
+// reachable from the vertices, or nil together with a cycle if none exists
+func (m *MemoryGraphDb) topologicalSort(vertices []string) ([]string, []string) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	return m.orderReachable(vertices)
+}
@@ -0,0 +1,86 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTopologicalSort(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    [][2]string
+		vertices []string
+		order    []string
+		cycle    []string
+	}{
+		{
+			name:     "missing vertex",
+			vertices: []string{"a"},
+			order:    []string{},
+		},
+		{
+			name:     "chain",
+			edges:    [][2]string{{"a", "b"}, {"b", "c"}},
+			vertices: []string{"a"},
+			order:    []string{"a", "b", "c"},
+		},
+		{
+			name:     "diamond",
+			edges:    [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}},
+			vertices: []string{"a"},
+			order:    []string{"a", "c", "b", "d"},
+		},
+		{
+			name:     "only reachable vertices",
+			edges:    [][2]string{{"x", "a"}, {"a", "b"}},
+			vertices: []string{"a"},
+			order:    []string{"a", "b"},
+		},
+		{
+			name:     "several starts",
+			edges:    [][2]string{{"a", "c"}, {"b", "c"}},
+			vertices: []string{"a", "b"},
+			order:    []string{"b", "a", "c"},
+		},
+		{
+			name:     "self loop",
+			edges:    [][2]string{{"a", "a"}},
+			vertices: []string{"a"},
+			cycle:    []string{"a", "a"},
+		},
+		{
+			name:     "cycle",
+			edges:    [][2]string{{"x", "a"}, {"a", "b"}, {"b", "c"}, {"c", "a"}},
+			vertices: []string{"x"},
+			cycle:    []string{"a", "b", "c", "a"},
+		},
+		{
+			name:     "cycle not reachable",
+			edges:    [][2]string{{"a", "b"}, {"c", "d"}, {"d", "c"}},
+			vertices: []string{"a"},
+			order:    []string{"a", "b"},
+		},
+		{
+			name:     "converging paths are not a cycle",
+			edges:    [][2]string{{"a", "b"}, {"b", "c"}, {"a", "c"}},
+			vertices: []string{"a"},
+			order:    []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			for _, e := range tt.edges {
+				m.setEdge(e[0], e[1], floatWeight(1))
+			}
+			order, cycle := m.topologicalSort(tt.vertices)
+			if !reflect.DeepEqual(order, tt.order) || !reflect.DeepEqual(cycle, tt.cycle) {
+				t.Errorf("topologicalSort(%v) = %v %v, want %v %v", tt.vertices, order, cycle, tt.order, tt.cycle)
+			}
+			if got := m.findCycle(tt.vertices); !reflect.DeepEqual(got, tt.cycle) {
+				t.Errorf("findCycle(%v) = %v, want %v", tt.vertices, got, tt.cycle)
+			}
+		})
+	}
+}
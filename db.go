@@ -1,74 +1,263 @@
 package bgraph
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type DB interface {
-	setEdge(from string, to string, weight float64)
-	incrEdge(from string, to string, weight float64)
-	decrEdge(from string, to string, weight float64)
-	setVertex(vertex string, weight float64)
-	incrVertex(vertex string, weight float64)
-	decrVertex(vertex string, weight float64)
+	setEdge(from string, to string, weight weightArg) error
+	incrEdge(from string, to string, weight weightArg) error
+	decrEdge(from string, to string, weight weightArg) error
+	setVertex(vertex string, weight weightArg) error
+	incrVertex(vertex string, weight weightArg) error
+	decrVertex(vertex string, weight weightArg) error
+	getVertex(vertex string) (float64, bool)
 	findEdges(vertex string) map[string]float64
 	sumIntersectEdges(vertices []string) map[string]float64
+	coreNumbers(weighted bool) map[string]float64
+	findCycle(vertices []string) []string
+	topologicalSort(vertices []string) ([]string, []string)
+	spanningForest(maximum bool) *spanningForest
+	maxFlow(source string, sink string) (*flowResult, error)
+	inducedSubgraph(vertices []string) *subgraph
+	egoSubgraph(vertex string, radius int) *subgraph
+	expireVertex(vertex string, ttl time.Duration)
+	expireEdge(from string, to string, ttl time.Duration)
+	vertexTTL(vertex string) (float64, bool)
+	edgeTTL(from string, to string) (float64, bool)
+	expireLabeledEdge(label string, from string, to string, ttl time.Duration)
+	labeledEdgeTTL(label string, from string, to string) (float64, bool)
+	sweepExpired() int
+	setHalfLife(halfLife time.Duration)
+	getHalfLife() time.Duration
+	setHistory(limit int, age time.Duration)
+	getHistory() (int, time.Duration)
+	findEdgesAt(vertex string, at timeSpec) (map[string]float64, error)
+	sumIntersectEdgesAt(vertices []string, at timeSpec) (map[string]float64, error)
+	findLabeledEdgesAt(labels []string, vertex string, at timeSpec) (map[string]float64, error)
+	sumIntersectLabeledEdgesAt(labels []string, vertices []string, at timeSpec) (map[string]float64, error)
+	incrWindowEdge(from string, to string, weight weightArg) error
+	setWindow(span time.Duration, buckets int) error
+	getWindow() (time.Duration, int)
+	info() graphInfo
+	snapshot() ([]byte, error)
+	restore(data []byte) error
+	flushGraph()
+	flushEdges()
+	flushVertexWeights()
+	setLabeledEdge(label string, from string, to string, weight weightArg) error
+	incrLabeledEdge(label string, from string, to string, weight weightArg) error
+	decrLabeledEdge(label string, from string, to string, weight weightArg) error
+	writeLabeledEdge(policy negativePolicy, label string, from string, to string, write weightWrite) error
+	writeVertex(policy negativePolicy, vertex string, write weightWrite) error
+	setNegative(policy negativePolicy)
+	getNegative() negativePolicy
+	setEpsilon(prune bool, epsilon float64)
+	getEpsilon() (float64, bool)
+	compactWeights() compactResult
+	setWeightMode(mode weightMode) error
+	getWeightMode() weightMode
+	findLabeledEdges(labels []string, vertex string) map[string]float64
+	sumIntersectLabeledEdges(labels []string, vertices []string) map[string]float64
+	edgeLabels(from string, to string) map[string]float64
+	findExactEdges(vertex string) (map[string]int64, error)
+	findLabeledExactEdges(labels []string, vertex string) (map[string]int64, error)
+	sumIntersectExactEdges(vertices []string) (map[string]int64, error)
+	sumIntersectLabeledExactEdges(labels []string, vertices []string) (map[string]int64, error)
+	exactEdgeLabels(from string, to string) map[string]int64
+	setVertexLabel(label string, vertex string)
+	setVertexProperties(vertex string, props properties)
+	delVertexProperties(vertex string, keys []string)
+	getVertexProperties(vertex string) (string, properties, bool)
+	filterVertices(vertices []string, predicates []vertexPredicate) map[string]bool
+	createIndex(key string, sorted bool)
+	dropIndex(key string)
+	getIndexes() map[string]string
+	findIndexed(key string, value interface{}, offset int, count int) (indexPage, error)
+	rangeIndexed(key string, min interface{}, max interface{}, offset int, count int) (indexPage, error)
+	prefixVertices(prefix string, offset int, count int) indexPage
+	rangeVertices(min string, max string, offset int, count int) indexPage
+	runQuery(q *graphQuery) ([]map[string]interface{}, error)
+	explainQuery(q *graphQuery) []string
+	setEdgeProperties(from string, to string, props properties)
+	delEdgeProperties(from string, to string, keys []string)
+	getEdgeProperties(from string, to string) (edgeDetail, bool)
+	findEdgesWithProperties(vertex string) (map[string]edgeDetail, error)
+	setLabeledEdgeProperties(label string, from string, to string, props properties)
+	delLabeledEdgeProperties(label string, from string, to string, keys []string)
+	getLabeledEdgeProperties(label string, from string, to string) (edgeDetail, bool)
+	findLabeledEdgesWithProperties(labels []string, vertex string) (map[string]edgeDetail, error)
+	normalizeEdges(vertex string) error
+	normalizeLabeledEdges(label string, vertex string) error
+	transformEdges(vertices []string, transform edgeTransform) transformResult
+	transformLabeledEdges(labels []string, vertices []string, transform edgeTransform) transformResult
 }
 
+// edgeMap maps a vertex to the set of vertices it has edges to edgeMap[a_vertex][b_vertex]edgeNum
+type edgeMap map[int64]map[int64]int64
+
 type MemoryGraphDb struct {
 	sync.Mutex
 
-	vertices      map[string]int64          // set of vertices and their associated map values
-	r_vertices    map[int64]string          // reverse lookup of the vertices index to the cooresponding name
-	vertexWeights map[int64]float64         // map of vertex weights
-	edges         map[int64]map[int64]int64 // map of vertex to the set of vertices edges[a_vertex][b_vertex]edgeNum
-	edgeWeights   map[int64]float64         // map of edge weights
-	totalVertices int64                     // atomically updated total of the number of vertices
-	totalEdges    int64                     // atomically updated total of the number of edges
-	allowNegative bool                      // allow for negative weights to occur
+	vertices       map[string]int64                        // set of vertices and their associated map values
+	r_vertices     map[int64]string                        // reverse lookup of the vertices index to the cooresponding name
+	vertexNames    *skipList                               // vertex names in order used to list vertices by prefix or range
+	vertexWeights  map[int64]float64                       // map of vertex weights
+	vertexInts     map[int64]int64                         // map of exact vertex weights when weights are integers
+	vertexLabels   map[int64]string                        // map of vertex labels (types such as user or item)
+	vertexProps    map[int64]properties                    // map of vertex properties holding strings or numbers
+	indexes        map[string]*propertyIndex               // map of secondary indexes over vertex properties
+	edges          map[int64]map[int64]int64               // map of vertex to the set of vertices edges[a_vertex][b_vertex]edgeNum
+	edgeWeights    map[int64]float64                       // map of edge weights
+	edgeInts       map[int64]int64                         // map of exact edge weights when weights are integers
+	labelEdges     map[string]edgeMap                      // map of labeled edges labelEdges[label][a_vertex][b_vertex]edgeNum
+	inbound        map[int64]map[int64]int                 // number of edges across labels to a vertex from each vertex inbound[b_vertex][a_vertex]
+	edgeProps      map[int64]properties                    // map of edge properties holding strings or numbers
+	vertexExpires  map[int64]int64                         // map of vertex expiration deadlines in unix nanoseconds
+	edgeExpires    map[int64]int64                         // map of edge expiration deadlines in unix nanoseconds
+	vertexTimes    map[int64]int64                         // map of when vertex weights were last written when decaying
+	edgeTimes      map[int64]int64                         // map of when edge weights were last written when decaying
+	edgeHistory    map[int64][]edgeVersion                 // map of edge weight versions kept for as-of queries
+	removedHistory map[string]map[historyKey][]edgeVersion // map of source vertex to the history of its removed edges
+	removedOrder   []removedEdge                           // removed edges in the order their history ages out
+	edgeWindows    map[int64]*windowCounter                // map of sliding window counters for windowed edges
+	freeVertices   []int64                                 // vertex indices reclaimed from removed vertices
+	freeEdges      []int64                                 // edge indices reclaimed from removed edges
+	totalVertices  int64                                   // atomically updated total of the number of vertices
+	totalEdges     int64                                   // atomically updated total of the number of edges
+	negative       negativePolicy                          // what happens to weights written below zero
+	prune          bool                                    // remove edges and vertex weights at or below epsilon
+	epsilon        float64                                 // magnitude at or below which weights are removed when pruning
+	mode           weightMode                              // whether weights are floats or exact integers
+	halfLife       time.Duration                           // half-life of exponentially decaying weights (0 disables decay)
+	historyLimit   int                                     // number of weight versions retained per edge (0 disables history)
+	historyAge     time.Duration                           // age after which weight versions are dropped (0 keeps them)
+	windowSpan     time.Duration                           // span of the sliding window counters
+	windowBuckets  int                                     // number of buckets in each sliding window counter
 }
 
 func NewMemoryGraphDb() (*MemoryGraphDb, error) {
 	mem := new(MemoryGraphDb)
 	mem.vertices = make(map[string]int64)
 	mem.r_vertices = make(map[int64]string)
+	mem.vertexNames = newSkipList()
 	mem.vertexWeights = make(map[int64]float64)
+	mem.vertexInts = make(map[int64]int64)
+	mem.vertexLabels = make(map[int64]string)
+	mem.vertexProps = make(map[int64]properties)
+	mem.indexes = make(map[string]*propertyIndex)
 	mem.edges = make(map[int64]map[int64]int64)
 	mem.edgeWeights = make(map[int64]float64)
-	mem.allowNegative = true
+	mem.edgeInts = make(map[int64]int64)
+	mem.labelEdges = make(map[string]edgeMap)
+	mem.inbound = make(map[int64]map[int64]int)
+	mem.edgeProps = make(map[int64]properties)
+	mem.vertexExpires = make(map[int64]int64)
+	mem.edgeExpires = make(map[int64]int64)
+	mem.vertexTimes = make(map[int64]int64)
+	mem.edgeTimes = make(map[int64]int64)
+	mem.edgeHistory = make(map[int64][]edgeVersion)
+	mem.removedHistory = make(map[string]map[historyKey][]edgeVersion)
+	mem.edgeWindows = make(map[int64]*windowCounter)
+	mem.negative = negativeAllow
+	mem.mode = weightFloat
+	mem.windowSpan = defaultWindowSpan
+	mem.windowBuckets = defaultWindowBuckets
 	return mem, nil
 }
 
-// getEdgeIndex will return the edge index according to the two vertices presented
-func (m *MemoryGraphDb) getEdgeIndex(from string, to string) int64 {
-	// ensure that both vertices exist in the map
-	f, f_ok := m.vertices[from]
-	t, t_ok := m.vertices[to]
-	if !f_ok {
-		m.totalVertices++
-		f = m.totalVertices
-		m.vertices[from] = f
-		m.r_vertices[f] = from
+// getVertexIndex will return the index of the vertex, creating it (and
+// reusing a reclaimed index when available) if it does not exist yet
+func (m *MemoryGraphDb) getVertexIndex(vertex string) int64 {
+	f, f_ok := m.liveVertex(vertex)
+	if f_ok {
+		return f
+	}
+
+	if n := len(m.freeVertices); n > 0 {
+		f = m.freeVertices[n-1]
+		m.freeVertices = m.freeVertices[:n-1]
+	} else {
+		// without free indices every index up to the total is in use
+		f = m.totalVertices + 1
+	}
+	m.totalVertices++
+	m.vertices[vertex] = f
+	m.r_vertices[f] = vertex
+	m.vertexNames.insert(indexEntry{vertex, f})
+	return f
+}
+
+// adjacency will return the edge map of the label, creating it if necessary.
+// Unlabeled edges are kept in m.edges under the empty label.
+func (m *MemoryGraphDb) adjacency(label string) edgeMap {
+	if label == "" {
+		return m.edges
+	}
+
+	adj, ok := m.labelEdges[label]
+	if !ok {
+		adj = make(edgeMap)
+		m.labelEdges[label] = adj
+	}
+	return adj
+}
+
+// selectAdjacency will return the edge maps of the labels, where * selects
+// the unlabeled edges along with every labeled edge
+func (m *MemoryGraphDb) selectAdjacency(labels []string) []edgeMap {
+	selected := make([]edgeMap, 0, len(labels))
+	for _, label := range labels {
+		if label == "*" {
+			selected = append(selected, m.edges)
+			for _, adj := range m.labelEdges {
+				selected = append(selected, adj)
+			}
+		} else if label == "" {
+			selected = append(selected, m.edges)
+		} else if adj, ok := m.labelEdges[label]; ok {
+			selected = append(selected, adj)
+		}
 	}
+	return selected
+}
 
-	if !t_ok {
-		m.totalVertices++
-		t = m.totalVertices
-		m.vertices[to] = t
-		m.r_vertices[t] = to
+// eachAdjacency will call fn with the edge map of every label
+func (m *MemoryGraphDb) eachAdjacency(fn func(label string, adj edgeMap)) {
+	fn("", m.edges)
+	for label, adj := range m.labelEdges {
+		fn(label, adj)
 	}
+}
+
+// getEdgeIndex will return the edge index according to the label and the two vertices presented
+func (m *MemoryGraphDb) getEdgeIndex(label string, from string, to string) int64 {
+	// ensure that both vertices exist in the map
+	f := m.getVertexIndex(from)
+	t := m.getVertexIndex(to)
 
 	// find the edge map or create it
-	ef, ef_ok := m.edges[f]
+	adj := m.adjacency(label)
+	ef, ef_ok := adj[f]
 	if !ef_ok {
 		ef = make(map[int64]int64)
-		m.edges[f] = ef
+		adj[f] = ef
 	}
 
 	// find the edge appropriately
-	ef_t, ok := ef[t]
+	ef_t, ok := m.liveEdge(label, f, t)
 	if !ok {
+		if n := len(m.freeEdges); n > 0 {
+			ef_t = m.freeEdges[n-1]
+			m.freeEdges = m.freeEdges[:n-1]
+		} else {
+			ef_t = m.totalEdges + 1
+		}
 		m.totalEdges++
-		ef_t = m.totalEdges
 		ef[t] = ef_t
+		m.addInbound(f, t)
+		m.reviveHistory(label, f, t, ef_t)
 	}
 
 	// find the edge weight or set it automatically
@@ -79,143 +268,230 @@ func (m *MemoryGraphDb) getEdgeIndex(from string, to string) int64 {
 	return ef_t
 }
 
-func (m *MemoryGraphDb) setEdge(from string, to string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
+// removeEdge will delete the labeled edge between the two vertex indices and reclaim its index
+func (m *MemoryGraphDb) removeEdge(label string, f int64, t int64) {
+	adj := m.edges
+	if label != "" {
+		adj = m.labelEdges[label]
+	}
+	vertexEdges, ok := adj[f]
+	if !ok {
+		return
+	}
+	edgeIndex, ok := vertexEdges[t]
+	if !ok {
+		return
+	}
 
-	// set the edge weight now that we have the proper index
-	ef_t := m.getEdgeIndex(from, to)
-	m.edgeWeights[ef_t] = weight
-	if !m.allowNegative && m.edgeWeights[ef_t] < 0 {
-		m.edgeWeights[ef_t] = 0
+	delete(vertexEdges, t)
+	m.removeInbound(f, t)
+	if len(vertexEdges) == 0 {
+		delete(adj, f)
+		if len(adj) == 0 && label != "" {
+			delete(m.labelEdges, label)
+		}
 	}
+	delete(m.edgeWeights, edgeIndex)
+	delete(m.edgeInts, edgeIndex)
+	delete(m.edgeProps, edgeIndex)
+	delete(m.edgeExpires, edgeIndex)
+	delete(m.edgeTimes, edgeIndex)
+	m.retireHistory(label, f, t, edgeIndex)
+	delete(m.edgeWindows, edgeIndex)
+	m.freeEdges = append(m.freeEdges, edgeIndex)
+	m.totalEdges--
 }
 
-func (m *MemoryGraphDb) incrEdge(from string, to string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
-
-	// set the edge weight now that we have the proper index
-	ef_t := m.getEdgeIndex(from, to)
-	m.edgeWeights[ef_t] += weight
-	if !m.allowNegative && m.edgeWeights[ef_t] < 0 {
-		m.edgeWeights[ef_t] = 0
+// addInbound records an edge from f to t in the reverse adjacency
+func (m *MemoryGraphDb) addInbound(f int64, t int64) {
+	sources, ok := m.inbound[t]
+	if !ok {
+		sources = make(map[int64]int)
+		m.inbound[t] = sources
 	}
+	sources[f]++
 }
 
-func (m *MemoryGraphDb) decrEdge(from string, to string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
-
-	// set the edge weight now that we have the proper index
-	ef_t := m.getEdgeIndex(from, to)
-	m.edgeWeights[ef_t] -= weight
-	if !m.allowNegative && m.edgeWeights[ef_t] < 0 {
-		m.edgeWeights[ef_t] = 0
+// removeInbound forgets an edge from f to t in the reverse adjacency
+func (m *MemoryGraphDb) removeInbound(f int64, t int64) {
+	sources := m.inbound[t]
+	if sources[f] > 1 {
+		sources[f]--
+		return
+	}
+	delete(sources, f)
+	if len(sources) == 0 {
+		delete(m.inbound, t)
 	}
 }
 
-func (m *MemoryGraphDb) setVertex(vertex string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
+// rebuildInbound will recompute the reverse adjacency from every edge
+func (m *MemoryGraphDb) rebuildInbound() {
+	m.inbound = make(map[int64]map[int64]int)
+	m.eachAdjacency(func(label string, adj edgeMap) {
+		for f, vertexEdges := range adj {
+			for t := range vertexEdges {
+				m.addInbound(f, t)
+			}
+		}
+	})
+}
 
-	// set the vertex weight now that we have an index
-	f, f_ok := m.vertices[vertex]
-	if !f_ok {
-		m.totalVertices++
-		f = m.totalVertices
-		m.vertices[vertex] = f
-		m.r_vertices[f] = vertex
+// removeVertex will delete the vertex along with every edge to or from it
+// and reclaim its index, incoming edges are found through the reverse adjacency
+func (m *MemoryGraphDb) removeVertex(f int64) {
+	name, ok := m.r_vertices[f]
+	if !ok {
+		return
 	}
 
-	m.vertexWeights[f] = weight
-	if !m.allowNegative && m.vertexWeights[f] < 0 {
-		m.vertexWeights[f] = 0
+	sources := make([]int64, 0, len(m.inbound[f]))
+	for from := range m.inbound[f] {
+		sources = append(sources, from)
 	}
+	m.eachAdjacency(func(label string, adj edgeMap) {
+		for t := range adj[f] {
+			m.removeEdge(label, f, t)
+		}
+		for _, from := range sources {
+			if _, ok := adj[from][f]; ok {
+				m.removeEdge(label, from, f)
+			}
+		}
+	})
+
+	delete(m.vertices, name)
+	delete(m.r_vertices, f)
+	m.vertexNames.remove(indexEntry{name, f})
+	delete(m.vertexWeights, f)
+	delete(m.vertexInts, f)
+	m.unindexVertex(f)
+	delete(m.vertexLabels, f)
+	delete(m.vertexProps, f)
+	delete(m.vertexExpires, f)
+	delete(m.vertexTimes, f)
+	m.freeVertices = append(m.freeVertices, f)
+	m.totalVertices--
 }
 
-func (m *MemoryGraphDb) incrVertex(vertex string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
+func (m *MemoryGraphDb) setEdge(from string, to string, weight weightArg) error {
+	return m.setLabeledEdge("", from, to, weight)
+}
 
-	// set the vertex weight now that we have an index
-	f, f_ok := m.vertices[vertex]
-	if !f_ok {
-		m.totalVertices++
-		f = m.totalVertices
-		m.vertices[vertex] = f
-		m.r_vertices[f] = vertex
-	}
+// setLabeledEdge will set the weight of the edge with the given label
+func (m *MemoryGraphDb) setLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return m.writeLabeledEdge(negativeDefault, label, from, to, setWeight(weight))
+}
 
-	if _, ok := m.vertexWeights[f]; !ok {
-		m.vertexWeights[f] = weight
-	} else {
-		m.vertexWeights[f] += weight
-	}
+func (m *MemoryGraphDb) incrEdge(from string, to string, weight weightArg) error {
+	return m.incrLabeledEdge("", from, to, weight)
+}
 
-	if !m.allowNegative && m.vertexWeights[f] < 0 {
-		m.vertexWeights[f] = 0
-	}
+// incrLabeledEdge will increment the weight of the edge with the given label
+func (m *MemoryGraphDb) incrLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return m.writeLabeledEdge(negativeDefault, label, from, to, incrWeight(weight))
 }
 
-func (m *MemoryGraphDb) decrVertex(vertex string, weight float64) {
-	m.Lock()
-	defer m.Unlock()
+func (m *MemoryGraphDb) decrEdge(from string, to string, weight weightArg) error {
+	return m.decrLabeledEdge("", from, to, weight)
+}
 
-	// set the vertex weight now that we have an index
-	f, f_ok := m.vertices[vertex]
-	if !f_ok {
-		m.totalVertices++
-		f = m.totalVertices
-		m.vertices[vertex] = f
-		m.r_vertices[f] = vertex
-	}
+// decrLabeledEdge will decrement the weight of the edge with the given label
+func (m *MemoryGraphDb) decrLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return m.writeLabeledEdge(negativeDefault, label, from, to, decrWeight(weight))
+}
 
-	if _, ok := m.vertexWeights[f]; !ok {
-		m.vertexWeights[f] = -1 * weight
-	} else {
-		m.vertexWeights[f] -= weight
-	}
+func (m *MemoryGraphDb) setVertex(vertex string, weight weightArg) error {
+	return m.writeVertex(negativeDefault, vertex, setWeight(weight))
+}
 
-	if !m.allowNegative && m.vertexWeights[f] < 0 {
-		m.vertexWeights[f] = 0
+func (m *MemoryGraphDb) incrVertex(vertex string, weight weightArg) error {
+	return m.writeVertex(negativeDefault, vertex, incrWeight(weight))
+}
+
+func (m *MemoryGraphDb) decrVertex(vertex string, weight weightArg) error {
+	return m.writeVertex(negativeDefault, vertex, decrWeight(weight))
+}
+
+// getVertex will return the weight of the vertex along with whether it exists
+func (m *MemoryGraphDb) getVertex(vertex string) (float64, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return 0, false
 	}
+	return m.vertexWeight(f), true
 }
 
 func (m *MemoryGraphDb) findEdges(vertex string) map[string]float64 {
+	return m.findLabeledEdges([]string{""}, vertex)
+}
+
+// findLabeledEdges will return the edges from the vertex with the given labels,
+// summing the weights of the edges to the same vertex across labels
+func (m *MemoryGraphDb) findLabeledEdges(labels []string, vertex string) map[string]float64 {
 	m.Lock()
 	defer m.Unlock()
 
-	f, f_ok := m.vertices[vertex]
+	f, f_ok := m.liveVertex(vertex)
 	if !f_ok {
 		return nil
 	}
 
-	if vertexEdges, ok := m.edges[f]; ok {
-		result := make(map[string]float64)
+	m.purgeEdges(f)
+	weights := m.neighborWeights(m.selectAdjacency(labels), f)
+	if weights == nil {
+		return nil
+	}
+
+	result := make(map[string]float64, len(weights))
+	for vertexIndex, weight := range weights {
+		if to, v_ok := m.r_vertices[vertexIndex]; v_ok {
+			result[to] = weight
+		}
+	}
+	return result
+}
+
+// neighborWeights will return the sum of the edge weights from the vertex to
+// each of its neighbors across the edge maps, or nil when there are none
+func (m *MemoryGraphDb) neighborWeights(adjs []edgeMap, f int64) map[int64]float64 {
+	var weights map[int64]float64
+	for _, adj := range adjs {
+		vertexEdges, ok := adj[f]
+		if !ok {
+			continue
+		}
+		if weights == nil {
+			weights = make(map[int64]float64, len(vertexEdges))
+		}
 		for vertexIndex, edgeIndex := range vertexEdges {
-			to, v_ok := m.r_vertices[vertexIndex]
-			weight, w_ok := m.edgeWeights[edgeIndex]
-			if v_ok && w_ok {
-				result[to] = weight
-			}
+			weights[vertexIndex] += m.edgeWeight(edgeIndex)
 		}
-		return result
-	} else {
-		return nil
 	}
+	return weights
 }
 
 func (m *MemoryGraphDb) sumIntersectEdges(vertices []string) map[string]float64 {
+	return m.sumIntersectLabeledEdges([]string{""}, vertices)
+}
+
+// sumIntersectLabeledEdges will return the intersection of the edges with the
+// given labels from all of the vertices along with the sum of their weights
+func (m *MemoryGraphDb) sumIntersectLabeledEdges(labels []string, vertices []string) map[string]float64 {
 	m.Lock()
 	defer m.Unlock()
 
-	values := make([]map[int64]int64, len(vertices))
+	adjs := m.selectAdjacency(labels)
+	values := make([]map[int64]float64, len(vertices))
 	minimalIndex := 0
 	for i, k := range vertices {
-		if index, ok := m.vertices[k]; ok {
-			e, ok := m.edges[index]
-			if !ok {
+		if index, ok := m.liveVertex(k); ok {
+			m.purgeEdges(index)
+			e := m.neighborWeights(adjs, index)
+			if e == nil {
 				return nil
 			}
 			values[i] = e
@@ -229,24 +505,20 @@ func (m *MemoryGraphDb) sumIntersectEdges(vertices []string) map[string]float64
 
 	minimalSet := values[minimalIndex]
 	results := make(map[string]float64)
-	for edgeVertex, edgeIndex := range minimalSet {
+	for edgeVertex, weight := range minimalSet {
 		value := true
-		sum := float64(0)
-		weight, ok := m.edgeWeights[edgeIndex]
-		if ok {
-			sum += weight
-		}
+		sum := weight
 		for i, v := range values {
 			if i == minimalIndex {
 				continue
 			}
 
-			e, ok := v[edgeVertex]
+			w, ok := v[edgeVertex]
 			if !ok {
 				value = false
 				break
 			} else {
-				sum += m.edgeWeights[e]
+				sum += w
 			}
 		}
 
@@ -258,3 +530,39 @@ func (m *MemoryGraphDb) sumIntersectEdges(vertices []string) map[string]float64
 
 	return results
 }
+
+// graphInfo is a summary of the contents and settings of a graph
+type graphInfo struct {
+	Vertices         int64   `json:"vertices"`
+	Edges            int64   `json:"edges"`
+	ExpiringVertices int     `json:"expiring_vertices"`
+	ExpiringEdges    int     `json:"expiring_edges"`
+	WindowedEdges    int     `json:"windowed_edges"`
+	Labels           int     `json:"labels"`
+	HalfLife         float64 `json:"half_life"`
+	HistoryLimit     int     `json:"history_limit"`
+	Negative         string  `json:"negative"`
+	Epsilon          float64 `json:"epsilon"`
+	Prune            bool    `json:"prune"`
+	Weights          string  `json:"weights"`
+}
+
+func (m *MemoryGraphDb) info() graphInfo {
+	m.Lock()
+	defer m.Unlock()
+
+	return graphInfo{
+		Vertices:         m.totalVertices,
+		Edges:            m.totalEdges,
+		ExpiringVertices: len(m.vertexExpires),
+		ExpiringEdges:    len(m.edgeExpires),
+		WindowedEdges:    len(m.edgeWindows),
+		Labels:           len(m.labelEdges),
+		HalfLife:         m.halfLife.Seconds(),
+		HistoryLimit:     m.historyLimit,
+		Negative:         string(m.negative),
+		Epsilon:          m.epsilon,
+		Prune:            m.prune,
+		Weights:          string(m.mode),
+	}
+}
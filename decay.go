@@ -0,0 +1,101 @@
+package bgraph
+
+import (
+	"math"
+	"time"
+)
+
+// decayFactor returns how much of a weight written at the given time remains
+// now according to the half-life of the graph
+func (m *MemoryGraphDb) decayFactor(written int64, now int64) float64 {
+	if m.halfLife <= 0 || now <= written {
+		return 1
+	}
+	return math.Exp2(-float64(now-written) / float64(m.halfLife))
+}
+
+// edgeWeight will return the current weight of the edge with decay applied,
+// or the total of its sliding window counter for windowed edges
+func (m *MemoryGraphDb) edgeWeight(edgeIndex int64) float64 {
+	if counter, ok := m.edgeWindows[edgeIndex]; ok {
+		return counter.sum(m.windowBucket(time.Now().UnixNano()))
+	}
+
+	weight := m.edgeWeights[edgeIndex]
+	if written, ok := m.edgeTimes[edgeIndex]; ok {
+		weight *= m.decayFactor(written, time.Now().UnixNano())
+	}
+	return weight
+}
+
+// vertexWeight will return the current weight of the vertex with decay applied
+func (m *MemoryGraphDb) vertexWeight(f int64) float64 {
+	weight := m.vertexWeights[f]
+	if written, ok := m.vertexTimes[f]; ok {
+		weight *= m.decayFactor(written, time.Now().UnixNano())
+	}
+	return weight
+}
+
+// touchEdge records the edge weight as written now so that it decays from
+// here and is kept as a new version in the edge history
+func (m *MemoryGraphDb) touchEdge(edgeIndex int64) {
+	now := time.Now().UnixNano()
+	if m.halfLife > 0 {
+		m.edgeTimes[edgeIndex] = now
+	}
+	if m.historyLimit > 0 {
+		m.recordEdge(edgeIndex, now)
+	}
+}
+
+// touchVertex records the vertex weight as written now so that it decays from here
+func (m *MemoryGraphDb) touchVertex(f int64) {
+	if m.halfLife > 0 {
+		m.vertexTimes[f] = time.Now().UnixNano()
+	}
+}
+
+// setHalfLife will change the decay half-life of the graph, a half-life of
+// zero or below disables decay. Every weight is rebased to its current
+// decayed value first so that the change only affects time from now on.
+// Windowed edges are left alone as their weight is the live counter total.
+func (m *MemoryGraphDb) setHalfLife(halfLife time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	now := time.Now().UnixNano()
+	for edgeIndex := range m.edgeWeights {
+		if _, ok := m.edgeWindows[edgeIndex]; !ok {
+			m.edgeWeights[edgeIndex] = m.edgeWeight(edgeIndex)
+		}
+	}
+	for f := range m.vertexWeights {
+		m.vertexWeights[f] = m.vertexWeight(f)
+	}
+
+	m.edgeTimes = make(map[int64]int64)
+	m.vertexTimes = make(map[int64]int64)
+	if halfLife < 0 {
+		halfLife = 0
+	}
+	m.halfLife = halfLife
+	if halfLife > 0 {
+		for edgeIndex := range m.edgeWeights {
+			if _, ok := m.edgeWindows[edgeIndex]; !ok {
+				m.edgeTimes[edgeIndex] = now
+			}
+		}
+		for f := range m.vertexWeights {
+			m.vertexTimes[f] = now
+		}
+	}
+}
+
+// getHalfLife returns the decay half-life of the graph
+func (m *MemoryGraphDb) getHalfLife() time.Duration {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.halfLife
+}
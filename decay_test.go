@@ -0,0 +1,101 @@
+package bgraph
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecayFactor(t *testing.T) {
+	tests := []struct {
+		halfLife time.Duration
+		age      time.Duration
+		want     float64
+	}{
+		{0, time.Hour, 1},
+		{time.Minute, 0, 1},
+		{time.Minute, -time.Minute, 1},
+		{time.Minute, time.Minute, 0.5},
+		{time.Minute, 3 * time.Minute, 0.125},
+		{time.Minute, 30 * time.Second, math.Sqrt(0.5)},
+	}
+
+	for _, tt := range tests {
+		m, _ := NewMemoryGraphDb()
+		m.halfLife = tt.halfLife
+		now := time.Now().UnixNano()
+		if got := m.decayFactor(now-int64(tt.age), now); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("decayFactor(half-life %v, age %v) = %v, want %v", tt.halfLife, tt.age, got, tt.want)
+		}
+	}
+}
+
+// ageWeights will move the write time of every weight back by the duration
+func ageWeights(m *MemoryGraphDb, age time.Duration) {
+	for edgeIndex, written := range m.edgeTimes {
+		m.edgeTimes[edgeIndex] = written - int64(age)
+	}
+	for f, written := range m.vertexTimes {
+		m.vertexTimes[f] = written - int64(age)
+	}
+}
+
+// near reports whether the decayed weight is within the time the test took
+func near(got float64, want float64) bool {
+	return math.Abs(got-want) < 1e-3*math.Max(1, math.Abs(want))
+}
+
+func TestDecayedWeights(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(8))
+	m.setVertex("a", floatWeight(4))
+	m.setHalfLife(time.Hour)
+	ageWeights(m, time.Hour)
+
+	if got := m.findEdges("a")["b"]; !near(got, 4) {
+		t.Errorf("edge after one half-life = %v, want 4", got)
+	}
+	if got, _ := m.getVertex("a"); !near(got, 2) {
+		t.Errorf("vertex after one half-life = %v, want 2", got)
+	}
+
+	// an increment applies to the decayed weight and restarts the decay
+	m.incrEdge("a", "b", floatWeight(1))
+	if got := m.findEdges("a")["b"]; !near(got, 5) {
+		t.Errorf("edge after increment = %v, want 5", got)
+	}
+	ageWeights(m, 2*time.Hour)
+	if got := m.findEdges("a")["b"]; !near(got, 1.25) {
+		t.Errorf("edge two half-lives after increment = %v, want 1.25", got)
+	}
+
+	// disabling decay keeps the decayed weights as they are now
+	m.setHalfLife(0)
+	if len(m.edgeTimes) != 0 || len(m.vertexTimes) != 0 {
+		t.Errorf("write times kept after disabling decay")
+	}
+	if got := m.findEdges("a")["b"]; !near(got, 1.25) {
+		t.Errorf("edge after disabling decay = %v, want 1.25", got)
+	}
+	if got, _ := m.getVertex("a"); !near(got, 0.5) {
+		t.Errorf("vertex after disabling decay = %v, want 0.5", got)
+	}
+}
+
+func TestHalfLifeKeepsWindows(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setWindow(time.Hour, 4)
+	m.incrWindowEdge("a", "b", floatWeight(3))
+	static := m.edgeWeights[m.edgeIndexOf("a", "b")]
+
+	m.setHalfLife(time.Minute)
+	if got := m.edgeWeights[m.edgeIndexOf("a", "b")]; got != static {
+		t.Errorf("static weight of a windowed edge = %v, want %v", got, static)
+	}
+	if _, ok := m.edgeTimes[m.edgeIndexOf("a", "b")]; ok {
+		t.Errorf("windowed edge given a write time to decay from")
+	}
+	if got := m.findEdges("a")["b"]; got != 3 {
+		t.Errorf("windowed weight = %v, want 3", got)
+	}
+}
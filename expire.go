@@ -0,0 +1,201 @@
+package bgraph
+
+import "time"
+
+// isExpired returns true when the deadline (in unix nanoseconds) has passed
+func isExpired(deadline int64, now int64) bool {
+	return deadline <= now
+}
+
+// liveVertex will return the index of the vertex, removing it first when its
+// expiration deadline has already passed
+func (m *MemoryGraphDb) liveVertex(vertex string) (int64, bool) {
+	f, ok := m.vertices[vertex]
+	if !ok {
+		return 0, false
+	}
+
+	if deadline, ok := m.vertexExpires[f]; ok && isExpired(deadline, time.Now().UnixNano()) {
+		m.removeVertex(f)
+		return 0, false
+	}
+	return f, true
+}
+
+// liveEdge will return the index of the labeled edge between the two vertex
+// indices, removing the edge first when its expiration deadline has passed
+func (m *MemoryGraphDb) liveEdge(label string, f int64, t int64) (int64, bool) {
+	adj := m.edges
+	if label != "" {
+		adj = m.labelEdges[label]
+	}
+	edgeIndex, ok := adj[f][t]
+	if !ok {
+		return 0, false
+	}
+
+	if deadline, ok := m.edgeExpires[edgeIndex]; ok && isExpired(deadline, time.Now().UnixNano()) {
+		m.removeEdge(label, f, t)
+		return 0, false
+	}
+	return edgeIndex, true
+}
+
+// purgeEdges will lazily remove the expired edges leaving the vertex along
+// with any expired vertices they point to
+func (m *MemoryGraphDb) purgeEdges(f int64) {
+	if len(m.edgeExpires) == 0 && len(m.vertexExpires) == 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	m.eachAdjacency(func(label string, adj edgeMap) {
+		for t, edgeIndex := range adj[f] {
+			if deadline, ok := m.vertexExpires[t]; ok && isExpired(deadline, now) {
+				m.removeVertex(t)
+			} else if deadline, ok := m.edgeExpires[edgeIndex]; ok && isExpired(deadline, now) {
+				m.removeEdge(label, f, t)
+			}
+		}
+	})
+}
+
+// purgeExpired will remove every expired vertex and edge from the graph
+func (m *MemoryGraphDb) purgeExpired() int {
+	if len(m.edgeExpires) == 0 && len(m.vertexExpires) == 0 {
+		return 0
+	}
+
+	now := time.Now().UnixNano()
+	removed := m.purgeExpiredVertices(now)
+
+	if len(m.edgeExpires) > 0 {
+		m.eachAdjacency(func(label string, adj edgeMap) {
+			for f, vertexEdges := range adj {
+				for t, edgeIndex := range vertexEdges {
+					if deadline, ok := m.edgeExpires[edgeIndex]; ok && isExpired(deadline, now) {
+						m.removeEdge(label, f, t)
+						removed++
+					}
+				}
+			}
+		})
+	}
+	return removed
+}
+
+// purgeExpiredVertices will remove every vertex whose deadline has passed
+func (m *MemoryGraphDb) purgeExpiredVertices(now int64) int {
+	removed := 0
+	for f, deadline := range m.vertexExpires {
+		if isExpired(deadline, now) {
+			m.removeVertex(f)
+			removed++
+		}
+	}
+	return removed
+}
+
+// sweepExpired is run by the background sweeper to reclaim expired entries
+// that were never accessed again
+func (m *MemoryGraphDb) sweepExpired() int {
+	m.Lock()
+	defer m.Unlock()
+
+	m.expireRemovedHistory(time.Now().UnixNano())
+	return m.purgeExpired()
+}
+
+// expireVertex will set the time to live of an existing vertex, a ttl of zero
+// or below removes any expiration
+func (m *MemoryGraphDb) expireVertex(vertex string, ttl time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return
+	}
+
+	if ttl <= 0 {
+		delete(m.vertexExpires, f)
+	} else {
+		m.vertexExpires[f] = time.Now().Add(ttl).UnixNano()
+	}
+}
+
+func (m *MemoryGraphDb) expireEdge(from string, to string, ttl time.Duration) {
+	m.expireLabeledEdge("", from, to, ttl)
+}
+
+// expireLabeledEdge will set the time to live of an existing edge with the
+// given label, a ttl of zero or below removes any expiration
+func (m *MemoryGraphDb) expireLabeledEdge(label string, from string, to string, ttl time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, f_ok := m.liveVertex(from)
+	t, t_ok := m.liveVertex(to)
+	if !f_ok || !t_ok {
+		return
+	}
+
+	edgeIndex, ok := m.liveEdge(label, f, t)
+	if !ok {
+		return
+	}
+
+	if ttl <= 0 {
+		delete(m.edgeExpires, edgeIndex)
+	} else {
+		m.edgeExpires[edgeIndex] = time.Now().Add(ttl).UnixNano()
+	}
+}
+
+// remaining converts a deadline into the seconds left, or -1 when there is none
+func remaining(deadline int64, ok bool) float64 {
+	if !ok {
+		return -1
+	}
+	return time.Duration(deadline - time.Now().UnixNano()).Seconds()
+}
+
+// vertexTTL will return the seconds left before the vertex expires (-1 when
+// it never expires) and whether the vertex exists
+func (m *MemoryGraphDb) vertexTTL(vertex string) (float64, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return 0, false
+	}
+
+	deadline, ok := m.vertexExpires[f]
+	return remaining(deadline, ok), true
+}
+
+func (m *MemoryGraphDb) edgeTTL(from string, to string) (float64, bool) {
+	return m.labeledEdgeTTL("", from, to)
+}
+
+// labeledEdgeTTL will return the seconds left before the edge with the given
+// label expires (-1 when it never expires) and whether the edge exists
+func (m *MemoryGraphDb) labeledEdgeTTL(label string, from string, to string) (float64, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, f_ok := m.liveVertex(from)
+	t, t_ok := m.liveVertex(to)
+	if !f_ok || !t_ok {
+		return 0, false
+	}
+
+	edgeIndex, ok := m.liveEdge(label, f, t)
+	if !ok {
+		return 0, false
+	}
+
+	deadline, ok := m.edgeExpires[edgeIndex]
+	return remaining(deadline, ok), true
+}
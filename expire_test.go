@@ -0,0 +1,171 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+		err  bool
+	}{
+		{"10", 10 * time.Second, false},
+		{"0.5", 500 * time.Millisecond, false},
+		{"0", 0, false},
+		{"-1", -time.Second, false},
+		{"abc", 0, true},
+		{"", 0, true},
+		{"inf", 0, true},
+		{"NaN", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTTL([]byte(tt.text))
+		if (err != nil) != tt.err {
+			t.Errorf("parseTTL(%q) error = %v, want error %v", tt.text, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTTL(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// expireNow will move the deadline of every expiring vertex and edge into the past
+func expireNow(m *MemoryGraphDb) {
+	past := time.Now().Add(-time.Second).UnixNano()
+	for f := range m.vertexExpires {
+		m.vertexExpires[f] = past
+	}
+	for edgeIndex := range m.edgeExpires {
+		m.edgeExpires[edgeIndex] = past
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	tests := []struct {
+		name     string
+		expire   func(m *MemoryGraphDb)
+		edges    map[string]float64 // remaining edges from a
+		vertices int64
+		swept    int
+	}{
+		{
+			name:     "nothing expires",
+			expire:   func(m *MemoryGraphDb) {},
+			edges:    map[string]float64{"b": 1, "c": 2},
+			vertices: 3,
+		},
+		{
+			name: "edge",
+			expire: func(m *MemoryGraphDb) {
+				m.expireEdge("a", "b", time.Hour)
+			},
+			edges:    map[string]float64{"c": 2},
+			vertices: 3,
+			swept:    1,
+		},
+		{
+			name: "vertex takes its edges",
+			expire: func(m *MemoryGraphDb) {
+				m.expireVertex("c", time.Hour)
+			},
+			edges:    map[string]float64{"b": 1},
+			vertices: 2,
+			swept:    1,
+		},
+		{
+			name: "zero ttl clears the expiration",
+			expire: func(m *MemoryGraphDb) {
+				m.expireEdge("a", "b", time.Hour)
+				m.expireVertex("c", time.Hour)
+				m.expireEdge("a", "b", 0)
+				m.expireVertex("c", -time.Second)
+			},
+			edges:    map[string]float64{"b": 1, "c": 2},
+			vertices: 3,
+		},
+		{
+			name: "labeled edge",
+			expire: func(m *MemoryGraphDb) {
+				m.setLabeledEdge("likes", "a", "b", floatWeight(5))
+				m.expireLabeledEdge("likes", "a", "b", time.Hour)
+			},
+			edges:    map[string]float64{"b": 1, "c": 2},
+			vertices: 3,
+			swept:    1,
+		},
+		{
+			name: "missing edge",
+			expire: func(m *MemoryGraphDb) {
+				m.expireEdge("b", "a", time.Hour)
+				m.expireVertex("x", time.Hour)
+			},
+			edges:    map[string]float64{"b": 1, "c": 2},
+			vertices: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			build := func() *MemoryGraphDb {
+				m, _ := NewMemoryGraphDb()
+				m.setEdge("a", "b", floatWeight(1))
+				m.setEdge("a", "c", floatWeight(2))
+				m.setEdge("c", "a", floatWeight(2))
+				tt.expire(m)
+				expireNow(m)
+				return m
+			}
+
+			// expired entries are removed lazily when they are read
+			m := build()
+			if got := m.findEdges("a"); !reflect.DeepEqual(got, tt.edges) {
+				t.Errorf("findEdges = %v, want %v", got, tt.edges)
+			}
+			if got := m.findLabeledEdges([]string{"likes"}, "a"); len(got) != 0 {
+				t.Errorf("findLabeledEdges = %v, want no edges", got)
+			}
+			if info := m.info(); info.Vertices != tt.vertices {
+				t.Errorf("vertices = %d, want %d", info.Vertices, tt.vertices)
+			}
+
+			// or by the sweeper when they are never read again
+			m = build()
+			if got := m.sweepExpired(); got != tt.swept {
+				t.Errorf("sweepExpired = %d, want %d", got, tt.swept)
+			}
+			if m.sweepExpired() != 0 {
+				t.Errorf("second sweepExpired removed more entries")
+			}
+		})
+	}
+}
+
+func TestTTL(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(1))
+	if ttl, ok := m.vertexTTL("a"); !ok || ttl != -1 {
+		t.Errorf("vertexTTL without expiration = %v %v, want -1", ttl, ok)
+	}
+	if _, ok := m.edgeTTL("b", "a"); ok {
+		t.Errorf("edgeTTL of a missing edge should not exist")
+	}
+
+	m.expireVertex("a", time.Hour)
+	m.expireEdge("a", "b", time.Minute)
+	if ttl, ok := m.vertexTTL("a"); !ok || ttl <= 3590 || ttl > 3600 {
+		t.Errorf("vertexTTL = %v %v, want about an hour", ttl, ok)
+	}
+	if ttl, ok := m.edgeTTL("a", "b"); !ok || ttl <= 50 || ttl > 60 {
+		t.Errorf("edgeTTL = %v %v, want about a minute", ttl, ok)
+	}
+
+	expireNow(m)
+	if _, ok := m.vertexTTL("a"); ok {
+		t.Errorf("vertexTTL of an expired vertex should not exist")
+	}
+}
@@ -0,0 +1,114 @@
+package bgraph
+
+import (
+	"errors"
+	"math"
+)
+
+// flowEpsilon is the smallest residual capacity still considered usable
+const flowEpsilon = 1e-12
+
+// flowResult is the maximum flow between two vertices and its minimum cut
+type flowResult struct {
+	Flow float64        `json:"flow"`
+	Cut  []weightedEdge `json:"cut"`
+}
+
+// maxFlow will compute the maximum flow from source to sink using
+// Edmonds-Karp, treating the directed edge weights as capacities (edges with
+// a weight of zero or below carry no flow). The minimum cut is the set of
+// edges leaving the vertices still reachable from the source in the residual
+// graph. nil is returned when either vertex does not exist and an error when
+// an edge has an infinite capacity, which no augmenting path could exhaust.
+// Graphs with integer weights require their capacities to total at most
+// 2^53 - 1 so that every residual capacity and flow is held exactly.
+func (m *MemoryGraphDb) maxFlow(source string, sink string) (*flowResult, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	s, s_ok := m.vertices[source]
+	t, t_ok := m.vertices[sink]
+	if !s_ok || !t_ok {
+		return nil, nil
+	}
+
+	// residual capacities, including the reverse edges needed to cancel flow
+	residual := make(map[int64]map[int64]float64)
+	addResidual := func(a int64, b int64, capacity float64) {
+		r, ok := residual[a]
+		if !ok {
+			r = make(map[int64]float64)
+			residual[a] = r
+		}
+		r[b] += capacity
+	}
+	total := int64(0)
+	for f, vertexEdges := range m.edges {
+		for to, edgeIndex := range vertexEdges {
+			weight := m.edgeWeight(edgeIndex)
+			if math.IsInf(weight, 0) || math.IsNaN(weight) {
+				return nil, errors.New("maxflow requires finite capacities for the edge " + m.r_vertices[f] + " " + m.r_vertices[to])
+			}
+			if weight > 0 && f != to && m.mode == weightInteger {
+				var err error
+				if total, err = addExact(total, m.edgeExact(edgeIndex)); err != nil || total > maxExactFloat {
+					return nil, errors.New("maxflow requires integer capacities that total at most 2^53 - 1")
+				}
+			}
+			if weight > 0 && f != to {
+				addResidual(f, to, weight)
+				addResidual(to, f, 0)
+			}
+		}
+	}
+
+	result := &flowResult{Cut: make([]weightedEdge, 0)}
+	for {
+		// breadth first search for the shortest augmenting path
+		parents := map[int64]int64{s: s}
+		queue := []int64{s}
+		for len(queue) > 0 && !containsVertex(parents, t) {
+			u := queue[0]
+			queue = queue[1:]
+			for v, capacity := range residual[u] {
+				if capacity <= flowEpsilon || containsVertex(parents, v) {
+					continue
+				}
+				parents[v] = u
+				queue = append(queue, v)
+			}
+		}
+
+		if !containsVertex(parents, t) {
+			// parents now holds the source side of the minimum cut
+			for f := range parents {
+				for to, edgeIndex := range m.edges[f] {
+					weight := m.edgeWeight(edgeIndex)
+					if weight > 0 && !containsVertex(parents, to) {
+						result.Cut = append(result.Cut, weightedEdge{From: m.r_vertices[f], To: m.r_vertices[to], Weight: weight})
+					}
+				}
+			}
+			return result, nil
+		}
+
+		bottleneck := float64(-1)
+		for v := t; v != s; v = parents[v] {
+			if c := residual[parents[v]][v]; bottleneck < 0 || c < bottleneck {
+				bottleneck = c
+			}
+		}
+		for v := t; v != s; v = parents[v] {
+			residual[parents[v]][v] -= bottleneck
+			residual[v][parents[v]] += bottleneck
+		}
+		result.Flow += bottleneck
+	}
+}
+
+func containsVertex(set map[int64]int64, vertex int64) bool {
+	_, ok := set[vertex]
+	return ok
+}
@@ -0,0 +1,154 @@
+package bgraph
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestMaxFlow(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(m *MemoryGraphDb)
+		flow  float64
+		cut   []string // cut edges as "from to"
+		err   bool
+	}{
+		{
+			name: "single edge",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "t", floatWeight(4))
+			},
+			flow: 4,
+			cut:  []string{"s t"},
+		},
+		{
+			name: "bottleneck within a path",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "a", floatWeight(5))
+				m.setEdge("a", "t", floatWeight(2))
+			},
+			flow: 2,
+			cut:  []string{"a t"},
+		},
+		{
+			name: "flow cancelled along a reverse edge",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "a", floatWeight(3))
+				m.setEdge("s", "b", floatWeight(2))
+				m.setEdge("a", "b", floatWeight(1))
+				m.setEdge("a", "t", floatWeight(2))
+				m.setEdge("b", "t", floatWeight(3))
+			},
+			flow: 5,
+			cut:  []string{"s a", "s b"},
+		},
+		{
+			name: "edges at or below zero carry no flow",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "a", floatWeight(0))
+				m.setEdge("s", "b", floatWeight(-1))
+				m.setEdge("a", "t", floatWeight(1))
+				m.setEdge("b", "t", floatWeight(1))
+				m.setEdge("s", "s", floatWeight(1))
+			},
+			flow: 0,
+			cut:  []string{},
+		},
+		{
+			name: "no path",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "a", floatWeight(1))
+				m.setEdge("t", "a", floatWeight(1))
+			},
+			flow: 0,
+			cut:  []string{},
+		},
+		{
+			name: "infinite capacity",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "t", floatWeight(math.Inf(1)))
+			},
+			err: true,
+		},
+		{
+			name: "infinite capacity outside any path",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("s", "t", floatWeight(1))
+				m.setEdge("x", "y", floatWeight(math.Inf(-1)))
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.build(m)
+
+			done := make(chan struct{})
+			var result *flowResult
+			var err error
+			go func() {
+				result, err = m.maxFlow("s", "t")
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("maxFlow did not return")
+			}
+
+			if (err != nil) != tt.err {
+				t.Fatalf("maxFlow error = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			cut := make([]string, 0)
+			total := float64(0)
+			for _, e := range result.Cut {
+				cut = append(cut, e.From+" "+e.To)
+				total += e.Weight
+			}
+			sort.Strings(cut)
+			if result.Flow != tt.flow || len(cut) != len(tt.cut) {
+				t.Fatalf("maxFlow = %v %v, want %v %v", result.Flow, cut, tt.flow, tt.cut)
+			}
+			for i := range cut {
+				if cut[i] != tt.cut[i] {
+					t.Errorf("cut = %v, want %v", cut, tt.cut)
+				}
+			}
+			if total != result.Flow {
+				t.Errorf("cut capacity %v differs from the flow %v", total, result.Flow)
+			}
+		})
+	}
+}
+
+func TestMaxFlowMissingVertex(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("s", "a", floatWeight(1))
+	if result, err := m.maxFlow("s", "t"); result != nil || err != nil {
+		t.Errorf("maxFlow to a missing vertex = %v %v, want nil", result, err)
+	}
+}
+
+func TestIntegerMaxFlow(t *testing.T) {
+	m := integerGraph(t)
+	m.setEdge("s", "a", mustWeight(t, "4503599627370496"))
+	m.setEdge("a", "t", mustWeight(t, "4503599627370494"))
+	m.setEdge("s", "t", mustWeight(t, "1"))
+	result, err := m.maxFlow("s", "t")
+	if err != nil || result.Flow != 4503599627370495 {
+		t.Fatalf("maxFlow = %+v %v, want a flow of 2^52 - 1", result, err)
+	}
+
+	// beyond 2^53 - 1 the residual capacities would no longer be exact
+	m.setEdge("t", "s", mustWeight(t, "1"))
+	if _, err := m.maxFlow("s", "t"); err == nil {
+		t.Errorf("maxFlow with capacities totaling 2^53 should fail")
+	}
+}
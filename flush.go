@@ -0,0 +1,59 @@
+package bgraph
+
+// The flush operations swap in empty maps while holding the lock so that they
+// return immediately regardless of the size of the graph. The previous maps
+// are no longer referenced and are reclaimed by the garbage collector in the
+// background.
+
+// flushGraph will remove every vertex and edge while keeping the settings
+func (m *MemoryGraphDb) flushGraph() {
+	m.Lock()
+	defer m.Unlock()
+
+	mem, _ := NewMemoryGraphDb()
+	mem.negative = m.negative
+	mem.prune = m.prune
+	mem.epsilon = m.epsilon
+	mem.mode = m.mode
+	mem.halfLife = m.halfLife
+	mem.historyLimit = m.historyLimit
+	mem.historyAge = m.historyAge
+	mem.windowSpan = m.windowSpan
+	mem.windowBuckets = m.windowBuckets
+	for key, idx := range m.indexes {
+		mem.indexes[key] = newPropertyIndex(idx.sorted)
+	}
+	m.replace(mem)
+}
+
+// flushEdges will remove every edge while keeping the vertices and their weights
+func (m *MemoryGraphDb) flushEdges() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.edges = make(map[int64]map[int64]int64)
+	m.labelEdges = make(map[string]edgeMap)
+	m.inbound = make(map[int64]map[int64]int)
+	m.edgeProps = make(map[int64]properties)
+	m.edgeWeights = make(map[int64]float64)
+	m.edgeInts = make(map[int64]int64)
+	m.edgeExpires = make(map[int64]int64)
+	m.edgeTimes = make(map[int64]int64)
+	m.edgeHistory = make(map[int64][]edgeVersion)
+	m.removedHistory = make(map[string]map[historyKey][]edgeVersion)
+	m.removedOrder = nil
+	m.edgeWindows = make(map[int64]*windowCounter)
+	m.freeEdges = nil
+	m.totalEdges = 0
+}
+
+// flushVertexWeights will remove the weight of every vertex while keeping the
+// vertices and their edges
+func (m *MemoryGraphDb) flushVertexWeights() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.vertexWeights = make(map[int64]float64)
+	m.vertexInts = make(map[int64]int64)
+	m.vertexTimes = make(map[int64]int64)
+}
@@ -0,0 +1,99 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// flushGraphFixture returns a graph with weights, labels, expirations and settings
+func flushGraphFixture() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	m.setNegative(negativeClamp)
+	m.setHistory(3, 0)
+	m.setWindow(time.Hour, 4)
+	m.createIndex("price", true)
+	m.setEdge("a", "b", floatWeight(1))
+	m.setLabeledEdge("likes", "a", "c", floatWeight(2))
+	m.incrWindowEdge("a", "d", floatWeight(3))
+	m.expireEdge("a", "b", time.Hour)
+	m.setVertex("a", floatWeight(4))
+	m.setVertexProperties("c", properties{"price": float64(5)})
+	return m
+}
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		flush    func(m *MemoryGraphDb)
+		info     graphInfo
+		edges    map[string]float64
+		weight   float64
+		indexed  []string
+		vertices []string
+	}{
+		{
+			name:  "graph",
+			flush: (*MemoryGraphDb).flushGraph,
+			info:  graphInfo{HistoryLimit: 3, Negative: "clamp", Weights: "float"},
+		},
+		{
+			name:     "edges",
+			flush:    (*MemoryGraphDb).flushEdges,
+			info:     graphInfo{Vertices: 4, HistoryLimit: 3, Negative: "clamp", Weights: "float"},
+			weight:   4,
+			indexed:  []string{"c"},
+			vertices: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:     "vertex weights",
+			flush:    (*MemoryGraphDb).flushVertexWeights,
+			info:     graphInfo{Vertices: 4, Edges: 3, ExpiringEdges: 1, WindowedEdges: 1, Labels: 1, HistoryLimit: 3, Negative: "clamp", Weights: "float"},
+			edges:    map[string]float64{"b": 1, "d": 3},
+			indexed:  []string{"c"},
+			vertices: []string{"a", "b", "c", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := flushGraphFixture()
+			tt.flush(m)
+
+			if info := m.info(); info != tt.info {
+				t.Errorf("info = %+v, want %+v", info, tt.info)
+			}
+			if got := m.findEdges("a"); !reflect.DeepEqual(got, tt.edges) {
+				t.Errorf("findEdges = %v, want %v", got, tt.edges)
+			}
+			if got, _ := m.getVertex("a"); got != tt.weight {
+				t.Errorf("getVertex = %v, want %v", got, tt.weight)
+			}
+			page, err := m.rangeIndexed("price", float64(0), float64(10), 0, 10)
+			if err != nil || !sameNames(page.Vertices, tt.indexed) {
+				t.Errorf("rangeIndexed = %+v %v, want %v", page, err, tt.indexed)
+			}
+			if page := m.prefixVertices("", 0, 10); !sameNames(page.Vertices, tt.vertices) {
+				t.Errorf("prefixVertices = %v, want %v", page.Vertices, tt.vertices)
+			}
+			if span, buckets := m.getWindow(); span != time.Hour || buckets != 4 {
+				t.Errorf("window after flush = %v %d, want the window kept", span, buckets)
+			}
+
+			// the flushed graph keeps working with reclaimed indices
+			m.setEdge("a", "e", floatWeight(6))
+			m.setLabeledEdge("likes", "e", "a", floatWeight(7))
+			if got := m.findEdges("a")["e"]; got != 6 {
+				t.Errorf("edge written after flush = %v, want 6", got)
+			}
+			if got := m.edgeLabels("e", "a"); !reflect.DeepEqual(got, map[string]float64{"likes": 7}) {
+				t.Errorf("labels written after flush = %v", got)
+			}
+		})
+	}
+}
+
+// sameNames reports whether both lists hold the same names, treating nil as empty
+func sameNames(a []string, b []string) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
@@ -0,0 +1,387 @@
+package bgraph
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// defaultGraph is the graph used by commands that are not run through GRAPH
+const defaultGraph = "default"
+
+// snapshotExt is the file extension of persisted graph snapshots
+const snapshotExt = ".bgraph"
+
+// errNoDir is returned by SAVE when no data directory is configured
+var errNoDir = errors.New("no data directory is configured to save graphs to (dir in bgraph.conf or -dir)")
+
+// graphNames restricts graph names to those that are safe to use as file names
+var graphNames = regexp.MustCompile(`^[A-Za-z0-9_\-:][A-Za-z0-9_\-:.]*$`)
+
+// readCommands are the graph commands that never write, run against a graph
+// that does not exist they see an empty graph rather than creating it
+var readCommands = map[string]bool{
+	"*e": true, "&e": true, "*e@": true, "&e@": true, "*ep": true, "eagg": true, "*en": true,
+	"core": true, "kcore": true, "cycle": true, "topo": true, "mst": true, "maxflow": true,
+	"subgraph": true, "ego": true, "ttl": true, "ttl>": true, "stats": true, "labels": true,
+	"vget": true, "eget": true, "vprefix": true, "vbetween": true, "vfind": true, "vrange": true,
+	"query": true,
+}
+
+// settingCommands are the graph commands that return a setting of the graph
+// when run without parameters and change it otherwise
+var settingCommands = map[string]bool{
+	"decay": true, "history": true, "window": true, "negative": true,
+	"epsilon": true, "weights": true, "vindex": true,
+}
+
+// graphHandler is a command handler that runs against a single graph
+type graphHandler func(db DB, d [][]byte, client server.ProtocolClient) error
+
+// graphCommand is a registered graph command that can be dispatched by name
+type graphCommand struct {
+	server.Command
+	handler graphHandler
+}
+
+// register will register a graph command against the default graph and make
+// it available to the GRAPH command for every other graph
+func (b *BGraphBackend) register(cmd server.Command, handler graphHandler) {
+	b.commands[strings.ToLower(cmd.Name)] = graphCommand{cmd, handler}
+	b.app.RegisterCommand(cmd, func(data interface{}, client server.ProtocolClient) error {
+		d, _ := data.([][]byte)
+		defer b.lockCommand(cmd.Name)()
+		err := handler(b.graph(defaultGraph), d, client)
+		if err != nil && cmd.FireForget {
+			// nothing replies to fire and forget commands, report the error instead
+			b.reportError(cmd.Name, err)
+			return nil
+		}
+		return err
+	})
+}
+
+// reportError will report an error that cannot be returned to the client as
+// an error event of the server
+func (b *BGraphBackend) reportError(name string, err error) {
+	if b.app == nil || b.app.Events == nil {
+		return
+	}
+	event := server.Event{Level: "error", Message: name + " failed:", Err: err}
+	go func() { b.app.Events <- event }()
+}
+
+// readOnly returns whether the graph command and its parameters never write,
+// commands that run another command are as read only as the command they run
+func readOnly(d [][]byte) bool {
+	cmd := strings.ToLower(string(d[0]))
+	switch cmd {
+	case "label", "where", "like":
+		return len(d) > 2 && readOnly(d[2:])
+	}
+	return readCommands[cmd] || (settingCommands[cmd] && len(d) == 1)
+}
+
+// lockCommand will hold the exec lock while the command runs, exclusively for
+// scripts so that no other command observes or interleaves with their writes.
+// Saves, drops and the background sweep hold it too. The returned function
+// releases the lock.
+func (b *BGraphBackend) lockCommand(name string) func() {
+	switch strings.ToLower(name) {
+	case "eval", "evalsha":
+		b.exec.Lock()
+		return b.exec.Unlock
+	}
+	b.exec.RLock()
+	return b.exec.RUnlock
+}
+
+// lookupGraph will return the graph with the given name if it exists
+func (b *BGraphBackend) lookupGraph(name string) (DB, bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	db, ok := b.graphs[name]
+	return db, ok
+}
+
+// graph will return the graph with the given name, creating it if necessary
+func (b *BGraphBackend) graph(name string) DB {
+	if db, ok := b.lookupGraph(name); ok {
+		return db
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	db, ok := b.graphs[name]
+	if !ok {
+		db = b.newGraph()
+		b.graphs[name] = db
+	}
+	return db
+}
+
+// newGraph will create an empty graph with the configured settings
+func (b *BGraphBackend) newGraph() DB {
+	mem, _ := NewMemoryGraphDb()
+	mem.negative = b.negative
+	return mem
+}
+
+// eachGraph will call fn for every graph currently held by the backend
+func (b *BGraphBackend) eachGraph(fn func(name string, db DB)) {
+	b.RLock()
+	graphs := make(map[string]DB, len(b.graphs))
+	for name, db := range b.graphs {
+		graphs[name] = db
+	}
+	b.RUnlock()
+
+	for name, db := range graphs {
+		fn(name, db)
+	}
+}
+
+// dispatch will run the named graph command against the graph, fire and
+// forget commands are acknowledged, or their error returned, as the caller
+// expects a reply
+func (b *BGraphBackend) dispatch(db DB, d [][]byte, client server.ProtocolClient) error {
+	cmd, ok := b.commands[strings.ToLower(string(d[0]))]
+	if !ok {
+		client.WriteError(errors.New("unknown graph command " + string(d[0])))
+		client.Flush()
+		return nil
+	}
+
+	err := cmd.handler(db, d[1:], client)
+	if cmd.FireForget {
+		if err != nil {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+		client.WriteString("OK")
+		client.Flush()
+	}
+	return err
+}
+
+// validateGraphName returns an error when the name cannot be used for a graph
+func validateGraphName(name string) error {
+	if !graphNames.MatchString(name) {
+		return errors.New("invalid graph name " + name + " (letters, digits and _-:. only)")
+	}
+	return nil
+}
+
+// Graph will run a graph command against the named graph
+func (b *BGraphBackend) Graph(data interface{}, client server.ProtocolClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 2 {
+		client.WriteError(errors.New("graph takes at least 2 parameters (graph name command [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	name := string(d[0])
+	if err := validateGraphName(name); err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	defer b.lockCommand(string(d[1]))()
+
+	// reads see an empty graph so that only writes create graphs
+	db, ok := b.lookupGraph(name)
+	if !ok {
+		if readOnly(d[1:]) {
+			db = b.newGraph()
+		} else {
+			db = b.graph(name)
+		}
+	}
+	return b.dispatch(db, d[1:], client)
+}
+
+// Graphs will return the names of every graph along with their statistics
+func (b *BGraphBackend) Graphs(data interface{}, client server.ProtocolClient) error {
+	defer b.lockCommand("graphs")()
+	results := make(map[string]graphInfo)
+	b.eachGraph(func(name string, db DB) {
+		results[name] = db.info()
+	})
+
+	client.WriteJson(results)
+	client.Flush()
+	return nil
+}
+
+// DropGraph will remove the named graphs along with their snapshots, the
+// default graph is emptied instead as it always exists
+func (b *BGraphBackend) DropGraph(data interface{}, client server.ProtocolClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 1 {
+		client.WriteError(errors.New("dropgraph takes at least 1 parameter (dropgraph name [name ...])"))
+		client.Flush()
+		return nil
+	}
+
+	defer b.lockCommand("dropgraph")()
+	for _, k := range d {
+		name := string(k)
+		if err := validateGraphName(name); err != nil {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+
+		b.Lock()
+		delete(b.graphs, name)
+		if name == defaultGraph {
+			b.graphs[name] = b.newGraph()
+		}
+		b.Unlock()
+
+		if !b.persistent() {
+			continue
+		}
+		if err := os.Remove(b.snapshotPath(name)); err != nil && !os.IsNotExist(err) {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+	}
+
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// persistent returns whether graphs are saved to and loaded from a data
+// directory, which is only the case once one is configured
+func (b *BGraphBackend) persistent() bool {
+	return b.config.Dir != ""
+}
+
+// snapshotPath returns the file the named graph is persisted to
+func (b *BGraphBackend) snapshotPath(name string) string {
+	return filepath.Join(b.config.Dir, name+snapshotExt)
+}
+
+// saveGraph will persist the graph to its snapshot file, the snapshot is
+// written to a temporary file first so that a failed save keeps the last one
+func (b *BGraphBackend) saveGraph(name string, db DB) error {
+	data, err := db.snapshot()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(b.config.Dir, 0755); err != nil {
+		return err
+	}
+	path := b.snapshotPath(name)
+	if err = ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// saveGraphs will persist every graph to its snapshot file, if any
+func (b *BGraphBackend) saveGraphs() error {
+	if !b.persistent() {
+		return nil
+	}
+
+	var err error
+	b.eachGraph(func(name string, db DB) {
+		if err == nil {
+			err = b.saveGraph(name, db)
+		}
+	})
+	return err
+}
+
+// loadGraphs will restore every graph snapshot found in the data directory, if any
+func (b *BGraphBackend) loadGraphs() error {
+	if !b.persistent() {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(b.config.Dir, "*"+snapshotExt))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), snapshotExt)
+		if validateGraphName(name) != nil {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err = b.graph(name).restore(data); err != nil {
+			return errors.New("unable to load graph " + name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Save will persist every graph, or only the named graphs, to the data directory
+func (b *BGraphBackend) Save(data interface{}, client server.ProtocolClient) error {
+	d, _ := data.([][]byte)
+	names := make([]string, len(d))
+	for i, k := range d {
+		names[i] = string(k)
+		if err := validateGraphName(names[i]); err != nil {
+			client.WriteError(err)
+			client.Flush()
+			return nil
+		}
+	}
+	if !b.persistent() {
+		client.WriteError(errNoDir)
+		client.Flush()
+		return nil
+	}
+
+	defer b.lockCommand("save")()
+	var err error
+	if len(names) == 0 {
+		err = b.saveGraphs()
+	} else {
+		for _, name := range names {
+			db, ok := b.lookupGraph(name)
+			if !ok {
+				err = errors.New("unknown graph " + name)
+				break
+			}
+			if err = b.saveGraph(name, db); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		client.WriteError(err)
+	} else {
+		client.WriteString("OK")
+	}
+	client.Flush()
+	return nil
+}
+
+// Stats will return the statistics of a single graph
+func (b *BGraphBackend) Stats(db DB, d [][]byte, client server.ProtocolClient) error {
+	client.WriteJson(db.info())
+	client.Flush()
+	return nil
+}
@@ -0,0 +1,67 @@
+package bgraph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// persistenceBackend returns a backend without a server saving graphs to the directory
+func persistenceBackend(dir string) *BGraphBackend {
+	return &BGraphBackend{config: Config{Dir: dir}, graphs: make(map[string]DB)}
+}
+
+func TestGraphPersistence(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "bgraph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	tests := []struct {
+		name  string
+		dir   string
+		files []string
+	}{
+		{"persisted", filepath.Join(tmp, "data"), []string{"default.bgraph", "follows.bgraph"}},
+		{"in memory only", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cwd, _ := os.Getwd()
+			before, _ := filepath.Glob(filepath.Join(cwd, "*"+snapshotExt))
+
+			b := persistenceBackend(tt.dir)
+			b.graph(defaultGraph).setEdge("a", "b", floatWeight(1))
+			b.graph("follows").setEdge("a", "c", floatWeight(2))
+			if err := b.saveGraphs(); err != nil {
+				t.Fatalf("saveGraphs: %v", err)
+			}
+
+			after, _ := filepath.Glob(filepath.Join(cwd, "*"+snapshotExt))
+			if len(after) != len(before) {
+				t.Errorf("snapshots written to the working directory: %v", after)
+			}
+			if tt.dir != "" {
+				paths, _ := filepath.Glob(filepath.Join(tt.dir, "*"+snapshotExt))
+				if len(paths) != len(tt.files) {
+					t.Errorf("snapshots = %v, want %v", paths, tt.files)
+				}
+			}
+
+			restored := persistenceBackend(tt.dir)
+			if err := restored.loadGraphs(); err != nil {
+				t.Fatalf("loadGraphs: %v", err)
+			}
+			_, ok := restored.lookupGraph("follows")
+			if ok != (tt.dir != "") {
+				t.Errorf("graph follows loaded = %v, want %v", ok, tt.dir != "")
+			}
+			if ok && restored.graph("follows").findEdges("a")["c"] != 2 {
+				t.Errorf("loaded graph follows = %v", restored.graph("follows").findEdges("a"))
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package bgraph
+
+import (
+	"sort"
+	"time"
+)
+
+// edgeVersion is the weight an edge was written with at a point in time, or
+// the time it was removed
+type edgeVersion struct {
+	at      int64   // unix nanoseconds of the write
+	weight  float64 // weight stored by the write (before any decay)
+	removed bool    // the edge was removed at this time (tombstone)
+}
+
+// historyKey names an edge by its label and vertices so that the history of
+// a removed edge outlives its index, which is reused by other edges
+type historyKey struct {
+	label string
+	from  string
+	to    string
+}
+
+// removedEdge is a removed edge in the order its history is aged out
+type removedEdge struct {
+	key historyKey
+	at  int64
+}
+
+// timeSpec selects either the weights as of a single point in time or, when
+// window is set, the change in weights between from and to
+type timeSpec struct {
+	from   int64
+	to     int64
+	window bool
+}
+
+// trimVersions will drop the versions outside of the retention policy
+func (m *MemoryGraphDb) trimVersions(versions []edgeVersion, now int64) []edgeVersion {
+	if len(versions) > m.historyLimit {
+		versions = versions[len(versions)-m.historyLimit:]
+	}
+
+	// keep the newest version older than the cutoff as it is still in effect
+	if m.historyAge > 0 {
+		cutoff := now - int64(m.historyAge)
+		drop := 0
+		for drop+1 < len(versions) && versions[drop+1].at <= cutoff {
+			drop++
+		}
+		versions = versions[drop:]
+	}
+	return versions
+}
+
+// recordEdge will append the current edge weight to its history, trimming the
+// versions outside of the retention policy
+func (m *MemoryGraphDb) recordEdge(edgeIndex int64, now int64) {
+	versions := append(m.edgeHistory[edgeIndex], edgeVersion{now, m.edgeWeights[edgeIndex], false})
+	m.edgeHistory[edgeIndex] = m.trimVersions(versions, now)
+}
+
+// retireHistory will keep the history of an edge being removed under its
+// names, ending with a tombstone, until the retention policy ages it out
+func (m *MemoryGraphDb) retireHistory(label string, f int64, t int64, edgeIndex int64) {
+	versions, ok := m.edgeHistory[edgeIndex]
+	delete(m.edgeHistory, edgeIndex)
+	if !ok || m.historyLimit == 0 {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	key := historyKey{label, m.r_vertices[f], m.r_vertices[t]}
+	removed, ok := m.removedHistory[key.from]
+	if !ok {
+		removed = make(map[historyKey][]edgeVersion)
+		m.removedHistory[key.from] = removed
+	}
+	removed[key] = m.trimVersions(append(versions, edgeVersion{now, 0, true}), now)
+	if m.historyAge > 0 {
+		m.removedOrder = append(m.removedOrder, removedEdge{key, now})
+	}
+}
+
+// reviveHistory will continue the history of a removed edge once an edge with
+// the same label and vertices is created again
+func (m *MemoryGraphDb) reviveHistory(label string, f int64, t int64, edgeIndex int64) {
+	key := historyKey{label, m.r_vertices[f], m.r_vertices[t]}
+	removed := m.removedHistory[key.from]
+	if versions, ok := removed[key]; ok {
+		m.edgeHistory[edgeIndex] = versions
+		m.forgetRemoved(key)
+	}
+}
+
+// forgetRemoved will drop the history kept for a removed edge
+func (m *MemoryGraphDb) forgetRemoved(key historyKey) {
+	removed := m.removedHistory[key.from]
+	delete(removed, key)
+	if len(removed) == 0 {
+		delete(m.removedHistory, key.from)
+	}
+}
+
+// expireRemovedHistory will drop the history of removed edges whose tombstone
+// is older than the history age, as none of their versions are in effect
+func (m *MemoryGraphDb) expireRemovedHistory(now int64) {
+	if m.historyAge <= 0 {
+		return
+	}
+
+	cutoff := now - int64(m.historyAge)
+	drop := 0
+	for ; drop < len(m.removedOrder) && m.removedOrder[drop].at <= cutoff; drop++ {
+		entry := m.removedOrder[drop]
+		versions := m.removedHistory[entry.key.from][entry.key]
+		if n := len(versions); n > 0 && versions[n-1].at == entry.at {
+			m.forgetRemoved(entry.key)
+		}
+	}
+	m.removedOrder = m.removedOrder[drop:]
+}
+
+// orderRemovedHistory will rebuild the order in which the history of removed
+// edges is aged out
+func (m *MemoryGraphDb) orderRemovedHistory() {
+	m.removedOrder = nil
+	if m.historyAge <= 0 {
+		return
+	}
+	for _, removed := range m.removedHistory {
+		for key, versions := range removed {
+			m.removedOrder = append(m.removedOrder, removedEdge{key, versions[len(versions)-1].at})
+		}
+	}
+	sort.Slice(m.removedOrder, func(i, j int) bool { return m.removedOrder[i].at < m.removedOrder[j].at })
+}
+
+// weightAsOf will return the weight of the versions as they were at the given
+// time along with whether the edge existed by then
+func (m *MemoryGraphDb) weightAsOf(versions []edgeVersion, at int64) (float64, bool) {
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].at > at })
+	if i == 0 || versions[i-1].removed {
+		return 0, false
+	}
+
+	version := versions[i-1]
+	return version.weight * m.decayFactor(version.at, at), true
+}
+
+// weightAt will return the weight of the versions according to the time spec:
+// the weight as of spec.from, or the change in weight across the window for
+// edges that were written or removed within it
+func (m *MemoryGraphDb) weightAt(versions []edgeVersion, spec timeSpec) (float64, bool) {
+	if !spec.window {
+		return m.weightAsOf(versions, spec.from)
+	}
+
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].at > spec.from })
+	if i == len(versions) || versions[i].at > spec.to {
+		return 0, false
+	}
+
+	end, _ := m.weightAsOf(versions, spec.to)
+	start, _ := m.weightAsOf(versions, spec.from)
+	return end - start, true
+}
+
+// exactAt reports whether the versions in effect at the times of the spec
+// hold their weights exactly, the history keeps the weights as floats so
+// integer weights beyond 2^53 - 1 are not
+func (m *MemoryGraphDb) exactAt(versions []edgeVersion, spec timeSpec) bool {
+	for _, at := range []int64{spec.from, spec.to} {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].at > at })
+		if i > 0 && !m.exactFloat(versions[i-1].weight) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MemoryGraphDb) findEdgesAt(vertex string, spec timeSpec) (map[string]float64, error) {
+	return m.findLabeledEdgesAt([]string{""}, vertex, spec)
+}
+
+// findLabeledEdgesAt will return the labeled edges from the vertex according
+// to the time spec, including edges that were removed since, summing the
+// weights across labels. Graphs with integer weights return errInexactWeight
+// rather than a weight beyond 2^53 - 1.
+func (m *MemoryGraphDb) findLabeledEdgesAt(labels []string, vertex string, spec timeSpec) (map[string]float64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	result, err := m.edgesAt(labels, vertex, spec)
+	if len(result) == 0 || err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// edgesAt will return the labeled edges from the vertex according to the time spec
+func (m *MemoryGraphDb) edgesAt(labels []string, vertex string, spec timeSpec) (map[string]float64, error) {
+	selected := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		selected[label] = true
+	}
+
+	result := make(map[string]float64)
+	exact := true
+	add := func(versions []edgeVersion, to string) {
+		if weight, ok := m.weightAt(versions, spec); ok {
+			result[to] += weight
+			exact = exact && m.exactAt(versions, spec) && m.exactFloat(result[to])
+		}
+	}
+	if f, ok := m.liveVertex(vertex); ok {
+		m.purgeEdges(f)
+		m.eachAdjacency(func(label string, adj edgeMap) {
+			if !selected[label] && !selected["*"] {
+				return
+			}
+			for t, edgeIndex := range adj[f] {
+				add(m.edgeHistory[edgeIndex], m.r_vertices[t])
+			}
+		})
+	}
+
+	for key, versions := range m.removedHistory[vertex] {
+		if !selected[key.label] && !selected["*"] {
+			continue
+		}
+		add(versions, key.to)
+	}
+	if !exact {
+		return nil, errInexactWeight
+	}
+	return result, nil
+}
+
+func (m *MemoryGraphDb) sumIntersectEdgesAt(vertices []string, spec timeSpec) (map[string]float64, error) {
+	return m.sumIntersectLabeledEdgesAt([]string{""}, vertices, spec)
+}
+
+// sumIntersectLabeledEdgesAt will return the intersection of the labeled
+// edges from the vertices with the sum of their weights according to the time spec
+func (m *MemoryGraphDb) sumIntersectLabeledEdgesAt(labels []string, vertices []string, spec timeSpec) (map[string]float64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	results := make(map[string]float64)
+	for i, k := range vertices {
+		edges, err := m.edgesAt(labels, k, spec)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			results = edges
+			continue
+		}
+		for to, weight := range results {
+			if other, ok := edges[to]; ok {
+				results[to] = weight + other
+				if !m.exactFloat(results[to]) {
+					return nil, errInexactWeight
+				}
+			} else {
+				delete(results, to)
+			}
+		}
+	}
+	return results, nil
+}
+
+// setHistory will change the retention policy of the edge history. A limit of
+// zero disables the history and discards every version recorded so far.
+func (m *MemoryGraphDb) setHistory(limit int, age time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	if limit < 0 {
+		limit = 0
+	}
+	if age < 0 {
+		age = 0
+	}
+	m.historyLimit = limit
+	m.historyAge = age
+
+	if limit == 0 {
+		m.edgeHistory = make(map[int64][]edgeVersion)
+		m.removedHistory = make(map[string]map[historyKey][]edgeVersion)
+		m.removedOrder = nil
+		return
+	}
+	for edgeIndex, versions := range m.edgeHistory {
+		if len(versions) > limit {
+			m.edgeHistory[edgeIndex] = versions[len(versions)-limit:]
+		}
+	}
+	for _, removed := range m.removedHistory {
+		for key, versions := range removed {
+			if len(versions) > limit {
+				removed[key] = versions[len(versions)-limit:]
+			}
+		}
+	}
+	m.orderRemovedHistory()
+	m.expireRemovedHistory(time.Now().UnixNano())
+}
+
+// getHistory returns the retention policy of the edge history
+func (m *MemoryGraphDb) getHistory() (int, time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.historyLimit, m.historyAge
+}
@@ -0,0 +1,213 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWeightAt(t *testing.T) {
+	versions := []edgeVersion{
+		{at: 10, weight: 1},
+		{at: 20, weight: 3},
+		{at: 30, removed: true},
+		{at: 40, weight: 7},
+	}
+	tests := []struct {
+		name   string
+		spec   timeSpec
+		weight float64
+		ok     bool
+	}{
+		{"before the first version", timeSpec{from: 5, to: 5}, 0, false},
+		{"at the first version", timeSpec{from: 10, to: 10}, 1, true},
+		{"between versions", timeSpec{from: 15, to: 15}, 1, true},
+		{"at a later version", timeSpec{from: 20, to: 20}, 3, true},
+		{"while removed", timeSpec{from: 35, to: 35}, 0, false},
+		{"after being written again", timeSpec{from: 50, to: 50}, 7, true},
+		{"window over a change", timeSpec{10, 25, true}, 2, true},
+		{"window from before the edge", timeSpec{0, 15, true}, 1, true},
+		{"window over the removal", timeSpec{25, 35, true}, -3, true},
+		{"window without a change", timeSpec{11, 19, true}, 0, false},
+		{"window ending at a change", timeSpec{15, 20, true}, 2, true},
+		{"window starting at a change", timeSpec{20, 25, true}, 0, false},
+	}
+
+	m, _ := NewMemoryGraphDb()
+	for _, tt := range tests {
+		weight, ok := m.weightAt(versions, tt.spec)
+		if weight != tt.weight || ok != tt.ok {
+			t.Errorf("%s: weightAt(%+v) = %v %v, want %v %v", tt.name, tt.spec, weight, ok, tt.weight, tt.ok)
+		}
+	}
+}
+
+func TestParseTimeSpec(t *testing.T) {
+	tests := []struct {
+		text string
+		want timeSpec
+		err  bool
+	}{
+		{"10", timeSpec{from: 10e9, to: 10e9}, false},
+		{"1.5", timeSpec{from: 1.5e9, to: 1.5e9}, false},
+		{"10:20", timeSpec{10e9, 20e9, true}, false},
+		{"10:10", timeSpec{10e9, 10e9, true}, false},
+		{"20:10", timeSpec{}, true},
+		{"abc", timeSpec{}, true},
+		{"10:", timeSpec{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeSpec(tt.text)
+		if (err != nil) != tt.err {
+			t.Errorf("parseTimeSpec(%q) error = %v, want error %v", tt.text, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTimeSpec(%q) = %+v, want %+v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// versionTimes returns the write times recorded for the edge
+func versionTimes(m *MemoryGraphDb, label string, from string, to string) []int64 {
+	edgeIndex, _ := m.existingEdge(label, from, to)
+	times := make([]int64, 0)
+	for _, version := range m.edgeHistory[edgeIndex] {
+		times = append(times, version.at)
+	}
+	return times
+}
+
+func TestFindEdgesAt(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setHistory(10, 0)
+	writes := []func(){
+		func() { m.setEdge("a", "b", floatWeight(1)) },
+		func() { m.incrEdge("a", "b", floatWeight(2)) },
+		func() { m.setEdge("c", "b", floatWeight(5)) },
+		func() { m.setLabeledEdge("likes", "a", "b", floatWeight(4)) },
+		func() { m.setEdge("a", "d", floatWeight(6)) },
+	}
+	for _, write := range writes {
+		// every write is recorded at a distinct time
+		time.Sleep(time.Microsecond)
+		write()
+	}
+	ab, cb, ad := versionTimes(m, "", "a", "b"), versionTimes(m, "", "c", "b"), versionTimes(m, "", "a", "d")
+	likes := versionTimes(m, "likes", "a", "b")
+	time.Sleep(time.Microsecond)
+	m.removeEdge("", m.vertices["a"], m.vertices["d"])
+	now := time.Now().UnixNano()
+
+	tests := []struct {
+		name   string
+		labels []string
+		spec   timeSpec
+		want   map[string]float64
+	}{
+		{"first write", []string{""}, timeSpec{from: ab[0], to: ab[0]}, map[string]float64{"b": 1}},
+		{"second write", []string{""}, timeSpec{from: ab[1], to: ab[1]}, map[string]float64{"b": 3}},
+		{"before any write", []string{""}, timeSpec{from: ab[0] - 1, to: ab[0] - 1}, nil},
+		{"removed edge", []string{""}, timeSpec{from: ad[0], to: ad[0]}, map[string]float64{"b": 3, "d": 6}},
+		{"after the removal", []string{""}, timeSpec{from: now, to: now}, map[string]float64{"b": 3}},
+		{"window", []string{""}, timeSpec{ab[0], ab[1], true}, map[string]float64{"b": 2}},
+		{"labeled", []string{"likes"}, timeSpec{from: likes[0], to: likes[0]}, map[string]float64{"b": 4}},
+		{"every label", []string{"*"}, timeSpec{from: now, to: now}, map[string]float64{"b": 7}},
+	}
+
+	for _, tt := range tests {
+		if got, err := m.findLabeledEdgesAt(tt.labels, "a", tt.spec); err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: findLabeledEdgesAt = %v %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if got, _ := m.sumIntersectEdgesAt([]string{"a", "c"}, timeSpec{from: cb[0], to: cb[0]}); !reflect.DeepEqual(got, map[string]float64{"b": 8}) {
+		t.Errorf("sumIntersectEdgesAt = %v, want b 8", got)
+	}
+	if got, _ := m.sumIntersectEdgesAt([]string{"a", "c"}, timeSpec{from: ab[0], to: ab[0]}); len(got) != 0 {
+		t.Errorf("sumIntersectEdgesAt before c b = %v, want no edges", got)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setHistory(3, 0)
+	for i := 0; i < 5; i++ {
+		m.incrEdge("a", "b", floatWeight(1))
+	}
+	if versions := m.edgeHistory[m.edgeIndexOf("a", "b")]; len(versions) != 3 || versions[0].weight != 3 {
+		t.Errorf("history = %+v, want the last 3 versions", versions)
+	}
+
+	m.setHistory(2, 0)
+	if versions := m.edgeHistory[m.edgeIndexOf("a", "b")]; len(versions) != 2 || versions[0].weight != 4 {
+		t.Errorf("history after lowering the limit = %+v, want the last 2 versions", versions)
+	}
+
+	// the newest version older than the age is kept as it is still in effect
+	edgeIndex := m.edgeIndexOf("a", "b")
+	old := time.Now().Add(-time.Hour).UnixNano()
+	m.edgeHistory[edgeIndex] = []edgeVersion{{at: old, weight: 1}, {at: old + 1, weight: 2}}
+	m.setHistory(5, time.Minute)
+	m.incrEdge("a", "b", floatWeight(1))
+	if versions := m.edgeHistory[edgeIndex]; len(versions) != 2 || versions[0].weight != 2 {
+		t.Errorf("history after aging = %+v, want the version in effect and the new one", versions)
+	}
+
+	// removed edges are forgotten once their tombstone is older than the age
+	m.removeEdge("", m.vertices["a"], m.vertices["b"])
+	if len(m.removedHistory["a"]) != 1 {
+		t.Fatalf("removed history = %v, want the history of a b", m.removedHistory)
+	}
+	m.expireRemovedHistory(time.Now().Add(2 * time.Minute).UnixNano())
+	if len(m.removedHistory) != 0 || len(m.removedOrder) != 0 {
+		t.Errorf("removed history after aging = %v %v, want none", m.removedHistory, m.removedOrder)
+	}
+
+	m.setHistory(0, 0)
+	if len(m.edgeHistory) != 0 {
+		t.Errorf("history kept after disabling it")
+	}
+}
+
+func TestIntegerEdgesAt(t *testing.T) {
+	m := integerGraph(t)
+	m.setHistory(10, 0)
+	m.setEdge("a", "b", mustWeight(t, "9007199254740991"))
+	time.Sleep(time.Microsecond)
+	m.setEdge("a", "b", mustWeight(t, "9007199254740993"))
+	time.Sleep(time.Microsecond)
+	m.setEdge("y", "c", mustWeight(t, "4503599627370496"))
+	m.setLabeledEdge("likes", "y", "c", mustWeight(t, "4503599627370496"))
+	m.setEdge("x", "c", mustWeight(t, "4503599627370496"))
+	ab := versionTimes(m, "", "a", "b")
+	now := time.Now().UnixNano()
+
+	if got, err := m.findEdgesAt("a", timeSpec{from: ab[0], to: ab[0]}); err != nil || got["b"] != 9007199254740991 {
+		t.Errorf("findEdgesAt within 2^53 - 1 = %v %v", got, err)
+	}
+	if got, err := m.findEdgesAt("y", timeSpec{from: now, to: now}); err != nil || got["c"] != 4503599627370496 {
+		t.Errorf("findEdgesAt of a single label = %v %v", got, err)
+	}
+	tests := []struct {
+		name string
+		run  func() error
+	}{
+		{"weight beyond 2^53 - 1", func() error { _, err := m.findEdgesAt("a", timeSpec{from: now, to: now}); return err }},
+		{"window ending beyond 2^53 - 1", func() error { _, err := m.findEdgesAt("a", timeSpec{ab[0], ab[1], true}); return err }},
+		{"sum across labels", func() error {
+			_, err := m.findLabeledEdgesAt([]string{"*"}, "y", timeSpec{from: now, to: now})
+			return err
+		}},
+		{"sum of the intersection", func() error {
+			_, err := m.sumIntersectEdgesAt([]string{"x", "y"}, timeSpec{from: now, to: now})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		if err := tt.run(); err != errInexactWeight {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, errInexactWeight)
+		}
+	}
+}
@@ -0,0 +1,387 @@
+package bgraph
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// propertyIndex is a secondary index over a single vertex property (or the
+// vertex label under the key label). A hash index answers equality lookups
+// while a sorted index also answers range lookups, numbers sort before strings.
+type propertyIndex struct {
+	sorted  bool
+	values  map[int64]interface{}          // currently indexed value of each vertex
+	hash    map[interface{}]map[int64]bool // vertices by value for hash indexes
+	entries *skipList                      // entries ordered by value for sorted indexes
+}
+
+// indexEntry is a single vertex of a sorted index, ordered by value then vertex
+type indexEntry struct {
+	value  interface{}
+	vertex int64
+}
+
+// indexPage is a single page of the vertices found through an index along
+// with the total number of matching vertices
+type indexPage struct {
+	Total    int      `json:"total"`
+	Vertices []string `json:"vertices"`
+}
+
+func newPropertyIndex(sorted bool) *propertyIndex {
+	idx := &propertyIndex{sorted: sorted, values: make(map[int64]interface{})}
+	if sorted {
+		idx.entries = newSkipList()
+	} else {
+		idx.hash = make(map[interface{}]map[int64]bool)
+	}
+	return idx
+}
+
+// compareValues orders property values with numbers before strings
+func compareValues(a interface{}, b interface{}) int {
+	an, aNumber := a.(float64)
+	bn, bNumber := b.(float64)
+	switch {
+	case aNumber && bNumber:
+		if an < bn {
+			return -1
+		} else if an > bn {
+			return 1
+		}
+		return 0
+	case aNumber:
+		return -1
+	case bNumber:
+		return 1
+	}
+	as, _ := a.(string)
+	bs, _ := b.(string)
+	return strings.Compare(as, bs)
+}
+
+func (idx *propertyIndex) add(f int64, value interface{}) {
+	idx.values[f] = value
+	if !idx.sorted {
+		vertices, ok := idx.hash[value]
+		if !ok {
+			vertices = make(map[int64]bool)
+			idx.hash[value] = vertices
+		}
+		vertices[f] = true
+		return
+	}
+
+	idx.entries.insert(indexEntry{value, f})
+}
+
+func (idx *propertyIndex) remove(f int64) {
+	value, ok := idx.values[f]
+	if !ok {
+		return
+	}
+
+	delete(idx.values, f)
+	if !idx.sorted {
+		delete(idx.hash[value], f)
+		if len(idx.hash[value]) == 0 {
+			delete(idx.hash, value)
+		}
+		return
+	}
+
+	idx.entries.remove(indexEntry{value, f})
+}
+
+// vertexProperty returns the value of a property of the vertex, the key label
+// refers to the label of the vertex rather than a property
+func (m *MemoryGraphDb) vertexProperty(f int64, key string) (interface{}, bool) {
+	if key == "label" {
+		label, ok := m.vertexLabels[f]
+		return label, ok
+	}
+	value, ok := m.vertexProps[f][key]
+	return value, ok
+}
+
+// reindexVertex will bring every index up to date with the current label and
+// properties of the vertex
+func (m *MemoryGraphDb) reindexVertex(f int64) {
+	for key, idx := range m.indexes {
+		value, ok := m.vertexProperty(f, key)
+		current, indexed := idx.values[f]
+		if ok == indexed && (!ok || compareValues(value, current) == 0) {
+			continue
+		}
+		idx.remove(f)
+		if ok {
+			idx.add(f, value)
+		}
+	}
+}
+
+// unindexVertex will remove the vertex from every index
+func (m *MemoryGraphDb) unindexVertex(f int64) {
+	for _, idx := range m.indexes {
+		idx.remove(f)
+	}
+}
+
+// buildIndex will create an index over the property from the existing vertices
+func (m *MemoryGraphDb) buildIndex(key string, sorted bool) {
+	idx := newPropertyIndex(sorted)
+	m.indexes[key] = idx
+	for f := range m.r_vertices {
+		if value, ok := m.vertexProperty(f, key); ok {
+			idx.add(f, value)
+		}
+	}
+}
+
+// createIndex will declare a hash or sorted index over the property, an
+// existing index over the property is rebuilt with the new kind
+func (m *MemoryGraphDb) createIndex(key string, sorted bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if idx, ok := m.indexes[key]; ok && idx.sorted == sorted {
+		return
+	}
+	m.buildIndex(key, sorted)
+}
+
+// dropIndex will remove the index over the property
+func (m *MemoryGraphDb) dropIndex(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.indexes, key)
+}
+
+// getIndexes returns the kind (hash or sorted) of every declared index
+func (m *MemoryGraphDb) getIndexes() map[string]string {
+	m.Lock()
+	defer m.Unlock()
+
+	results := make(map[string]string, len(m.indexes))
+	for key, idx := range m.indexes {
+		if idx.sorted {
+			results[key] = "sorted"
+		} else {
+			results[key] = "hash"
+		}
+	}
+	return results
+}
+
+// rankedPage will return the names of the entries ranked from lower up to
+// upper starting at offset, a count below zero returns every entry after the
+// offset. Only the entries of the page are visited, so expired vertices must
+// be purged beforehand.
+func rankedPage(list *skipList, lower int, upper int, offset int, count int, name func(e indexEntry) string) indexPage {
+	result := indexPage{Total: upper - lower, Vertices: make([]string, 0)}
+	if result.Total <= 0 {
+		result.Total = 0
+		return result
+	}
+
+	n := result.Total - offset
+	if count >= 0 && count < n {
+		n = count
+	}
+	for x := list.at(lower + offset); x != nil && len(result.Vertices) < n; x = x.next[0].node {
+		result.Vertices = append(result.Vertices, name(x.entry))
+	}
+	return result
+}
+
+// entryName returns the name of the vertex of an index entry
+func (m *MemoryGraphDb) entryName(e indexEntry) string {
+	return m.r_vertices[e.vertex]
+}
+
+// page will return the live vertices in the given order starting at offset,
+// a count below zero returns every vertex after the offset
+func (m *MemoryGraphDb) page(vertices []int64, offset int, count int) indexPage {
+	live := make([]string, 0, len(vertices))
+	for _, f := range vertices {
+		if name, ok := m.r_vertices[f]; ok {
+			if _, ok := m.liveVertex(name); ok {
+				live = append(live, name)
+			}
+		}
+	}
+
+	result := indexPage{Total: len(live), Vertices: make([]string, 0)}
+	if offset < len(live) {
+		live = live[offset:]
+		if count >= 0 && count < len(live) {
+			live = live[:count]
+		}
+		result.Vertices = live
+	}
+	return result
+}
+
+// findIndexed will return the vertices whose property equals the value using
+// the index over the property, vertices are ordered by name for hash indexes
+func (m *MemoryGraphDb) findIndexed(key string, value interface{}, offset int, count int) (indexPage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	idx, ok := m.indexes[key]
+	if !ok {
+		return indexPage{}, errors.New("no index on property " + key)
+	}
+
+	if idx.sorted {
+		m.purgeExpiredVertices(time.Now().UnixNano())
+		lower := idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, value) < 0 })
+		upper := idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, value) <= 0 })
+		return rankedPage(idx.entries, lower, upper, offset, count, m.entryName), nil
+	}
+
+	var vertices []int64
+	for f := range idx.hash[value] {
+		vertices = append(vertices, f)
+	}
+	sort.Slice(vertices, func(i, j int) bool { return m.r_vertices[vertices[i]] < m.r_vertices[vertices[j]] })
+	return m.page(vertices, offset, count), nil
+}
+
+// rangeIndexed will return the vertices whose property lies within min and
+// max inclusive ordered by value using the sorted index over the property
+func (m *MemoryGraphDb) rangeIndexed(key string, min interface{}, max interface{}, offset int, count int) (indexPage, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	idx, ok := m.indexes[key]
+	if !ok || !idx.sorted {
+		return indexPage{}, errors.New("no sorted index on property " + key)
+	}
+
+	m.purgeExpiredVertices(time.Now().UnixNano())
+	lower := idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, min) < 0 })
+	upper := idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, max) <= 0 })
+	return rankedPage(idx.entries, lower, upper, offset, count, m.entryName), nil
+}
+
+// parseIndexValue parses a value to look up in the index over the property,
+// labels are always strings while properties may also be numbers
+func parseIndexValue(key string, value []byte) interface{} {
+	if key == "label" {
+		return string(value)
+	}
+	return parsePropertyValue(string(value))
+}
+
+// parsePage parses the optional offset and count of a paginated command
+func parsePage(d [][]byte) (int, int, error) {
+	offset, count := 0, -1
+	var err error
+	if len(d) > 0 {
+		if offset, err = strconv.Atoi(string(d[0])); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a positive integer")
+		}
+	}
+	if len(d) > 1 {
+		if count, err = strconv.Atoi(string(d[1])); err != nil || count < 0 {
+			return 0, 0, errors.New("count must be a positive integer")
+		}
+	}
+	return offset, count, nil
+}
+
+// Index will declare a hash or sorted index over a vertex property, or
+// return the declared indexes of the graph
+func (b *BGraphBackend) Index(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		client.WriteJson(db.getIndexes())
+		client.Flush()
+		return nil
+	}
+
+	kind := "hash"
+	if len(d) > 1 {
+		kind = strings.ToLower(string(d[1]))
+	}
+	if len(d) > 2 || (kind != "hash" && kind != "sorted") {
+		client.WriteError(errors.New("vindex takes at most 2 parameters (vindex [key [hash|sorted]])"))
+		client.Flush()
+		return nil
+	}
+
+	db.createIndex(string(d[0]), kind == "sorted")
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// DropIndex will remove the indexes over the given vertex properties
+func (b *BGraphBackend) DropIndex(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("vunindex takes at least 1 parameter (vunindex key [key ...])"))
+		client.Flush()
+		return nil
+	}
+
+	for _, k := range d {
+		db.dropIndex(string(k))
+	}
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// FindIndexed will return a page of the vertices whose property equals the value
+func (b *BGraphBackend) FindIndexed(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 || len(d) > 4 {
+		client.WriteError(errors.New("vfind takes 2 to 4 parameters (vfind key value [offset [count]])"))
+		client.Flush()
+		return nil
+	}
+
+	offset, count, err := parsePage(d[2:])
+	if err == nil {
+		key := string(d[0])
+		var result indexPage
+		if result, err = db.findIndexed(key, parseIndexValue(key, d[1]), offset, count); err == nil {
+			client.WriteJson(result)
+			client.Flush()
+			return nil
+		}
+	}
+
+	client.WriteError(err)
+	client.Flush()
+	return nil
+}
+
+// RangeIndexed will return a page of the vertices whose property lies within a range
+func (b *BGraphBackend) RangeIndexed(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 3 || len(d) > 5 {
+		client.WriteError(errors.New("vrange takes 3 to 5 parameters (vrange key min max [offset [count]])"))
+		client.Flush()
+		return nil
+	}
+
+	offset, count, err := parsePage(d[3:])
+	if err == nil {
+		key := string(d[0])
+		var result indexPage
+		if result, err = db.rangeIndexed(key, parseIndexValue(key, d[1]), parseIndexValue(key, d[2]), offset, count); err == nil {
+			client.WriteJson(result)
+			client.Flush()
+			return nil
+		}
+	}
+
+	client.WriteError(err)
+	client.Flush()
+	return nil
+}
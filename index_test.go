@@ -0,0 +1,152 @@
+package bgraph
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// indexGraph returns items with prices and labels indexed by a sorted and a hash index
+func indexGraph() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	// items of equal price are ordered by vertex index, the order they are created in
+	prices := []interface{}{float64(5), float64(20), float64(5), float64(12), "free"}
+	for i, price := range prices {
+		vertex := "i" + strconv.Itoa(i+1)
+		m.setVertexLabel("item", vertex)
+		m.setVertexProperties(vertex, properties{"price": price})
+	}
+	m.setVertexLabel("user", "u1")
+	m.createIndex("price", true)
+	m.createIndex("label", false)
+	return m
+}
+
+func TestFindIndexed(t *testing.T) {
+	tests := []struct {
+		key    string
+		value  interface{}
+		offset int
+		count  int
+		want   []string
+		total  int
+		err    bool
+	}{
+		{"price", float64(5), 0, -1, []string{"i1", "i3"}, 2, false},
+		{"price", float64(5), 1, -1, []string{"i3"}, 2, false},
+		{"price", "free", 0, -1, []string{"i5"}, 1, false},
+		{"price", float64(6), 0, -1, []string{}, 0, false},
+		{"label", "item", 0, -1, []string{"i1", "i2", "i3", "i4", "i5"}, 5, false},
+		{"label", "item", 1, 2, []string{"i2", "i3"}, 5, false},
+		{"label", "user", 0, -1, []string{"u1"}, 1, false},
+		{"name", "lamp", 0, -1, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		page, err := indexGraph().findIndexed(tt.key, tt.value, tt.offset, tt.count)
+		if (err != nil) != tt.err {
+			t.Errorf("findIndexed(%s, %v) error = %v, want error %v", tt.key, tt.value, err, tt.err)
+			continue
+		}
+		if page.Total != tt.total || !sameNames(page.Vertices, tt.want) {
+			t.Errorf("findIndexed(%s, %v, %d, %d) = %+v, want %v of %d", tt.key, tt.value, tt.offset, tt.count, page, tt.want, tt.total)
+		}
+	}
+}
+
+func TestRangeIndexed(t *testing.T) {
+	tests := []struct {
+		key    string
+		min    interface{}
+		max    interface{}
+		offset int
+		count  int
+		want   []string
+		total  int
+		err    bool
+	}{
+		{"price", float64(5), float64(12), 0, -1, []string{"i1", "i3", "i4"}, 3, false},
+		{"price", float64(6), float64(100), 0, -1, []string{"i4", "i2"}, 2, false},
+		{"price", float64(0), "zzz", 0, -1, []string{"i1", "i3", "i4", "i2", "i5"}, 5, false},
+		{"price", float64(0), "zzz", 2, 2, []string{"i4", "i2"}, 5, false},
+		{"price", float64(13), float64(19), 0, -1, []string{}, 0, false},
+		{"price", float64(20), float64(5), 0, -1, []string{}, 0, false},
+		{"label", "a", "z", 0, -1, nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		page, err := indexGraph().rangeIndexed(tt.key, tt.min, tt.max, tt.offset, tt.count)
+		if (err != nil) != tt.err {
+			t.Errorf("rangeIndexed(%s, %v, %v) error = %v, want error %v", tt.key, tt.min, tt.max, err, tt.err)
+			continue
+		}
+		if page.Total != tt.total || !sameNames(page.Vertices, tt.want) {
+			t.Errorf("rangeIndexed(%s, %v, %v, %d, %d) = %+v, want %v of %d", tt.key, tt.min, tt.max, tt.offset, tt.count, page, tt.want, tt.total)
+		}
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	m := indexGraph()
+	m.setVertexProperties("i1", properties{"price": float64(30)})
+	m.delVertexProperties("i3", []string{"price"})
+	m.setVertexLabel("", "i2")
+	m.removeVertex(m.vertices["i4"])
+	m.setVertexProperties("i6", properties{"price": float64(7)})
+	m.expireVertex("i6", time.Hour)
+	expireNow(m)
+
+	page, _ := m.rangeIndexed("price", float64(0), float64(100), 0, -1)
+	if want := []string{"i2", "i1"}; page.Total != 2 || !sameNames(page.Vertices, want) {
+		t.Errorf("rangeIndexed after updates = %+v, want %v", page, want)
+	}
+	page, _ = m.findIndexed("label", "item", 0, -1)
+	if want := []string{"i1", "i3", "i5"}; page.Total != 3 || !sameNames(page.Vertices, want) {
+		t.Errorf("findIndexed after updates = %+v, want %v", page, want)
+	}
+
+	// rebuilding an index with another kind keeps its vertices
+	m.createIndex("price", false)
+	page, err := m.findIndexed("price", float64(30), 0, -1)
+	if err != nil || !sameNames(page.Vertices, []string{"i1"}) {
+		t.Errorf("findIndexed through the rebuilt index = %+v %v", page, err)
+	}
+	if _, err := m.rangeIndexed("price", float64(0), float64(100), 0, -1); err == nil {
+		t.Errorf("rangeIndexed through a hash index should fail")
+	}
+	if got := m.getIndexes(); len(got) != 2 || got["price"] != "hash" || got["label"] != "hash" {
+		t.Errorf("getIndexes = %v", got)
+	}
+
+	m.dropIndex("price")
+	if _, err := m.findIndexed("price", float64(30), 0, -1); err == nil {
+		t.Errorf("findIndexed through a dropped index should fail")
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		args   []string
+		offset int
+		count  int
+		err    bool
+	}{
+		{nil, 0, -1, false},
+		{[]string{"5"}, 5, -1, false},
+		{[]string{"5", "10"}, 5, 10, false},
+		{[]string{"-1"}, 0, 0, true},
+		{[]string{"0", "-1"}, 0, 0, true},
+		{[]string{"a"}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		d := make([][]byte, len(tt.args))
+		for i, arg := range tt.args {
+			d[i] = []byte(arg)
+		}
+		offset, count, err := parsePage(d)
+		if (err != nil) != tt.err || offset != tt.offset || count != tt.count {
+			t.Errorf("parsePage(%v) = %d %d %v, want %d %d error %v", tt.args, offset, count, err, tt.offset, tt.count, tt.err)
+		}
+	}
+}
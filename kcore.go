@@ -0,0 +1,115 @@
+package bgraph
+
+import "container/heap"
+
+// coreItem is a single entry in the peeling queue used by coreNumbers
+type coreItem struct {
+	vertex int64
+	degree float64
+}
+
+// coreQueue is a min-heap of vertices ordered by their current degree
+type coreQueue []coreItem
+
+func (q coreQueue) Len() int            { return len(q) }
+func (q coreQueue) Less(i, j int) bool  { return q[i].degree < q[j].degree }
+func (q coreQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *coreQueue) Push(x interface{}) { *q = append(*q, x.(coreItem)) }
+func (q *coreQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+// undirectedNeighbors will return the undirected view of the graph where
+// every directed edge a->b also links b to a. Symmetric edges collapse into a
+// single neighbor entry using the larger of the two weights.
+func (m *MemoryGraphDb) undirectedNeighbors() map[int64]map[int64]float64 {
+	neighbors := make(map[int64]map[int64]float64)
+	link := func(a int64, b int64, weight float64) {
+		n, ok := neighbors[a]
+		if !ok {
+			n = make(map[int64]float64)
+			neighbors[a] = n
+		}
+		if w, ok := n[b]; !ok || weight > w {
+			n[b] = weight
+		}
+	}
+
+	for f, vertexEdges := range m.edges {
+		for t, edgeIndex := range vertexEdges {
+			if f == t {
+				continue
+			}
+			weight := m.edgeWeight(edgeIndex)
+			link(f, t, weight)
+			link(t, f, weight)
+		}
+	}
+
+	return neighbors
+}
+
+// coreNumbers will compute the core number of every vertex by repeatedly
+// peeling the vertex with the smallest remaining degree. When weighted is
+// set the degree is the sum of the incident edge weights (negative weights
+// count as zero), otherwise it is the number of distinct neighbors.
+func (m *MemoryGraphDb) coreNumbers(weighted bool) map[string]float64 {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	neighbors := m.undirectedNeighbors()
+	degrees := make(map[int64]float64, len(m.vertices))
+	queue := make(coreQueue, 0, len(m.vertices))
+	for _, index := range m.vertices {
+		degree := float64(0)
+		for _, weight := range neighbors[index] {
+			degree += coreWeight(weight, weighted)
+		}
+		degrees[index] = degree
+		queue = append(queue, coreItem{index, degree})
+	}
+	heap.Init(&queue)
+
+	results := make(map[string]float64, len(m.vertices))
+	removed := make(map[int64]bool, len(m.vertices))
+	core := float64(0)
+	for queue.Len() > 0 {
+		item := heap.Pop(&queue).(coreItem)
+		if removed[item.vertex] || item.degree != degrees[item.vertex] {
+			continue
+		}
+
+		if item.degree > core {
+			core = item.degree
+		}
+		removed[item.vertex] = true
+		results[m.r_vertices[item.vertex]] = core
+
+		for n, weight := range neighbors[item.vertex] {
+			if removed[n] {
+				continue
+			}
+			degrees[n] -= coreWeight(weight, weighted)
+			heap.Push(&queue, coreItem{n, degrees[n]})
+		}
+	}
+
+	return results
+}
+
+// coreWeight returns the contribution of a single edge to a vertex degree
+func coreWeight(weight float64, weighted bool) float64 {
+	if !weighted {
+		return 1
+	}
+	if weight < 0 {
+		return 0
+	}
+	return weight
+}
@@ -0,0 +1,83 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoreNumbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		edges    [][2]string
+		weights  map[[2]string]float64 // weights other than one
+		weighted bool
+		want     map[string]float64
+	}{
+		{
+			name: "empty graph",
+			want: map[string]float64{},
+		},
+		{
+			name:  "path",
+			edges: [][2]string{{"a", "b"}, {"b", "c"}},
+			want:  map[string]float64{"a": 1, "b": 1, "c": 1},
+		},
+		{
+			name:  "triangle with a pendant",
+			edges: [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}},
+			want:  map[string]float64{"a": 2, "b": 2, "c": 2, "d": 1},
+		},
+		{
+			name:  "clique of four within a cycle",
+			edges: [][2]string{{"a", "b"}, {"a", "c"}, {"a", "d"}, {"b", "c"}, {"b", "d"}, {"c", "d"}, {"d", "e"}, {"e", "f"}, {"f", "a"}},
+			want:  map[string]float64{"a": 3, "b": 3, "c": 3, "d": 3, "e": 2, "f": 2},
+		},
+		{
+			name:  "both directions count as one neighbor",
+			edges: [][2]string{{"a", "b"}, {"b", "a"}},
+			want:  map[string]float64{"a": 1, "b": 1},
+		},
+		{
+			name:  "self loops are ignored",
+			edges: [][2]string{{"a", "a"}, {"a", "b"}},
+			want:  map[string]float64{"a": 1, "b": 1},
+		},
+		{
+			name:     "weighted degrees",
+			edges:    [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}, {"c", "d"}},
+			weights:  map[[2]string]float64{{"a", "b"}: 2, {"b", "c"}: 2, {"c", "a"}: 2},
+			weighted: true,
+			want:     map[string]float64{"a": 4, "b": 4, "c": 4, "d": 1},
+		},
+		{
+			name:     "weighted symmetric edges use the larger weight",
+			edges:    [][2]string{{"a", "b"}, {"b", "a"}},
+			weights:  map[[2]string]float64{{"a", "b"}: 3},
+			weighted: true,
+			want:     map[string]float64{"a": 3, "b": 3},
+		},
+		{
+			name:     "weighted negative edges count as zero",
+			edges:    [][2]string{{"a", "b"}, {"b", "c"}},
+			weights:  map[[2]string]float64{{"a", "b"}: -5},
+			weighted: true,
+			want:     map[string]float64{"a": 0, "b": 1, "c": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			for _, e := range tt.edges {
+				weight, ok := tt.weights[e]
+				if !ok {
+					weight = 1
+				}
+				m.setEdge(e[0], e[1], floatWeight(weight))
+			}
+			if got := m.coreNumbers(tt.weighted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("coreNumbers(%v) = %v, want %v", tt.weighted, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package bgraph
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// labelCommands are the graph commands that can be run through LABEL
+var labelCommands = map[string]bool{
+	"=>": true, "+>": true, "->": true,
+	"<=>": true, "<+>": true, "<->": true,
+	"*e": true, "&e": true, "*e@": true, "&e@": true, "*ep": true, "eagg": true, "*en": true, "normalize": true,
+	"scale": true, "clamp": true, "prune": true,
+	"eset": true, "edel": true, "eget": true,
+	"expire>": true, "ttl>": true,
+}
+
+// labelView is a graph whose edge writes and neighbor queries are restricted
+// to a set of edge labels, every other operation applies to the whole graph
+type labelView struct {
+	DB
+	labels []string
+}
+
+func (v labelView) setEdge(from string, to string, weight weightArg) error {
+	return v.DB.setLabeledEdge(v.labels[0], from, to, weight)
+}
+
+func (v labelView) incrEdge(from string, to string, weight weightArg) error {
+	return v.DB.incrLabeledEdge(v.labels[0], from, to, weight)
+}
+
+func (v labelView) decrEdge(from string, to string, weight weightArg) error {
+	return v.DB.decrLabeledEdge(v.labels[0], from, to, weight)
+}
+
+func (v labelView) findEdges(vertex string) map[string]float64 {
+	return v.DB.findLabeledEdges(v.labels, vertex)
+}
+
+func (v labelView) sumIntersectEdges(vertices []string) map[string]float64 {
+	return v.DB.sumIntersectLabeledEdges(v.labels, vertices)
+}
+
+func (v labelView) findExactEdges(vertex string) (map[string]int64, error) {
+	return v.DB.findLabeledExactEdges(v.labels, vertex)
+}
+
+func (v labelView) sumIntersectExactEdges(vertices []string) (map[string]int64, error) {
+	return v.DB.sumIntersectLabeledExactEdges(v.labels, vertices)
+}
+
+func (v labelView) findEdgesAt(vertex string, at timeSpec) (map[string]float64, error) {
+	return v.DB.findLabeledEdgesAt(v.labels, vertex, at)
+}
+
+func (v labelView) sumIntersectEdgesAt(vertices []string, at timeSpec) (map[string]float64, error) {
+	return v.DB.sumIntersectLabeledEdgesAt(v.labels, vertices, at)
+}
+
+func (v labelView) setEdgeProperties(from string, to string, props properties) {
+	v.DB.setLabeledEdgeProperties(v.labels[0], from, to, props)
+}
+
+func (v labelView) delEdgeProperties(from string, to string, keys []string) {
+	v.DB.delLabeledEdgeProperties(v.labels[0], from, to, keys)
+}
+
+func (v labelView) getEdgeProperties(from string, to string) (edgeDetail, bool) {
+	return v.DB.getLabeledEdgeProperties(v.labels[0], from, to)
+}
+
+func (v labelView) findEdgesWithProperties(vertex string) (map[string]edgeDetail, error) {
+	return v.DB.findLabeledEdgesWithProperties(v.labels, vertex)
+}
+
+func (v labelView) expireEdge(from string, to string, ttl time.Duration) {
+	v.DB.expireLabeledEdge(v.labels[0], from, to, ttl)
+}
+
+func (v labelView) edgeTTL(from string, to string) (float64, bool) {
+	return v.DB.labeledEdgeTTL(v.labels[0], from, to)
+}
+
+func (v labelView) normalizeEdges(vertex string) error {
+	return v.DB.normalizeLabeledEdges(v.labels[0], vertex)
+}
+
+func (v labelView) transformEdges(vertices []string, transform edgeTransform) transformResult {
+	return v.DB.transformLabeledEdges(v.labels, vertices, transform)
+}
+
+// edgeLabels will return the weight of the edge between the two vertices for
+// each of its labels, the unlabeled edge is returned under the empty label
+func (m *MemoryGraphDb) edgeLabels(from string, to string) map[string]float64 {
+	m.Lock()
+	defer m.Unlock()
+
+	f, f_ok := m.liveVertex(from)
+	t, t_ok := m.liveVertex(to)
+	if !f_ok || !t_ok {
+		return nil
+	}
+
+	m.purgeEdges(f)
+	results := make(map[string]float64)
+	m.eachAdjacency(func(label string, adj edgeMap) {
+		if edgeIndex, ok := adj[f][t]; ok {
+			results[label] = m.edgeWeight(edgeIndex)
+		}
+	})
+	return results
+}
+
+// Label will run an edge command against the edges with the given label. A
+// comma separated list of labels, or * for every label including unlabeled
+// edges, aggregates the weights of neighbor and intersection queries.
+func (b *BGraphBackend) Label(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("label takes at least 2 parameters (label name[,name ...]|* command [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	cmd := strings.ToLower(string(d[1]))
+	if !labelCommands[cmd] {
+		client.WriteError(errors.New("label does not support the command " + cmd))
+		client.Flush()
+		return nil
+	}
+
+	labels := strings.Split(string(d[0]), ",")
+	if (b.commands[cmd].FireForget || cmd == "eget" || cmd == "ttl>") && (len(labels) > 1 || labels[0] == "*") {
+		client.WriteError(errors.New("label can only write or get edges with a single label"))
+		client.Flush()
+		return nil
+	}
+
+	return b.dispatch(labelView{db, labels}, d[1:], client)
+}
+
+// EdgeLabels will return the weight of the edge between two vertices for each of its labels
+func (b *BGraphBackend) EdgeLabels(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) != 2 {
+		client.WriteError(errors.New("labels takes 2 parameters (labels from to)"))
+		client.Flush()
+		return nil
+	}
+
+	if db.getWeightMode() == weightInteger {
+		results := db.exactEdgeLabels(string(d[0]), string(d[1]))
+		if len(results) > 0 {
+			client.WriteJson(results)
+		} else {
+			client.WriteNull()
+		}
+		client.Flush()
+		return nil
+	}
+
+	results := db.edgeLabels(string(d[0]), string(d[1]))
+	if len(results) > 0 {
+		client.WriteJson(results)
+		client.Flush()
+	} else {
+		client.WriteNull()
+		client.Flush()
+	}
+	return nil
+}
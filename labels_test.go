@@ -0,0 +1,92 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+// labelGraph returns a graph with unlabeled edges and edges labeled follows and messaged
+func labelGraph() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "c", floatWeight(10))
+	m.setLabeledEdge("follows", "a", "b", floatWeight(1))
+	m.setLabeledEdge("messaged", "a", "b", floatWeight(3))
+	m.setLabeledEdge("follows", "x", "b", floatWeight(2))
+	m.setLabeledEdge("messaged", "x", "c", floatWeight(4))
+	return m
+}
+
+func TestLabeledEdges(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    []string
+		edges     map[string]float64 // edges from a
+		intersect map[string]float64 // intersection of the edges from a and x
+	}{
+		{"unlabeled", []string{""}, map[string]float64{"c": 10}, map[string]float64{}},
+		{"single label", []string{"follows"}, map[string]float64{"b": 1}, map[string]float64{"b": 3}},
+		{"several labels", []string{"follows", "messaged"}, map[string]float64{"b": 4}, map[string]float64{"b": 6}},
+		{"every label", []string{"*"}, map[string]float64{"b": 4, "c": 10}, map[string]float64{"b": 6, "c": 14}},
+		{"missing label", []string{"blocked"}, nil, map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := labelGraph()
+			if got := m.findLabeledEdges(tt.labels, "a"); !reflect.DeepEqual(got, tt.edges) {
+				t.Errorf("findLabeledEdges = %v, want %v", got, tt.edges)
+			}
+			if got := m.sumIntersectLabeledEdges(tt.labels, []string{"a", "x"}); !sameWeights(got, tt.intersect) {
+				t.Errorf("sumIntersectLabeledEdges = %v, want %v", got, tt.intersect)
+			}
+		})
+	}
+}
+
+func TestLabeledWrites(t *testing.T) {
+	m := labelGraph()
+	m.incrLabeledEdge("follows", "a", "b", floatWeight(2))
+	m.decrLabeledEdge("messaged", "a", "b", floatWeight(1))
+	if got, want := m.edgeLabels("a", "b"), map[string]float64{"follows": 3, "messaged": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("edgeLabels = %v, want %v", got, want)
+	}
+	if got := m.findEdges("a"); !reflect.DeepEqual(got, map[string]float64{"c": 10}) {
+		t.Errorf("labeled writes changed the unlabeled edges to %v", got)
+	}
+
+	// removing a label keeps the edges under other labels
+	m.removeEdge("follows", m.vertices["a"], m.vertices["b"])
+	if got, want := m.edgeLabels("a", "b"), map[string]float64{"messaged": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("edgeLabels after removal = %v, want %v", got, want)
+	}
+
+	// removing a vertex removes its edges under every label
+	m.removeVertex(m.vertices["b"])
+	if got := m.findLabeledEdges([]string{"*"}, "a"); !reflect.DeepEqual(got, map[string]float64{"c": 10}) {
+		t.Errorf("edges after removing b = %v", got)
+	}
+	if got := m.findLabeledEdges([]string{"*"}, "x"); !reflect.DeepEqual(got, map[string]float64{"c": 4}) {
+		t.Errorf("edges of x after removing b = %v", got)
+	}
+	if info := m.info(); info.Edges != 2 || info.Labels != 1 {
+		t.Errorf("info = %+v, want 2 edges under 1 label", info)
+	}
+}
+
+func TestLabelView(t *testing.T) {
+	m := labelGraph()
+	v := labelView{m, []string{"follows"}}
+	v.setEdge("a", "d", floatWeight(5))
+	v.incrEdge("a", "b", floatWeight(1))
+	if got, want := v.findEdges("a"), map[string]float64{"b": 2, "d": 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findEdges through the view = %v, want %v", got, want)
+	}
+	if _, ok := m.findEdges("a")["d"]; ok {
+		t.Errorf("write through the view created an unlabeled edge")
+	}
+}
+
+// sameWeights reports whether both maps hold the same weights, treating nil as empty
+func sameWeights(a map[string]float64, b map[string]float64) bool {
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
@@ -0,0 +1,194 @@
+package bgraph
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// likeCommands are the graph commands that can be run through LIKE
+var likeCommands = map[string]bool{
+	"*e": true, "&e": true, "*e@": true, "&e@": true, "*ep": true, "eagg": true, "*en": true,
+	"subgraph": true, "ego": true, "label": true, "where": true,
+}
+
+// parsePatterns will parse a comma separated list of glob patterns such as
+// item:*,tag:[a-c]* and ensure that each of them is well formed
+func parsePatterns(spec string) ([]string, error) {
+	patterns := strings.Split(spec, ",")
+	for _, pattern := range patterns {
+		if validGlob(pattern) != nil || pattern == "" {
+			return nil, errors.New("invalid pattern " + pattern + " (expected a glob such as item:*)")
+		}
+	}
+	return patterns, nil
+}
+
+// errBadGlob is returned for a pattern with an unterminated class or escape
+var errBadGlob = errors.New("syntax error in pattern")
+
+// globMatch reports whether the name matches the glob pattern, which has the
+// syntax of path.Match except that * and ? also match '/' as vertex names are
+// not paths
+func globMatch(pattern string, name string) (bool, error) {
+	if err := validGlob(pattern); err != nil {
+		return false, err
+	}
+
+	// on a mismatch the last * is retried consuming one more character
+	px, nx := 0, 0
+	starP, starN := -1, -1
+	for px < len(pattern) || nx < len(name) {
+		if px < len(pattern) {
+			switch c := pattern[px]; c {
+			case '*':
+				starP, starN = px, nx
+				px++
+				continue
+			case '?':
+				if nx < len(name) {
+					_, size := utf8.DecodeRuneInString(name[nx:])
+					px, nx = px+1, nx+size
+					continue
+				}
+			case '[':
+				if nx < len(name) {
+					r, size := utf8.DecodeRuneInString(name[nx:])
+					ok, width, _ := matchClass(pattern[px:], r)
+					if ok {
+						px, nx = px+width, nx+size
+						continue
+					}
+				}
+			default:
+				width := 1
+				if c == '\\' {
+					c, width = pattern[px+1], 2
+				}
+				if nx < len(name) && name[nx] == c {
+					px, nx = px+width, nx+1
+					continue
+				}
+			}
+		}
+		if starP < 0 || starN >= len(name) {
+			return false, nil
+		}
+		_, size := utf8.DecodeRuneInString(name[starN:])
+		starN += size
+		px, nx = starP+1, starN
+	}
+	return true, nil
+}
+
+// validGlob returns errBadGlob unless every class and escape of the pattern is terminated
+func validGlob(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i++; i == len(pattern) {
+				return errBadGlob
+			}
+		case '[':
+			_, width, err := matchClass(pattern[i:], 0)
+			if err != nil {
+				return err
+			}
+			i += width - 1
+		}
+	}
+	return nil
+}
+
+// matchClass reports whether the rune is in the class such as [^a-c] at the
+// start of the pattern and the width of the class within the pattern
+func matchClass(pattern string, r rune) (bool, int, error) {
+	i := 1
+	negated := i < len(pattern) && pattern[i] == '^'
+	if negated {
+		i++
+	}
+	matched := false
+	for n := 0; ; n++ {
+		if i == len(pattern) {
+			return false, 0, errBadGlob
+		}
+		if pattern[i] == ']' && n > 0 {
+			return matched != negated, i + 1, nil
+		}
+		lo, width, err := classChar(pattern[i:])
+		if err != nil {
+			return false, 0, err
+		}
+		i += width
+		hi := lo
+		if i < len(pattern) && pattern[i] == '-' {
+			if hi, width, err = classChar(pattern[i+1:]); err != nil {
+				return false, 0, err
+			}
+			i += 1 + width
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+}
+
+// classChar returns the possibly escaped character at the start of a class range
+func classChar(pattern string) (rune, int, error) {
+	width := 0
+	if pattern != "" && pattern[0] == '\\' {
+		pattern, width = pattern[1:], 1
+	} else if pattern == "" || pattern[0] == '-' || pattern[0] == ']' {
+		return 0, 0, errBadGlob
+	}
+	if pattern == "" {
+		return 0, 0, errBadGlob
+	}
+	r, size := utf8.DecodeRuneInString(pattern)
+	return r, width + size, nil
+}
+
+// matchNames returns a filter accepting the vertex names that match any of the patterns
+func matchNames(patterns []string) func(names []string) map[string]bool {
+	return func(names []string) map[string]bool {
+		results := make(map[string]bool)
+		for _, name := range names {
+			for _, pattern := range patterns {
+				if ok, _ := globMatch(pattern, name); ok {
+					results[name] = true
+					break
+				}
+			}
+		}
+		return results
+	}
+}
+
+// Like will run a neighbor or traversal query returning only the vertices
+// whose names match any of the glob patterns
+func (b *BGraphBackend) Like(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("like takes at least 2 parameters (like pattern[,pattern ...] command [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	cmd := strings.ToLower(string(d[1]))
+	if !likeCommands[cmd] {
+		client.WriteError(errors.New("like does not support the command " + cmd))
+		client.Flush()
+		return nil
+	}
+
+	patterns, err := parsePatterns(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	return b.dispatch(filterView{db, matchNames(patterns)}, d[1:], client)
+}
@@ -0,0 +1,71 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+		err     bool
+	}{
+		{"item:*", "item:1", true, false},
+		{"item:*", "item:a/b", true, false},
+		{"*/*", "a/b/c", true, false},
+		{"item:?", "item:/", true, false},
+		{"item:?", "item:é", true, false},
+		{"item:?", "item:12", false, false},
+		{"*:*:1", "a:b:c:1", true, false},
+		{"*:*:1", "a:b:c:2", false, false},
+		{"tag:[a-c]*", "tag:beta", true, false},
+		{"tag:[a-c]*", "tag:delta", false, false},
+		{"tag:[^a-c]*", "tag:delta", true, false},
+		{"tag:[ab\\]]", "tag:]", true, false},
+		{"a\\*", "a*", true, false},
+		{"a\\*", "ab", false, false},
+		{"", "", true, false},
+		{"*", "", true, false},
+		{"a", "", false, false},
+		{"tag:[a-c", "tag:a", false, true},
+		{"tag:[]", "tag:a", false, true},
+		{"a\\", "a", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := globMatch(tt.pattern, tt.name)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v %v, want %v with error %v", tt.pattern, tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestMatchNames(t *testing.T) {
+	if _, err := parsePatterns("item:*,tag:[a-c"); err == nil {
+		t.Errorf("parsePatterns accepted an unterminated class")
+	}
+	if _, err := parsePatterns("item:*,"); err == nil {
+		t.Errorf("parsePatterns accepted an empty pattern")
+	}
+
+	patterns, err := parsePatterns("item:*,tag:[a-c]*")
+	if err != nil {
+		t.Fatalf("parsePatterns: %v", err)
+	}
+	names := []string{"item:1", "item:a/b", "tag:beta", "tag:delta", "user:1"}
+	want := map[string]bool{"item:1": true, "item:a/b": true, "tag:beta": true}
+	if got := matchNames(patterns)(names); !reflect.DeepEqual(got, want) {
+		t.Errorf("matchNames = %v, want %v", got, want)
+	}
+
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("u", "item:1", floatWeight(1))
+	m.setEdge("u", "item:a/b", floatWeight(2))
+	m.setEdge("u", "user:2", floatWeight(3))
+	v := filterView{m, matchNames(patterns)}
+	if got, want := v.findEdges("u"), map[string]float64{"item:1": 1, "item:a/b": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("findEdges through like = %v, want %v", got, want)
+	}
+}
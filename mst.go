@@ -0,0 +1,163 @@
+package bgraph
+
+import (
+	"encoding/json"
+	"math/big"
+	"sort"
+)
+
+// weightedEdge is a single edge along with its weight (and properties when
+// exported as part of a subgraph) as returned by the graph algorithm commands
+type weightedEdge struct {
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Weight     float64    `json:"weight"`
+	Properties properties `json:"properties,omitempty"`
+	exact      int64      // exact weight of an edge in a graph with integer weights
+	integer    bool
+}
+
+// MarshalJSON will write the exact weight in place of the float when the edge
+// is in a graph with integer weights
+func (e weightedEdge) MarshalJSON() ([]byte, error) {
+	var weight interface{} = e.Weight
+	if e.integer {
+		weight = e.exact
+	}
+	return json.Marshal(struct {
+		From       string      `json:"from"`
+		To         string      `json:"to"`
+		Weight     interface{} `json:"weight"`
+		Properties properties  `json:"properties,omitempty"`
+	}{e.From, e.To, weight, e.Properties})
+}
+
+// spanningForest is the set of tree edges and their total cost
+type spanningForest struct {
+	Edges []weightedEdge `json:"edges"`
+	Total float64        `json:"total"`
+	total *big.Int       // exact total of a graph with integer weights
+}
+
+// MarshalJSON will write the exact total in place of the float when the
+// forest is in a graph with integer weights
+func (f spanningForest) MarshalJSON() ([]byte, error) {
+	var total interface{} = f.Total
+	if f.total != nil {
+		total = f.total
+	}
+	return json.Marshal(struct {
+		Edges []weightedEdge `json:"edges"`
+		Total interface{}    `json:"total"`
+	}{f.Edges, total})
+}
+
+// disjointSet is a union-find structure over vertex indices
+type disjointSet map[int64]int64
+
+func (s disjointSet) find(v int64) int64 {
+	root := v
+	for {
+		parent, ok := s[root]
+		if !ok || parent == root {
+			break
+		}
+		root = parent
+	}
+	// compress the path so later lookups are constant time
+	for v != root {
+		next, ok := s[v]
+		if !ok {
+			break
+		}
+		s[v] = root
+		v = next
+	}
+	return root
+}
+
+func (s disjointSet) union(a int64, b int64) bool {
+	ra, rb := s.find(a), s.find(b)
+	if ra == rb {
+		return false
+	}
+	s[ra] = rb
+	return true
+}
+
+// spanningForest will compute the minimum (or maximum) spanning forest over
+// the symmetric edges of the graph, those that exist in both directions.
+// Both directions are expected to carry the same weight as written by <=>
+// and <+>; the weight of the direction leaving the vertex whose name sorts
+// first is used, and edges of equal weight are taken in order of their names.
+// Graphs with integer weights order and total the edges by their exact weights.
+func (m *MemoryGraphDb) spanningForest(maximum bool) *spanningForest {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	type candidate struct {
+		from   int64
+		to     int64
+		weight float64
+		exact  int64
+	}
+
+	// vertex indices are reused once freed so the names give the stable order
+	candidates := make([]candidate, 0)
+	for f, vertexEdges := range m.edges {
+		for t, edgeIndex := range vertexEdges {
+			if m.r_vertices[f] >= m.r_vertices[t] {
+				continue
+			}
+			if _, ok := m.edges[t][f]; !ok {
+				continue
+			}
+			candidates = append(candidates, candidate{f, t, m.edgeWeight(edgeIndex), m.edgeExact(edgeIndex)})
+		}
+	}
+
+	integer := m.mode == weightInteger
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if integer && a.exact != b.exact {
+			if maximum {
+				return a.exact > b.exact
+			}
+			return a.exact < b.exact
+		}
+		if !integer && a.weight != b.weight {
+			if maximum {
+				return a.weight > b.weight
+			}
+			return a.weight < b.weight
+		}
+		if a.from != b.from {
+			return m.r_vertices[a.from] < m.r_vertices[b.from]
+		}
+		return m.r_vertices[a.to] < m.r_vertices[b.to]
+	})
+
+	forest := &spanningForest{Edges: make([]weightedEdge, 0)}
+	if integer {
+		forest.total = new(big.Int)
+	}
+	sets := make(disjointSet)
+	for _, c := range candidates {
+		if sets.union(c.from, c.to) {
+			edge := weightedEdge{From: m.r_vertices[c.from], To: m.r_vertices[c.to], Weight: c.weight}
+			forest.Total += c.weight
+			if integer {
+				edge.exact, edge.integer = c.exact, true
+				forest.total.Add(forest.total, big.NewInt(c.exact))
+			}
+			forest.Edges = append(forest.Edges, edge)
+		}
+	}
+	if integer {
+		forest.Total, _ = new(big.Float).SetInt(forest.total).Float64()
+	}
+
+	return forest
+}
@@ -0,0 +1,110 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpanningForest(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func(m *MemoryGraphDb)
+		maximum bool
+		edges   []weightedEdge
+		total   float64
+	}{
+		{
+			name:  "empty graph",
+			build: func(m *MemoryGraphDb) {},
+			edges: []weightedEdge{},
+		},
+		{
+			name: "minimum tree",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "a", "b", 1)
+				symmetricEdge(m, "b", "c", 2)
+				symmetricEdge(m, "a", "c", 3)
+			},
+			edges: []weightedEdge{{From: "a", To: "b", Weight: 1}, {From: "b", To: "c", Weight: 2}},
+			total: 3,
+		},
+		{
+			name: "maximum tree",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "a", "b", 1)
+				symmetricEdge(m, "b", "c", 2)
+				symmetricEdge(m, "a", "c", 3)
+			},
+			maximum: true,
+			edges:   []weightedEdge{{From: "a", To: "c", Weight: 3}, {From: "b", To: "c", Weight: 2}},
+			total:   5,
+		},
+		{
+			name: "forest over components",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "a", "b", 1)
+				symmetricEdge(m, "x", "y", 5)
+			},
+			edges: []weightedEdge{{From: "a", To: "b", Weight: 1}, {From: "x", To: "y", Weight: 5}},
+			total: 6,
+		},
+		{
+			name: "one way edges are skipped",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "a", "b", 2)
+				m.setEdge("b", "c", floatWeight(1))
+				m.setEdge("c", "c", floatWeight(1))
+			},
+			edges: []weightedEdge{{From: "a", To: "b", Weight: 2}},
+			total: 2,
+		},
+		{
+			name: "ties taken in order of names",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "c", "b", 1)
+				symmetricEdge(m, "b", "a", 1)
+				symmetricEdge(m, "c", "a", 1)
+			},
+			edges: []weightedEdge{{From: "a", To: "b", Weight: 1}, {From: "a", To: "c", Weight: 1}},
+			total: 2,
+		},
+		{
+			name: "weight of the direction leaving the first name",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("b", "a", floatWeight(4))
+				m.setEdge("a", "b", floatWeight(3))
+			},
+			edges: []weightedEdge{{From: "a", To: "b", Weight: 3}},
+			total: 3,
+		},
+		{
+			name: "reused vertex indices",
+			build: func(m *MemoryGraphDb) {
+				symmetricEdge(m, "a", "b", 1)
+				m.removeVertex(m.vertices["a"])
+				symmetricEdge(m, "z", "b", 1)
+				symmetricEdge(m, "b", "c", 1)
+				symmetricEdge(m, "z", "c", 1)
+			},
+			edges: []weightedEdge{{From: "b", To: "c", Weight: 1}, {From: "b", To: "z", Weight: 1}},
+			total: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.build(m)
+			forest := m.spanningForest(tt.maximum)
+			if !reflect.DeepEqual(forest.Edges, tt.edges) || forest.Total != tt.total {
+				t.Errorf("spanningForest = %+v, want %+v with total %v", forest, tt.edges, tt.total)
+			}
+		})
+	}
+}
+
+// symmetricEdge will set the same weight on the edges in both directions
+func symmetricEdge(m *MemoryGraphDb, a string, b string, weight float64) {
+	m.setEdge(a, b, floatWeight(weight))
+	m.setEdge(b, a, floatWeight(weight))
+}
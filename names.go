@@ -0,0 +1,94 @@
+package bgraph
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// The vertex names are kept in order in m.vertexNames as entries holding the
+// name and index of each vertex, updated as vertices are created and removed.
+
+// entryString returns the vertex name held by an entry of the vertex names
+func entryString(e indexEntry) string {
+	return e.value.(string)
+}
+
+// prefixVertices will return a page of the vertex names starting with prefix in order
+func (m *MemoryGraphDb) prefixVertices(prefix string, offset int, count int) indexPage {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpiredVertices(time.Now().UnixNano())
+	lower := m.vertexNames.countBefore(func(e indexEntry) bool { return entryString(e) < prefix })
+	upper := m.vertexNames.countBefore(func(e indexEntry) bool {
+		name := entryString(e)
+		return name < prefix || strings.HasPrefix(name, prefix)
+	})
+	return rankedPage(m.vertexNames, lower, upper, offset, count, entryString)
+}
+
+// rangeVertices will return a page of the vertex names between min and max
+// inclusive in order, an empty min or max leaves that end unbounded
+func (m *MemoryGraphDb) rangeVertices(min string, max string, offset int, count int) indexPage {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpiredVertices(time.Now().UnixNano())
+	lower := m.vertexNames.countBefore(func(e indexEntry) bool { return entryString(e) < min })
+	upper := m.vertexNames.length
+	if max != "" {
+		upper = m.vertexNames.countBefore(func(e indexEntry) bool { return entryString(e) <= max })
+	}
+	return rankedPage(m.vertexNames, lower, upper, offset, count, entryString)
+}
+
+// PrefixVertices will return a page of the vertex names starting with the prefix
+func (b *BGraphBackend) PrefixVertices(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 || len(d) > 3 {
+		client.WriteError(errors.New("vprefix takes 1 to 3 parameters (vprefix prefix [offset [count]])"))
+		client.Flush()
+		return nil
+	}
+
+	offset, count, err := parsePage(d[1:])
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	client.WriteJson(db.prefixVertices(string(d[0]), offset, count))
+	client.Flush()
+	return nil
+}
+
+// RangeVertices will return a page of the vertex names within a lexicographic
+// range, where - and + leave the start and the end of the range unbounded
+func (b *BGraphBackend) RangeVertices(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 || len(d) > 4 {
+		client.WriteError(errors.New("vbetween takes 2 to 4 parameters (vbetween min|- max|+ [offset [count]])"))
+		client.Flush()
+		return nil
+	}
+
+	offset, count, err := parsePage(d[2:])
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	min, max := string(d[0]), string(d[1])
+	if min == "-" {
+		min = ""
+	}
+	if max == "+" {
+		max = ""
+	}
+	client.WriteJson(db.rangeVertices(min, max, offset, count))
+	client.Flush()
+	return nil
+}
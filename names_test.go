@@ -0,0 +1,76 @@
+package bgraph
+
+import (
+	"testing"
+	"time"
+)
+
+// namesGraph returns a graph whose vertex names share prefixes
+func namesGraph() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("item:1", "item:10", floatWeight(1))
+	m.setEdge("item:2", "tag:a", floatWeight(1))
+	m.setEdge("user:1", "item:1", floatWeight(1))
+	m.setVertex("item", floatWeight(1))
+	return m
+}
+
+func TestPrefixVertices(t *testing.T) {
+	tests := []struct {
+		prefix string
+		offset int
+		count  int
+		total  int
+		want   []string
+	}{
+		{"item:", 0, 10, 3, []string{"item:1", "item:10", "item:2"}},
+		{"item", 0, 10, 4, []string{"item", "item:1", "item:10", "item:2"}},
+		{"item:1", 0, 10, 2, []string{"item:1", "item:10"}},
+		{"item:", 1, 1, 3, []string{"item:10"}},
+		{"item:", 3, 10, 3, nil},
+		{"", 4, 10, 6, []string{"tag:a", "user:1"}},
+		{"z", 0, 10, 0, nil},
+	}
+
+	m := namesGraph()
+	for _, tt := range tests {
+		page := m.prefixVertices(tt.prefix, tt.offset, tt.count)
+		if page.Total != tt.total || !sameNames(page.Vertices, tt.want) {
+			t.Errorf("prefixVertices(%q, %d, %d) = %+v, want %d %v", tt.prefix, tt.offset, tt.count, page, tt.total, tt.want)
+		}
+	}
+}
+
+func TestRangeVertices(t *testing.T) {
+	tests := []struct {
+		min   string
+		max   string
+		total int
+		want  []string
+	}{
+		{"item:1", "item:2", 3, []string{"item:1", "item:10", "item:2"}},
+		{"item:10", "tag:a", 3, []string{"item:10", "item:2", "tag:a"}},
+		{"", "item:1", 2, []string{"item", "item:1"}},
+		{"tag", "", 2, []string{"tag:a", "user:1"}},
+		{"item:3", "tag", 0, nil},
+		{"user:1", "item", 0, nil},
+	}
+
+	m := namesGraph()
+	for _, tt := range tests {
+		page := m.rangeVertices(tt.min, tt.max, 0, 10)
+		if page.Total != tt.total || !sameNames(page.Vertices, tt.want) {
+			t.Errorf("rangeVertices(%q, %q) = %+v, want %d %v", tt.min, tt.max, page, tt.total, tt.want)
+		}
+	}
+}
+
+func TestVertexNamesFollowRemovals(t *testing.T) {
+	m := namesGraph()
+	m.removeVertex(m.vertices["item:10"])
+	m.expireVertex("item:2", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if page := m.prefixVertices("item:", 0, 10); !sameNames(page.Vertices, []string{"item:1"}) {
+		t.Errorf("prefixVertices after removals = %v, want item:1", page.Vertices)
+	}
+}
@@ -0,0 +1,247 @@
+package bgraph
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// negativePolicy decides what happens when a write leaves a weight below zero
+type negativePolicy string
+
+const (
+	negativeDefault negativePolicy = ""       // use the policy of the graph
+	negativeAllow   negativePolicy = "allow"  // keep negative weights
+	negativeClamp   negativePolicy = "clamp"  // clamp negative weights to zero
+	negativeReject  negativePolicy = "reject" // leave the weight unchanged and return an error
+	negativeDelete  negativePolicy = "delete" // remove the edge or vertex weight at zero or below
+)
+
+// negativeCommands are the graph commands that can be run through NEGATIVE
+var negativeCommands = map[string]bool{
+	"=>": true, "+>": true, "->": true,
+	"<=>": true, "<+>": true, "<->": true,
+	"=": true, "+": true, "-": true,
+	"label": true,
+}
+
+// weightWrite is a weight replacing, added to or subtracted from the current weight
+type weightWrite struct {
+	weight weightArg
+	incr   bool
+	negate bool
+}
+
+func setWeight(weight weightArg) weightWrite {
+	return weightWrite{weight: weight}
+}
+
+func incrWeight(weight weightArg) weightWrite {
+	return weightWrite{weight: weight, incr: true}
+}
+
+func decrWeight(weight weightArg) weightWrite {
+	return weightWrite{weight: weight, incr: true, negate: true}
+}
+
+// apply returns the weight to write given the current weight
+func (w weightWrite) apply(current float64) float64 {
+	weight := w.weight.value
+	if w.negate {
+		weight = -weight
+	}
+	if w.incr {
+		return current + weight
+	}
+	return weight
+}
+
+// parseNegativePolicy returns the policy with the given name
+func parseNegativePolicy(name string) (negativePolicy, error) {
+	switch policy := negativePolicy(strings.ToLower(name)); policy {
+	case negativeAllow, negativeClamp, negativeReject, negativeDelete:
+		return policy, nil
+	}
+	return negativeDefault, errors.New("invalid negative weight policy " + name + " (allow, clamp, reject or delete)")
+}
+
+// applyNegative will apply the policy to a weight about to be written,
+// returning the weight to write and whether the edge or vertex weight should
+// be removed instead, or an error when the policy rejects the weight
+func applyNegative(policy negativePolicy, weight float64) (float64, bool, error) {
+	switch {
+	case policy == negativeReject && weight < 0:
+		return weight, false, errors.New("negative weight " + strconv.FormatFloat(weight, 'g', -1, 64) + " rejected")
+	case policy == negativeDelete && weight <= 0:
+		return weight, true, nil
+	case policy == negativeClamp && weight < 0:
+		return 0, false, nil
+	}
+	return weight, false, nil
+}
+
+// resolveNegative returns the policy to apply for a write, the policy of the
+// graph unless one is given
+func (m *MemoryGraphDb) resolveNegative(policy negativePolicy) negativePolicy {
+	if policy == negativeDefault {
+		return m.negative
+	}
+	return policy
+}
+
+// writeLabeledEdge will write the weight of the edge with the given label
+// according to the negative weight policy
+func (m *MemoryGraphDb) writeLabeledEdge(policy negativePolicy, label string, from string, to string, write weightWrite) error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, currentExact := float64(0), int64(0)
+	edgeIndex, exists := m.existingEdge(label, from, to)
+	if exists {
+		current, currentExact = m.edgeWeight(edgeIndex), m.edgeExact(edgeIndex)
+	}
+
+	weight, exact, remove, err := m.resolveWrite(policy, write, current, currentExact)
+	if err != nil {
+		return errors.New(err.Error() + " for the edge " + from + " " + to)
+	}
+	if remove || (m.negligible(weight) && (!exists || m.negligibleEdge(edgeIndex, weight))) {
+		if exists {
+			m.removeEdge(label, m.vertices[from], m.vertices[to])
+		}
+		return nil
+	}
+
+	// set the edge weight now that we have the proper index
+	ef_t := m.getEdgeIndex(label, from, to)
+	m.setEdgeWeight(ef_t, weight, exact)
+	delete(m.edgeWindows, ef_t)
+	m.touchEdge(ef_t)
+	return nil
+}
+
+// writeVertex will write the weight of the vertex according to the negative weight policy
+func (m *MemoryGraphDb) writeVertex(policy negativePolicy, vertex string, write weightWrite) error {
+	m.Lock()
+	defer m.Unlock()
+
+	current, currentExact := float64(0), int64(0)
+	f, exists := m.liveVertex(vertex)
+	if exists {
+		current, currentExact = m.vertexWeight(f), m.vertexExact(f)
+	}
+
+	weight, exact, remove, err := m.resolveWrite(policy, write, current, currentExact)
+	if err != nil {
+		return errors.New(err.Error() + " for the vertex " + vertex)
+	}
+	if remove || m.negligible(weight) {
+		if exists {
+			delete(m.vertexWeights, f)
+			delete(m.vertexInts, f)
+			delete(m.vertexTimes, f)
+		}
+		return nil
+	}
+
+	// set the vertex weight now that we have an index
+	f = m.getVertexIndex(vertex)
+	m.setVertexWeight(f, weight, exact)
+	m.touchVertex(f)
+	return nil
+}
+
+// setNegative will change the negative weight policy of the graph, existing
+// weights are left as they are
+func (m *MemoryGraphDb) setNegative(policy negativePolicy) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.negative = policy
+}
+
+// getNegative returns the negative weight policy of the graph
+func (m *MemoryGraphDb) getNegative() negativePolicy {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.negative
+}
+
+// negativeView is a graph whose weight writes apply a negative weight policy
+// in place of the policy of the graph
+type negativeView struct {
+	DB
+	policy negativePolicy
+}
+
+func (v negativeView) setEdge(from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, "", from, to, setWeight(weight))
+}
+
+func (v negativeView) incrEdge(from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, "", from, to, incrWeight(weight))
+}
+
+func (v negativeView) decrEdge(from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, "", from, to, decrWeight(weight))
+}
+
+func (v negativeView) setLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, label, from, to, setWeight(weight))
+}
+
+func (v negativeView) incrLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, label, from, to, incrWeight(weight))
+}
+
+func (v negativeView) decrLabeledEdge(label string, from string, to string, weight weightArg) error {
+	return v.DB.writeLabeledEdge(v.policy, label, from, to, decrWeight(weight))
+}
+
+func (v negativeView) setVertex(vertex string, weight weightArg) error {
+	return v.DB.writeVertex(v.policy, vertex, setWeight(weight))
+}
+
+func (v negativeView) incrVertex(vertex string, weight weightArg) error {
+	return v.DB.writeVertex(v.policy, vertex, incrWeight(weight))
+}
+
+func (v negativeView) decrVertex(vertex string, weight weightArg) error {
+	return v.DB.writeVertex(v.policy, vertex, decrWeight(weight))
+}
+
+// NegativePolicy will set or return the negative weight policy of the graph,
+// or run a weight write with the policy in place of the policy of the graph
+func (b *BGraphBackend) NegativePolicy(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		client.WriteString(string(db.getNegative()))
+		client.Flush()
+		return nil
+	}
+
+	policy, err := parseNegativePolicy(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	if len(d) == 1 {
+		db.setNegative(policy)
+		client.WriteString("OK")
+		client.Flush()
+		return nil
+	}
+
+	cmd := strings.ToLower(string(d[1]))
+	if !negativeCommands[cmd] {
+		client.WriteError(errors.New("negative does not support the command " + cmd))
+		client.Flush()
+		return nil
+	}
+
+	return b.dispatch(negativeView{db, policy}, d[1:], client)
+}
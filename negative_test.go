@@ -0,0 +1,105 @@
+package bgraph
+
+import "testing"
+
+func TestApplyNegative(t *testing.T) {
+	tests := []struct {
+		policy negativePolicy
+		weight float64
+		want   float64
+		remove bool
+		err    bool
+	}{
+		{negativeAllow, 3, 3, false, false},
+		{negativeAllow, -3, -3, false, false},
+		{negativeClamp, 3, 3, false, false},
+		{negativeClamp, -3, 0, false, false},
+		{negativeClamp, 0, 0, false, false},
+		{negativeReject, 3, 3, false, false},
+		{negativeReject, 0, 0, false, false},
+		{negativeReject, -3, -3, false, true},
+		{negativeDelete, 3, 3, false, false},
+		{negativeDelete, 0, 0, true, false},
+		{negativeDelete, -3, -3, true, false},
+	}
+
+	for _, tt := range tests {
+		got, remove, err := applyNegative(tt.policy, tt.weight)
+		if (err != nil) != tt.err {
+			t.Errorf("applyNegative(%s, %v) error = %v, want error %v", tt.policy, tt.weight, err, tt.err)
+			continue
+		}
+		if got != tt.want || remove != tt.remove {
+			t.Errorf("applyNegative(%s, %v) = %v %v, want %v %v", tt.policy, tt.weight, got, remove, tt.want, tt.remove)
+		}
+	}
+}
+
+func TestParseNegativePolicy(t *testing.T) {
+	for _, name := range []string{"allow", "CLAMP", "Reject", "delete"} {
+		if _, err := parseNegativePolicy(name); err != nil {
+			t.Errorf("parseNegativePolicy(%q) error: %v", name, err)
+		}
+	}
+	if _, err := parseNegativePolicy("ignore"); err == nil {
+		t.Errorf("parseNegativePolicy(ignore) should fail")
+	}
+}
+
+func TestNegativeWrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		graph  negativePolicy
+		policy negativePolicy // policy given for the write in place of the policy of the graph
+		weight float64        // weight of the edge before it is decremented by 5
+		want   float64
+		exists bool
+		err    bool
+	}{
+		{"allow", negativeAllow, negativeDefault, 2, -3, true, false},
+		{"clamp", negativeClamp, negativeDefault, 2, 0, true, false},
+		{"reject", negativeReject, negativeDefault, 2, 2, true, true},
+		{"reject to zero", negativeReject, negativeDefault, 5, 0, true, false},
+		{"delete", negativeDelete, negativeDefault, 2, 0, false, false},
+		{"delete at zero", negativeDelete, negativeDefault, 5, 0, false, false},
+		{"delete above zero", negativeDelete, negativeDefault, 7, 2, true, false},
+		{"write overrides graph", negativeAllow, negativeDelete, 2, 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			m.setEdge("a", "b", floatWeight(tt.weight))
+			m.setNegative(tt.graph)
+
+			err := m.writeLabeledEdge(tt.policy, "", "a", "b", decrWeight(floatWeight(5)))
+			if (err != nil) != tt.err {
+				t.Fatalf("write error = %v, want error %v", err, tt.err)
+			}
+			weight, exists := m.findEdges("a")["b"]
+			if exists != tt.exists || weight != tt.want {
+				t.Errorf("edge = %v %v, want %v %v", weight, exists, tt.want, tt.exists)
+			}
+		})
+	}
+}
+
+func TestNegativeVertexWrites(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setNegative(negativeDelete)
+	m.setVertex("a", floatWeight(2))
+	if err := m.decrVertex("a", floatWeight(2)); err != nil {
+		t.Fatalf("decrVertex error: %v", err)
+	}
+	if weight, ok := m.getVertex("a"); ok && weight != 0 {
+		t.Errorf("getVertex = %v, want the weight removed", weight)
+	}
+	if _, ok := m.vertexWeights[m.vertices["a"]]; ok {
+		t.Errorf("vertex weight of a was kept at zero under the delete policy")
+	}
+
+	m.setNegative(negativeReject)
+	if err := m.setVertex("a", floatWeight(-1)); err == nil {
+		t.Errorf("setVertex below zero should be rejected")
+	}
+}
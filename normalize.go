@@ -0,0 +1,142 @@
+package bgraph
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// transitionProbabilities will divide the weight of each edge by the total
+// weight of the edges, nil is returned when the total is not positive
+func transitionProbabilities(edges map[string]float64) map[string]float64 {
+	total := float64(0)
+	for _, weight := range edges {
+		total += weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	results := make(map[string]float64, len(edges))
+	for to, weight := range edges {
+		results[to] = weight / total
+	}
+	return results
+}
+
+// exactTransitionProbabilities will divide the exact weight of each edge by
+// the exact total weight of a graph with integer weights, rounding only the
+// quotient to a float, nil is returned when the total is not positive
+func exactTransitionProbabilities(edges map[string]int64) map[string]float64 {
+	total := new(big.Int)
+	for _, weight := range edges {
+		total.Add(total, big.NewInt(weight))
+	}
+	if total.Sign() <= 0 {
+		return nil
+	}
+
+	results := make(map[string]float64, len(edges))
+	for to, weight := range edges {
+		results[to], _ = new(big.Rat).SetFrac(big.NewInt(weight), total).Float64()
+	}
+	return results
+}
+
+func (m *MemoryGraphDb) normalizeEdges(vertex string) error {
+	return m.normalizeLabeledEdges("", vertex)
+}
+
+// normalizeLabeledEdges will rewrite the weights of the labeled edges from the
+// vertex so that they sum to one, edges whose weights do not have a positive
+// total are left unchanged. Graphs with integer weights cannot hold the
+// fractions and negative weights have no proportion, both return an error.
+func (m *MemoryGraphDb) normalizeLabeledEdges(label string, vertex string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.mode == weightInteger {
+		return errors.New("normalize requires float weights")
+	}
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return nil
+	}
+
+	m.purgeEdges(f)
+	var vertexEdges map[int64]int64
+	for _, adj := range m.selectAdjacency([]string{label}) {
+		vertexEdges = adj[f]
+	}
+	total := float64(0)
+	for t, edgeIndex := range vertexEdges {
+		weight := m.edgeWeight(edgeIndex)
+		if weight < 0 {
+			return errors.New("normalize requires non-negative weights for the edge " + vertex + " " + m.r_vertices[t])
+		}
+		total += weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	for _, edgeIndex := range vertexEdges {
+		m.edgeWeights[edgeIndex] = m.edgeWeight(edgeIndex) / total
+		delete(m.edgeWindows, edgeIndex)
+		m.touchEdge(edgeIndex)
+	}
+	return nil
+}
+
+// TransitionEdges will return the edges from the vertices with each weight
+// divided by the total weight of the returned edges of the vertex
+func (b *BGraphBackend) TransitionEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("*en takes at least 1 parameter (*en vertex [vertex ...])"))
+		client.Flush()
+		return nil
+	}
+
+	// graphs with integer weights divide their exact weights
+	integer := db.getWeightMode() == weightInteger
+	vertexEdges := make(map[string]map[string]float64)
+	for _, k := range d {
+		key := string(k)
+		var edges map[string]float64
+		if integer {
+			exact, err := db.findExactEdges(key)
+			if err != nil {
+				client.WriteError(err)
+				client.Flush()
+				return nil
+			}
+			edges = exactTransitionProbabilities(exact)
+		} else {
+			edges = transitionProbabilities(db.findEdges(key))
+		}
+		if edges != nil {
+			vertexEdges[key] = edges
+		}
+	}
+
+	if len(vertexEdges) > 0 {
+		client.WriteJson(vertexEdges)
+	} else {
+		client.WriteNull()
+	}
+	client.Flush()
+	return nil
+}
+
+// NormalizeEdges will rewrite the weights of the edges from the vertices so
+// that the edges of each vertex sum to one
+func (b *BGraphBackend) NormalizeEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	for _, k := range d {
+		if err := db.normalizeEdges(string(k)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
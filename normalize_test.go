@@ -0,0 +1,55 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransitionProbabilities(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges map[string]float64
+		want  map[string]float64
+	}{
+		{"no edges", nil, nil},
+		{"zero total", map[string]float64{"b": 0}, nil},
+		{"negative total", map[string]float64{"b": 1, "c": -2}, nil},
+		{"proportions", map[string]float64{"b": 1, "c": 3}, map[string]float64{"b": 0.25, "c": 0.75}},
+	}
+	for _, tt := range tests {
+		if got := transitionProbabilities(tt.edges); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: transitionProbabilities = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLabeledEdges(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(1))
+	m.setEdge("a", "c", floatWeight(3))
+	m.setLabeledEdge("x", "a", "b", floatWeight(5))
+
+	if err := m.normalizeEdges("a"); err != nil {
+		t.Fatalf("normalizeEdges error: %v", err)
+	}
+	if got, want := m.findEdges("a"), map[string]float64{"b": 0.25, "c": 0.75}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized edges = %v, want %v", got, want)
+	}
+	if got, want := m.findLabeledEdges([]string{"x"}, "a"), map[string]float64{"b": 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("labeled edges = %v, want %v", got, want)
+	}
+	if err := m.normalizeEdges("missing"); err != nil {
+		t.Errorf("normalizing a missing vertex error: %v", err)
+	}
+
+	m.setEdge("d", "e", floatWeight(-1))
+	if err := m.normalizeEdges("d"); err == nil {
+		t.Errorf("normalizing negative weights should fail")
+	}
+
+	i := integerGraph(t)
+	i.setEdge("a", "b", mustWeight(t, "2"))
+	if err := i.normalizeEdges("a"); err == nil {
+		t.Errorf("normalizing integer weights should fail")
+	}
+}
@@ -0,0 +1,552 @@
+package bgraph
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// queryStep binds the next node of the pattern by following an edge from a
+// node that is already bound, inbound steps follow the edges pointing into the
+// bound vertex by walking the vertices with edges into it
+type queryStep struct {
+	edge    int
+	from    int
+	to      int
+	inbound bool
+}
+
+// queryPlan is the order in which the nodes of the pattern are bound, the
+// start node is bound from its candidates and every other node by a step
+type queryPlan struct {
+	start      int
+	access     string
+	candidates []int64
+	steps      []queryStep
+}
+
+// queryRef is an edge bound by a step and the vertex at its other end
+type queryRef struct {
+	vertex int64
+	edge   int64
+}
+
+// queryMatch is the vertex bound to every node and the edge bound to every
+// edge of the pattern
+type queryMatch struct {
+	vertices []int64
+	edges    []int64
+	inexact  *bool // a weight was read that its float does not hold exactly
+}
+
+// startCandidates will return the vertices a node can be bound to without
+// following an edge using a name or secondary index lookup, nothing is
+// returned when none of the conditions of the node can use a lookup
+func (m *MemoryGraphDb) startCandidates(q *graphQuery, node int) (string, []int64) {
+	name := q.nodes[node].name
+	access := ""
+	var best []int64
+	for _, cond := range q.conds {
+		if cond.variable != name {
+			continue
+		}
+
+		var candidates []int64
+		var method string
+		if cond.field == "" && cond.op == "=" {
+			method = "name lookup " + cond.String()
+			if s, ok := cond.value.(string); ok {
+				if f, ok := m.vertices[s]; ok {
+					candidates = []int64{f}
+				}
+			}
+		} else if idx, ok := m.indexes[cond.field]; ok && cond.field != "weight" {
+			switch {
+			case cond.op == "=" && !idx.sorted:
+				method = "hash index " + cond.String()
+				for f := range idx.hash[cond.value] {
+					candidates = append(candidates, f)
+				}
+			case cond.op != "!=" && idx.sorted:
+				method = "sorted index " + cond.String()
+				i, end := 0, idx.entries.length
+				if cond.op != "<" && cond.op != "<=" {
+					i = idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, cond.value) < 0 })
+				}
+				if cond.op != ">" && cond.op != ">=" {
+					end = idx.entries.countBefore(func(e indexEntry) bool { return compareValues(e.value, cond.value) <= 0 })
+				}
+				for x := idx.entries.at(i); x != nil && i < end; x, i = x.next[0].node, i+1 {
+					candidates = append(candidates, x.entry.vertex)
+				}
+			default:
+				continue
+			}
+		} else {
+			continue
+		}
+
+		if access == "" || len(candidates) < len(best) {
+			access, best = method, candidates
+		}
+	}
+
+	return access, best
+}
+
+// planQuery will start from the node with the fewest candidates, or scan every
+// vertex for the first node when no lookup applies, and then bind the nodes to
+// its right followed by the nodes to its left
+func (m *MemoryGraphDb) planQuery(q *graphQuery) queryPlan {
+	plan := queryPlan{start: -1}
+	for i := range q.nodes {
+		access, candidates := m.startCandidates(q, i)
+		if access != "" && (plan.start < 0 || len(candidates) < len(plan.candidates)) {
+			plan.start, plan.access, plan.candidates = i, access, candidates
+		}
+	}
+	if plan.start < 0 {
+		plan.start, plan.access = 0, "full scan"
+		plan.candidates = make([]int64, 0, len(m.r_vertices))
+		for f := range m.r_vertices {
+			plan.candidates = append(plan.candidates, f)
+		}
+	}
+
+	for i := plan.start; i < len(q.edges); i++ {
+		plan.steps = append(plan.steps, queryStep{i, i, i + 1, q.edges[i].reverse})
+	}
+	for i := plan.start - 1; i >= 0; i-- {
+		plan.steps = append(plan.steps, queryStep{i, i + 1, i, !q.edges[i].reverse})
+	}
+	return plan
+}
+
+// readWeight returns the weight read by the match, flagging the match when
+// the weight is an integer beyond what its float holds exactly
+func (m *MemoryGraphDb) readWeight(match queryMatch, weight float64) float64 {
+	if !m.exactFloat(weight) {
+		*match.inexact = true
+	}
+	return weight
+}
+
+// nodeValue returns the name, label, weight or a property of the vertex
+func (m *MemoryGraphDb) nodeValue(match queryMatch, f int64, field string) (interface{}, bool) {
+	switch field {
+	case "":
+		name, ok := m.r_vertices[f]
+		return name, ok
+	case "weight":
+		return m.readWeight(match, m.vertexWeight(f)), true
+	}
+	return m.vertexProperty(f, field)
+}
+
+// edgeValue returns the weight or a property of the edge
+func (m *MemoryGraphDb) edgeValue(match queryMatch, edgeIndex int64, field string) (interface{}, bool) {
+	if field == "" || field == "weight" {
+		return m.readWeight(match, m.edgeWeight(edgeIndex)), true
+	}
+	value, ok := m.edgeProps[edgeIndex][field]
+	return value, ok
+}
+
+// queryValue returns the value of a variable or one of its fields within the match
+func (m *MemoryGraphDb) queryValue(q *graphQuery, match queryMatch, variable string, field string) (interface{}, bool) {
+	for i, node := range q.nodes {
+		if node.name == variable {
+			return m.nodeValue(match, match.vertices[i], field)
+		}
+	}
+	for i, edge := range q.edges {
+		if edge.name == variable {
+			return m.edgeValue(match, match.edges[i], field)
+		}
+	}
+	return nil, false
+}
+
+// holds returns true when the value satisfies the condition, values of a
+// different type than the condition only satisfy !=
+func (c queryCond) holds(value interface{}, ok bool) bool {
+	if !ok {
+		return c.op == "!="
+	}
+	_, isNumber := value.(float64)
+	_, wantNumber := c.value.(float64)
+	if isNumber != wantNumber {
+		return c.op == "!="
+	}
+
+	cmp := compareValues(value, c.value)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// bindNode will bind the vertex to the node when it satisfies the conditions
+// of the node and agrees with the other nodes bound to the same variable
+func (m *MemoryGraphDb) bindNode(q *graphQuery, match queryMatch, node int, f int64) bool {
+	name := q.nodes[node].name
+	for i, other := range q.nodes {
+		if other.name == name && i != node && match.vertices[i] >= 0 && match.vertices[i] != f {
+			return false
+		}
+	}
+	for _, cond := range q.conds {
+		if cond.variable == name && !cond.holds(m.nodeValue(match, f, cond.field)) {
+			return false
+		}
+	}
+	match.vertices[node] = f
+	return true
+}
+
+// bindEdge will bind the edge when it satisfies the conditions of the edge
+func (m *MemoryGraphDb) bindEdge(q *graphQuery, match queryMatch, edge int, edgeIndex int64) bool {
+	name := q.edges[edge].name
+	for _, cond := range q.conds {
+		if cond.variable == name && !cond.holds(m.edgeValue(match, edgeIndex, cond.field)) {
+			return false
+		}
+	}
+	match.edges[edge] = edgeIndex
+	return true
+}
+
+// matchQuery will call fn with every match of the pattern following the plan
+// until fn returns false. Graphs with integer weights return errInexactWeight
+// once a weight beyond 2^53 - 1 was read as the matches may then be wrong.
+func (m *MemoryGraphDb) matchQuery(q *graphQuery, plan queryPlan, fn func(queryMatch) bool) error {
+	match := queryMatch{make([]int64, len(q.nodes)), make([]int64, len(q.edges)), new(bool)}
+	for i := range match.vertices {
+		match.vertices[i] = -1
+	}
+
+	inbound := func(label string, t int64) []queryRef {
+		var refs []queryRef
+		adjs := m.selectAdjacency([]string{label})
+		for f := range m.inbound[t] {
+			for _, adj := range adjs {
+				if edgeIndex, ok := adj[f][t]; ok {
+					refs = append(refs, queryRef{f, edgeIndex})
+				}
+			}
+		}
+		return refs
+	}
+
+	var expand func(step int) bool
+	expand = func(step int) bool {
+		if step == len(plan.steps) {
+			return fn(match)
+		}
+
+		s := plan.steps[step]
+		label := q.edges[s.edge].label
+		var refs []queryRef
+		if s.inbound {
+			refs = inbound(label, match.vertices[s.from])
+		} else {
+			for _, adj := range m.selectAdjacency([]string{label}) {
+				for t, edgeIndex := range adj[match.vertices[s.from]] {
+					refs = append(refs, queryRef{t, edgeIndex})
+				}
+			}
+		}
+
+		for _, ref := range refs {
+			if m.bindEdge(q, match, s.edge, ref.edge) && m.bindNode(q, match, s.to, ref.vertex) {
+				if !expand(step + 1) {
+					return false
+				}
+			}
+			match.vertices[s.to] = -1
+		}
+		return true
+	}
+
+	for _, f := range plan.candidates {
+		if _, ok := m.r_vertices[f]; !ok {
+			continue
+		}
+		if m.bindNode(q, match, plan.start, f) && !expand(0) {
+			break
+		}
+		match.vertices[plan.start] = -1
+	}
+	if *match.inexact {
+		return errInexactWeight
+	}
+	return nil
+}
+
+// queryGroup accumulates the aggregates of the rows sharing the same plain values
+type queryGroup struct {
+	row    map[string]interface{}
+	counts []int
+	sums   []float64
+}
+
+// runQuery will match the pattern and return the requested items of every
+// match, or of every group of matches when aggregates are returned
+func (m *MemoryGraphDb) runQuery(q *graphQuery) ([]map[string]interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	aggregate := false
+	for _, item := range q.items {
+		aggregate = aggregate || item.fn != ""
+	}
+	early := !aggregate && q.order < 0 && q.limit >= 0
+
+	rows := make([]map[string]interface{}, 0)
+	groups := make(map[string]*queryGroup)
+	order := make([]string, 0)
+	err := m.matchQuery(q, m.planQuery(q), func(match queryMatch) bool {
+		if early && len(rows) >= q.limit {
+			return false
+		}
+
+		if !aggregate {
+			row := make(map[string]interface{}, len(q.items))
+			for _, item := range q.items {
+				row[item.String()], _ = m.queryValue(q, match, item.variable, item.field)
+			}
+			rows = append(rows, row)
+			return true
+		}
+
+		keys := make([]string, 0, len(q.items))
+		for _, item := range q.items {
+			if item.fn == "" {
+				value, _ := m.queryValue(q, match, item.variable, item.field)
+				keys = append(keys, fmt.Sprintf("%T:%v", value, value))
+			}
+		}
+		key := strings.Join(keys, "\x00")
+		group, ok := groups[key]
+		if !ok {
+			group = &queryGroup{make(map[string]interface{}), make([]int, len(q.items)), make([]float64, len(q.items))}
+			groups[key] = group
+			order = append(order, key)
+		}
+
+		for i, item := range q.items {
+			if item.variable == "" {
+				group.counts[i]++
+				continue
+			}
+			value, ok := m.queryValue(q, match, item.variable, item.field)
+			if !ok {
+				continue
+			}
+			current, seen := group.row[item.String()]
+			switch item.fn {
+			case "":
+				group.row[item.String()] = value
+			case "count":
+				group.counts[i]++
+			case "sum", "avg":
+				if number, ok := value.(float64); ok {
+					group.sums[i] += number
+					group.counts[i]++
+					if !m.exactFloat(group.sums[i]) {
+						*match.inexact = true
+					}
+				}
+			case "min":
+				if !seen || compareValues(value, current) < 0 {
+					group.row[item.String()] = value
+				}
+			case "max":
+				if !seen || compareValues(value, current) > 0 {
+					group.row[item.String()] = value
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range order {
+		group := groups[key]
+		for i, item := range q.items {
+			switch item.fn {
+			case "count":
+				group.row[item.String()] = group.counts[i]
+			case "sum":
+				group.row[item.String()] = group.sums[i]
+			case "avg":
+				if group.counts[i] > 0 {
+					group.row[item.String()] = group.sums[i] / float64(group.counts[i])
+				} else {
+					group.row[item.String()] = nil
+				}
+			default:
+				if _, ok := group.row[item.String()]; !ok {
+					group.row[item.String()] = nil
+				}
+			}
+		}
+		rows = append(rows, group.row)
+	}
+
+	if q.order >= 0 {
+		column := q.items[q.order].String()
+		sort.SliceStable(rows, func(i, j int) bool {
+			a, b := rows[i][column], rows[j][column]
+			if q.desc {
+				a, b = b, a
+			}
+			return compareRowValues(a, b) < 0
+		})
+	}
+	if q.limit >= 0 && len(rows) > q.limit {
+		rows = rows[:q.limit]
+	}
+	return rows, nil
+}
+
+// compareRowValues orders the values of a column with missing values first
+func compareRowValues(a interface{}, b interface{}) int {
+	if count, ok := a.(int); ok {
+		a = float64(count)
+	}
+	if count, ok := b.(int); ok {
+		b = float64(count)
+	}
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return compareValues(a, b)
+}
+
+// explainQuery will describe the plan that would be used to run the query
+func (m *MemoryGraphDb) explainQuery(q *graphQuery) []string {
+	m.Lock()
+	defer m.Unlock()
+
+	plan := m.planQuery(q)
+	lines := []string{fmt.Sprintf("start (%s) by %s, %d candidates", q.nodes[plan.start].name, plan.access, len(plan.candidates))}
+	lines = append(lines, describeConds(q, q.nodes[plan.start].name)...)
+	for _, s := range plan.steps {
+		edge := q.edges[s.edge]
+		method := "out edges"
+		if s.inbound {
+			method = "in edges"
+		}
+		label := ""
+		if edge.label != "" {
+			label = " with label " + edge.label
+		}
+		lines = append(lines, fmt.Sprintf("expand (%s) to (%s) by %s%s", q.nodes[s.from].name, q.nodes[s.to].name, method, label))
+		lines = append(lines, describeConds(q, edge.name)...)
+		lines = append(lines, describeConds(q, q.nodes[s.to].name)...)
+	}
+
+	plain := make([]string, 0)
+	aggregates := make([]string, 0)
+	for _, item := range q.items {
+		if item.fn == "" {
+			plain = append(plain, item.String())
+		} else {
+			aggregates = append(aggregates, item.String())
+		}
+	}
+	if len(aggregates) > 0 {
+		line := "aggregate " + strings.Join(aggregates, ", ")
+		if len(plain) > 0 {
+			line += " group by " + strings.Join(plain, ", ")
+		}
+		lines = append(lines, line)
+	} else {
+		lines = append(lines, "return "+strings.Join(plain, ", "))
+	}
+	if q.order >= 0 {
+		direction := "asc"
+		if q.desc {
+			direction = "desc"
+		}
+		lines = append(lines, "order by "+q.items[q.order].String()+" "+direction)
+	}
+	if q.limit >= 0 {
+		lines = append(lines, "limit "+strconv.Itoa(q.limit))
+	}
+	return lines
+}
+
+// describeConds will describe the filters applied when the variable is bound
+func describeConds(q *graphQuery, variable string) []string {
+	lines := make([]string, 0)
+	for _, cond := range q.conds {
+		if cond.variable == variable {
+			lines = append(lines, "  filter "+cond.String())
+		}
+	}
+	return lines
+}
+
+// Query will match a path pattern against the graph returning the requested
+// items of every match, or the plan of the query when prefixed with EXPLAIN
+func (b *BGraphBackend) Query(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("query takes at least 1 parameter (query [explain] match pattern [where cond [and cond ...]] return item [, item ...] [order by item [asc|desc]] [limit n])"))
+		client.Flush()
+		return nil
+	}
+
+	text := make([]string, len(d))
+	for i, k := range d {
+		text[i] = string(k)
+	}
+	q, err := parseQuery(strings.Join(text, " "))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	if q.explain {
+		client.WriteJson(db.explainQuery(q))
+		client.Flush()
+		return nil
+	}
+
+	rows, err := db.runQuery(q)
+	if err != nil {
+		client.WriteError(err)
+	} else if len(rows) > 0 {
+		client.WriteJson(rows)
+	} else {
+		client.WriteNull()
+	}
+	client.Flush()
+	return nil
+}
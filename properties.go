@@ -0,0 +1,248 @@
+package bgraph
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// properties are the key/value pairs attached to a vertex or an edge, values
+// are either strings or float64 numbers
+type properties map[string]interface{}
+
+// parsePropertyValue will store finite numeric values as numbers and anything
+// else as a string, so NaN and infinities stay strings that compare and encode
+func parsePropertyValue(value string) interface{} {
+	if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsNaN(number) && !math.IsInf(number, 0) {
+		return number
+	}
+	return value
+}
+
+// setVertexLabel will set the label (type) of the vertex, an empty label removes it
+func (m *MemoryGraphDb) setVertexLabel(label string, vertex string) {
+	m.Lock()
+	defer m.Unlock()
+
+	f := m.getVertexIndex(vertex)
+	if label == "" {
+		delete(m.vertexLabels, f)
+	} else {
+		m.vertexLabels[f] = label
+	}
+	m.reindexVertex(f)
+}
+
+// setVertexProperties will set the given properties of the vertex
+func (m *MemoryGraphDb) setVertexProperties(vertex string, props properties) {
+	m.Lock()
+	defer m.Unlock()
+
+	f := m.getVertexIndex(vertex)
+	existing, ok := m.vertexProps[f]
+	if !ok {
+		existing = make(properties, len(props))
+		m.vertexProps[f] = existing
+	}
+	for key, value := range props {
+		existing[key] = value
+	}
+	m.reindexVertex(f)
+}
+
+// delVertexProperties will remove the given properties from the vertex
+func (m *MemoryGraphDb) delVertexProperties(vertex string, keys []string) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return
+	}
+
+	props := m.vertexProps[f]
+	for _, key := range keys {
+		delete(props, key)
+	}
+	if len(props) == 0 {
+		delete(m.vertexProps, f)
+	}
+	m.reindexVertex(f)
+}
+
+// getVertexProperties will return the label and a copy of the properties of
+// the vertex along with whether the vertex exists
+func (m *MemoryGraphDb) getVertexProperties(vertex string) (string, properties, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return "", nil, false
+	}
+
+	props := make(properties, len(m.vertexProps[f]))
+	for key, value := range m.vertexProps[f] {
+		props[key] = value
+	}
+	return m.vertexLabels[f], props, true
+}
+
+// edgeDetail is the weight of an edge along with its properties
+type edgeDetail struct {
+	Weight     float64    `json:"weight"`
+	Properties properties `json:"properties,omitempty"`
+	exact      int64      // exact weight of an edge in a graph with integer weights
+	integer    bool
+}
+
+// MarshalJSON will write the exact weight in place of the float when the edge
+// is in a graph with integer weights
+func (d edgeDetail) MarshalJSON() ([]byte, error) {
+	var weight interface{} = d.Weight
+	if d.integer {
+		weight = d.exact
+	}
+	return json.Marshal(struct {
+		Weight     interface{} `json:"weight"`
+		Properties properties  `json:"properties,omitempty"`
+	}{weight, d.Properties})
+}
+
+func (m *MemoryGraphDb) setEdgeProperties(from string, to string, props properties) {
+	m.setLabeledEdgeProperties("", from, to, props)
+}
+
+// setLabeledEdgeProperties will set the given properties of the labeled edge,
+// creating the edge with a weight of zero if it does not exist yet
+func (m *MemoryGraphDb) setLabeledEdgeProperties(label string, from string, to string, props properties) {
+	m.Lock()
+	defer m.Unlock()
+
+	ef_t := m.getEdgeIndex(label, from, to)
+	existing, ok := m.edgeProps[ef_t]
+	if !ok {
+		existing = make(properties, len(props))
+		m.edgeProps[ef_t] = existing
+	}
+	for key, value := range props {
+		existing[key] = value
+	}
+}
+
+func (m *MemoryGraphDb) delEdgeProperties(from string, to string, keys []string) {
+	m.delLabeledEdgeProperties("", from, to, keys)
+}
+
+// delLabeledEdgeProperties will remove the given properties from the labeled edge
+func (m *MemoryGraphDb) delLabeledEdgeProperties(label string, from string, to string, keys []string) {
+	m.Lock()
+	defer m.Unlock()
+
+	edgeIndex, ok := m.existingEdge(label, from, to)
+	if !ok {
+		return
+	}
+
+	props := m.edgeProps[edgeIndex]
+	for _, key := range keys {
+		delete(props, key)
+	}
+	if len(props) == 0 {
+		delete(m.edgeProps, edgeIndex)
+	}
+}
+
+func (m *MemoryGraphDb) getEdgeProperties(from string, to string) (edgeDetail, bool) {
+	return m.getLabeledEdgeProperties("", from, to)
+}
+
+// getLabeledEdgeProperties will return the weight and a copy of the
+// properties of the labeled edge along with whether the edge exists
+func (m *MemoryGraphDb) getLabeledEdgeProperties(label string, from string, to string) (edgeDetail, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	edgeIndex, ok := m.existingEdge(label, from, to)
+	if !ok {
+		return edgeDetail{}, false
+	}
+	return m.edgeDetail(edgeIndex), true
+}
+
+// existingEdge will return the index of the labeled edge between the two
+// vertices without creating either of them
+func (m *MemoryGraphDb) existingEdge(label string, from string, to string) (int64, bool) {
+	f, f_ok := m.liveVertex(from)
+	t, t_ok := m.liveVertex(to)
+	if !f_ok || !t_ok {
+		return 0, false
+	}
+	return m.liveEdge(label, f, t)
+}
+
+// edgeDetail will return the current weight and a copy of the properties of the edge
+func (m *MemoryGraphDb) edgeDetail(edgeIndex int64) edgeDetail {
+	detail := edgeDetail{Weight: m.edgeWeight(edgeIndex)}
+	if m.mode == weightInteger {
+		detail.exact, detail.integer = m.edgeExact(edgeIndex), true
+	}
+	if props, ok := m.edgeProps[edgeIndex]; ok {
+		detail.Properties = make(properties, len(props))
+		for key, value := range props {
+			detail.Properties[key] = value
+		}
+	}
+	return detail
+}
+
+func (m *MemoryGraphDb) findEdgesWithProperties(vertex string) (map[string]edgeDetail, error) {
+	return m.findLabeledEdgesWithProperties([]string{""}, vertex)
+}
+
+// findLabeledEdgesWithProperties will return the edges from the vertex with
+// the given labels along with their properties. Weights of edges to the same
+// vertex are summed across labels, exactly in a graph with integer weights,
+// and their properties merged.
+func (m *MemoryGraphDb) findLabeledEdgesWithProperties(labels []string, vertex string) (map[string]edgeDetail, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, f_ok := m.liveVertex(vertex)
+	if !f_ok {
+		return nil, nil
+	}
+
+	m.purgeEdges(f)
+	results := make(map[string]edgeDetail)
+	for _, adj := range m.selectAdjacency(labels) {
+		for vertexIndex, edgeIndex := range adj[f] {
+			to := m.r_vertices[vertexIndex]
+			detail := m.edgeDetail(edgeIndex)
+			if existing, ok := results[to]; ok {
+				existing.Weight += detail.Weight
+				if existing.integer {
+					sum, err := addExact(existing.exact, detail.exact)
+					if err != nil {
+						return nil, err
+					}
+					existing.exact = sum
+				}
+				if existing.Properties == nil {
+					existing.Properties = detail.Properties
+				} else {
+					for key, value := range detail.Properties {
+						existing.Properties[key] = value
+					}
+				}
+				detail = existing
+			}
+			results[to] = detail
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, nil
+	}
+	return results, nil
+}
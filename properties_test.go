@@ -0,0 +1,95 @@
+package bgraph
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEdgeProperties(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(2))
+	m.setEdgeProperties("a", "b", properties{"source": "import", "confidence": float64(0.9)})
+	m.setLabeledEdgeProperties("follows", "a", "c", properties{"created_at": float64(100)})
+
+	detail, ok := m.getEdgeProperties("a", "b")
+	if !ok || detail.Weight != 2 || !reflect.DeepEqual(detail.Properties, properties{"source": "import", "confidence": float64(0.9)}) {
+		t.Errorf("getEdgeProperties = %+v %v", detail, ok)
+	}
+
+	// properties create the edge with a weight of zero
+	detail, ok = m.getLabeledEdgeProperties("follows", "a", "c")
+	if !ok || detail.Weight != 0 || detail.Properties["created_at"] != float64(100) {
+		t.Errorf("getLabeledEdgeProperties = %+v %v", detail, ok)
+	}
+	if _, ok := m.getEdgeProperties("a", "c"); ok {
+		t.Errorf("labeled properties created an unlabeled edge")
+	}
+
+	// weights are written without changing the properties
+	m.incrEdge("a", "b", floatWeight(1))
+	if detail, _ := m.getEdgeProperties("a", "b"); detail.Weight != 3 || detail.Properties["source"] != "import" {
+		t.Errorf("getEdgeProperties after increment = %+v", detail)
+	}
+
+	m.delEdgeProperties("a", "b", []string{"source", "confidence"})
+	if detail, ok := m.getEdgeProperties("a", "b"); !ok || detail.Properties != nil {
+		t.Errorf("getEdgeProperties after removal = %+v %v", detail, ok)
+	}
+	m.delEdgeProperties("b", "a", []string{"source"})
+	if _, ok := m.getEdgeProperties("b", "a"); ok {
+		t.Errorf("removing properties created an edge")
+	}
+}
+
+func TestEdgesWithProperties(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(2))
+	m.setEdgeProperties("a", "b", properties{"source": "import"})
+	m.setLabeledEdge("follows", "a", "b", floatWeight(1))
+	m.setLabeledEdgeProperties("follows", "a", "b", properties{"since": float64(2020)})
+	m.setEdge("a", "c", floatWeight(5))
+
+	tests := []struct {
+		labels []string
+		want   map[string]edgeDetail
+	}{
+		{[]string{""}, map[string]edgeDetail{
+			"b": {Weight: 2, Properties: properties{"source": "import"}},
+			"c": {Weight: 5},
+		}},
+		{[]string{"follows"}, map[string]edgeDetail{
+			"b": {Weight: 1, Properties: properties{"since": float64(2020)}},
+		}},
+		{[]string{"*"}, map[string]edgeDetail{
+			"b": {Weight: 3, Properties: properties{"source": "import", "since": float64(2020)}},
+			"c": {Weight: 5},
+		}},
+		{[]string{"blocked"}, nil},
+	}
+
+	for _, tt := range tests {
+		got, err := m.findLabeledEdgesWithProperties(tt.labels, "a")
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("findLabeledEdgesWithProperties(%v) = %+v %v, want %+v", tt.labels, got, err, tt.want)
+		}
+	}
+}
+
+func TestEdgeDetailJSON(t *testing.T) {
+	tests := []struct {
+		detail edgeDetail
+		want   string
+	}{
+		{edgeDetail{Weight: 1.5}, `{"weight":1.5}`},
+		{edgeDetail{Weight: 2, Properties: properties{"k": "v"}}, `{"weight":2,"properties":{"k":"v"}}`},
+		{edgeDetail{Weight: 9007199254740992, exact: 9007199254740993, integer: true}, `{"weight":9007199254740993}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.detail)
+		if err != nil || string(data) != tt.want {
+			t.Errorf("json of %+v = %s %v, want %s", tt.detail, data, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package bgraph
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A query matches a linear path pattern against the graph and returns one row
+// per match, or one row per group when aggregates are returned:
+//
+//	[EXPLAIN] MATCH (a)-[w>5]->(b:item)<--(c)
+//	  [WHERE a = 'user:1' AND b.price <= 10 AND w.source = import]
+//	  RETURN a, b.price, count(*), sum(w) [ORDER BY sum(w) DESC] [LIMIT 10]
+//
+// Nodes are (name[:label]) and edges are --> or <-- (or the shorter -> and
+// <-) with an optional [name[:label|*][op value]] between the dashes, where
+// the label selects which labeled edges are followed. A node variable alone
+// refers to the vertex name and its fields to properties, except label and
+// weight which refer to the label and the weight of the vertex. An edge
+// variable alone refers to the edge weight and its fields to edge properties.
+
+// queryAggregates are the aggregate functions of the RETURN clause
+var queryAggregates = map[string]bool{"count": true, "sum": true, "min": true, "max": true, "avg": true}
+
+// queryNode is a node of the path pattern
+type queryNode struct {
+	name string
+}
+
+// queryEdge is an edge of the path pattern between the nodes at the same
+// position and the one after it, reverse edges point towards the first node
+type queryEdge struct {
+	name    string
+	label   string
+	reverse bool
+}
+
+// queryCond compares a node or edge variable (or one of its fields) to a value
+type queryCond struct {
+	variable string
+	field    string
+	op       string
+	value    interface{}
+}
+
+// queryItem is a single column of the RETURN clause
+type queryItem struct {
+	fn       string // aggregate function, empty for plain values
+	variable string // variable, empty for count(*)
+	field    string
+}
+
+// graphQuery is a parsed query
+type graphQuery struct {
+	explain bool
+	nodes   []queryNode
+	edges   []queryEdge
+	conds   []queryCond
+	items   []queryItem
+	order   int // index of the item to order by, -1 when unordered
+	desc    bool
+	limit   int // maximum number of rows, -1 when unlimited
+}
+
+func (c queryCond) String() string {
+	value := fmt.Sprint(c.value)
+	if s, ok := c.value.(string); ok {
+		value = strconv.Quote(s)
+	}
+	return queryField(c.variable, c.field) + " " + c.op + " " + value
+}
+
+func (i queryItem) String() string {
+	if i.fn == "" {
+		return queryField(i.variable, i.field)
+	}
+	if i.variable == "" {
+		return i.fn + "(*)"
+	}
+	return i.fn + "(" + queryField(i.variable, i.field) + ")"
+}
+
+func queryField(variable string, field string) string {
+	if field == "" {
+		return variable
+	}
+	return variable + "." + field
+}
+
+// queryToken is a single token of a query, symbols are kept as their text
+type queryToken struct {
+	kind rune // 'i' identifier, 'n' number, 's' string, 'p' punctuation, 0 end
+	text string
+}
+
+// lexQuery will split the query into identifiers, numbers, quoted strings and
+// punctuation, two character comparison operators are kept together
+func lexQuery(text string) ([]queryToken, error) {
+	tokens := make([]queryToken, 0)
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, queryToken{'i', string(runes[i:j])})
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E') {
+				j++
+			}
+			tokens = append(tokens, queryToken{'n', string(runes[i:j])})
+			i = j
+		case r == '\'' || r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				j++
+			}
+			if j == len(runes) {
+				return nil, errors.New("unterminated string in query")
+			}
+			tokens = append(tokens, queryToken{'s', string(runes[i+1 : j])})
+			i = j + 1
+		case strings.ContainsRune("!<>", r) && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, queryToken{'p', string(runes[i : i+2])})
+			i += 2
+		case strings.ContainsRune("()[],.*:-<>=", r):
+			tokens = append(tokens, queryToken{'p', string(r)})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q in query", r)
+		}
+	}
+	return append(tokens, queryToken{}), nil
+}
+
+// queryParser is a recursive descent parser over the tokens of a query
+type queryParser struct {
+	tokens    []queryToken
+	pos       int
+	anonymous int
+	query     *graphQuery
+}
+
+// parseQuery will parse the text of a query and validate its variables
+func parseQuery(text string) (*graphQuery, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens, query: &graphQuery{order: -1, limit: -1}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	if err := p.query.validate(); err != nil {
+		return nil, err
+	}
+	return p.query, nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+// keyword returns true and consumes the token when it is the given keyword
+func (p *queryParser) keyword(word string) bool {
+	if t := p.peek(); t.kind == 'i' && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// symbol returns true and consumes the token when it is the given punctuation
+func (p *queryParser) symbol(s string) bool {
+	if t := p.peek(); t.kind == 'p' && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(s string) error {
+	if !p.symbol(s) {
+		return p.unexpected("expected " + s)
+	}
+	return nil
+}
+
+func (p *queryParser) unexpected(expected string) error {
+	t := p.peek()
+	if t.kind == 0 {
+		return errors.New("unexpected end of query, " + expected)
+	}
+	return errors.New("unexpected " + t.text + " in query, " + expected)
+}
+
+func (p *queryParser) identifier() (string, error) {
+	if t := p.peek(); t.kind == 'i' {
+		p.pos++
+		return t.text, nil
+	}
+	return "", p.unexpected("expected a name")
+}
+
+func (p *queryParser) anonymousName() string {
+	p.anonymous++
+	return "_" + strconv.Itoa(p.anonymous)
+}
+
+func (p *queryParser) parse() error {
+	q := p.query
+	q.explain = p.keyword("explain")
+	if !p.keyword("match") {
+		return p.unexpected("expected MATCH")
+	}
+	if err := p.parsePath(); err != nil {
+		return err
+	}
+
+	if p.keyword("where") {
+		for {
+			cond, err := p.parseCond()
+			if err != nil {
+				return err
+			}
+			q.conds = append(q.conds, cond)
+			if !p.keyword("and") {
+				break
+			}
+		}
+	}
+
+	if !p.keyword("return") {
+		return p.unexpected("expected WHERE or RETURN")
+	}
+	for {
+		item, err := p.parseItem()
+		if err != nil {
+			return err
+		}
+		q.items = append(q.items, item)
+		if !p.symbol(",") {
+			break
+		}
+	}
+
+	if p.keyword("order") {
+		if !p.keyword("by") {
+			return p.unexpected("expected BY")
+		}
+		item, err := p.parseItem()
+		if err != nil {
+			return err
+		}
+		for i, returned := range q.items {
+			if returned == item {
+				q.order = i
+			}
+		}
+		if q.order < 0 {
+			return errors.New("order by " + item.String() + " must be one of the returned items")
+		}
+		if p.keyword("desc") {
+			q.desc = true
+		} else {
+			p.keyword("asc")
+		}
+	}
+
+	if p.keyword("limit") {
+		t := p.next()
+		limit, err := strconv.Atoi(t.text)
+		if t.kind != 'n' || err != nil || limit < 0 {
+			return errors.New("limit must be a positive integer")
+		}
+		q.limit = limit
+	}
+
+	if p.peek().kind != 0 {
+		return p.unexpected("expected the end of the query")
+	}
+	return nil
+}
+
+// parsePath will parse the alternating nodes and edges of the pattern
+func (p *queryParser) parsePath() error {
+	if err := p.parseNode(); err != nil {
+		return err
+	}
+	for {
+		t := p.peek()
+		if t.kind != 'p' || (t.text != "-" && t.text != "<") {
+			return nil
+		}
+		if err := p.parseEdge(); err != nil {
+			return err
+		}
+		if err := p.parseNode(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseNode will parse (name[:label]), an inline label becomes a condition
+func (p *queryParser) parseNode() error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+
+	node := queryNode{}
+	if p.peek().kind == 'i' {
+		node.name, _ = p.identifier()
+	} else {
+		node.name = p.anonymousName()
+	}
+	if p.symbol(":") {
+		label, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		p.query.conds = append(p.query.conds, queryCond{node.name, "label", "=", label})
+	}
+	p.query.nodes = append(p.query.nodes, node)
+	return p.expect(")")
+}
+
+// parseEdge will parse --> and <-- along with an optional [name:label op value]
+// between the dashes, or the shorter -> and <-
+func (p *queryParser) parseEdge() error {
+	edge := queryEdge{reverse: p.symbol("<")}
+	if err := p.expect("-"); err != nil {
+		return err
+	}
+
+	edge.name = p.anonymousName()
+	if t := p.peek(); t.kind == 'p' && ((!edge.reverse && t.text == ">") || (edge.reverse && t.text == "(")) {
+		p.symbol(">")
+		p.query.edges = append(p.query.edges, edge)
+		return nil
+	}
+	if p.symbol("[") {
+		if p.peek().kind == 'i' {
+			edge.name, _ = p.identifier()
+		}
+		if p.symbol(":") {
+			if p.symbol("*") {
+				edge.label = "*"
+			} else {
+				label, err := p.identifier()
+				if err != nil {
+					return err
+				}
+				edge.label = label
+			}
+		}
+		if !p.symbol("]") {
+			op, value, err := p.parseComparison()
+			if err != nil {
+				return err
+			}
+			p.query.conds = append(p.query.conds, queryCond{edge.name, "", op, value})
+			if err := p.expect("]"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := p.expect("-"); err != nil {
+		return err
+	}
+	if !edge.reverse {
+		if err := p.expect(">"); err != nil {
+			return err
+		}
+	}
+	p.query.edges = append(p.query.edges, edge)
+	return nil
+}
+
+// parseCond will parse variable[.field] op value
+func (p *queryParser) parseCond() (queryCond, error) {
+	variable, field, err := p.parseOperand()
+	if err != nil {
+		return queryCond{}, err
+	}
+	op, value, err := p.parseComparison()
+	if err != nil {
+		return queryCond{}, err
+	}
+	return queryCond{variable, field, op, value}, nil
+}
+
+func (p *queryParser) parseOperand() (string, string, error) {
+	variable, err := p.identifier()
+	if err != nil {
+		return "", "", err
+	}
+	if !p.symbol(".") {
+		return variable, "", nil
+	}
+	field, err := p.identifier()
+	return variable, field, err
+}
+
+// parseComparison will parse an operator followed by a number, a quoted string
+// or a bare word which is taken as a string
+func (p *queryParser) parseComparison() (string, interface{}, error) {
+	t := p.next()
+	if t.kind != 'p' || !containsString(predicateOps, t.text) {
+		p.pos--
+		return "", nil, p.unexpected("expected a comparison operator")
+	}
+
+	negative := p.symbol("-")
+	value := p.next()
+	switch value.kind {
+	case 'n':
+		number, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return "", nil, errors.New("invalid number " + value.text + " in query")
+		}
+		if negative {
+			number = -number
+		}
+		return t.text, number, nil
+	case 's', 'i':
+		if !negative {
+			return t.text, value.text, nil
+		}
+	}
+	p.pos--
+	return "", nil, p.unexpected("expected a value")
+}
+
+// parseItem will parse variable[.field] or fn(variable[.field]) or count(*)
+func (p *queryParser) parseItem() (queryItem, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return queryItem{}, err
+	}
+
+	fn := strings.ToLower(name)
+	if !queryAggregates[fn] || !p.symbol("(") {
+		item := queryItem{variable: name}
+		if p.symbol(".") {
+			item.field, err = p.identifier()
+		}
+		return item, err
+	}
+
+	item := queryItem{fn: fn}
+	if fn == "count" && p.symbol("*") {
+		return item, p.expect(")")
+	}
+	if item.variable, item.field, err = p.parseOperand(); err != nil {
+		return item, err
+	}
+	return item, p.expect(")")
+}
+
+// validate will ensure every condition and item refers to a variable of the pattern
+func (q *graphQuery) validate() error {
+	kinds := make(map[string]string)
+	for _, node := range q.nodes {
+		kinds[node.name] = "node"
+	}
+	for _, edge := range q.edges {
+		if kinds[edge.name] != "" {
+			return errors.New("edge variable " + edge.name + " is already used")
+		}
+		kinds[edge.name] = "edge"
+	}
+
+	for _, cond := range q.conds {
+		if kinds[cond.variable] == "" {
+			return errors.New("unknown variable " + cond.variable + " in query")
+		}
+	}
+	for _, item := range q.items {
+		if item.variable == "" {
+			continue
+		}
+		if kinds[item.variable] == "" || strings.HasPrefix(item.variable, "_") {
+			return errors.New("unknown variable " + item.variable + " in query")
+		}
+		if item.fn == "sum" || item.fn == "avg" {
+			if kinds[item.variable] == "node" && item.field == "" {
+				return errors.New(item.String() + " requires a numeric value")
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
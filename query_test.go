@@ -0,0 +1,193 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query *graphQuery
+	}{
+		{
+			name: "single edge",
+			text: "MATCH (a)-->(b) RETURN a, b",
+			query: &graphQuery{
+				nodes: []queryNode{{"a"}, {"b"}},
+				edges: []queryEdge{{name: "_1"}},
+				items: []queryItem{{variable: "a"}, {variable: "b"}},
+				order: -1,
+				limit: -1,
+			},
+		},
+		{
+			name: "short and reverse edges",
+			text: "match (a)->(b)<-(c) return c",
+			query: &graphQuery{
+				nodes: []queryNode{{"a"}, {"b"}, {"c"}},
+				edges: []queryEdge{{name: "_1"}, {name: "_2", reverse: true}},
+				items: []queryItem{{variable: "c"}},
+				order: -1,
+				limit: -1,
+			},
+		},
+		{
+			name: "labels, edge condition and where",
+			text: "EXPLAIN MATCH (a)-[w:likes>5]->(b:item)<--(c) WHERE a = 'user:1' AND b.price <= 10 RETURN a, b.price",
+			query: &graphQuery{
+				explain: true,
+				nodes:   []queryNode{{"a"}, {"b"}, {"c"}},
+				edges:   []queryEdge{{name: "w", label: "likes"}, {name: "_2", reverse: true}},
+				conds: []queryCond{
+					{"w", "", ">", float64(5)},
+					{"b", "label", "=", "item"},
+					{"a", "", "=", "user:1"},
+					{"b", "price", "<=", float64(10)},
+				},
+				items: []queryItem{{variable: "a"}, {variable: "b", field: "price"}},
+				order: -1,
+				limit: -1,
+			},
+		},
+		{
+			name: "any label and negative number",
+			text: "MATCH (a)-[w:*]->(b) WHERE w >= -2.5 RETURN b",
+			query: &graphQuery{
+				nodes: []queryNode{{"a"}, {"b"}},
+				edges: []queryEdge{{name: "w", label: "*"}},
+				conds: []queryCond{{"w", "", ">=", float64(-2.5)}},
+				items: []queryItem{{variable: "b"}},
+				order: -1,
+				limit: -1,
+			},
+		},
+		{
+			name: "aggregates, order and limit",
+			text: "MATCH (a)-[w]->(b) RETURN b, count(*), sum(w) ORDER BY sum(w) DESC LIMIT 10",
+			query: &graphQuery{
+				nodes: []queryNode{{"a"}, {"b"}},
+				edges: []queryEdge{{name: "w"}},
+				items: []queryItem{{variable: "b"}, {fn: "count"}, {fn: "sum", variable: "w"}},
+				order: 2,
+				desc:  true,
+				limit: 10,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parseQuery(tt.text)
+			if err != nil {
+				t.Fatalf("parseQuery(%q) error: %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(query, tt.query) {
+				t.Errorf("parseQuery(%q) = %+v, want %+v", tt.text, query, tt.query)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"", "unexpected end of query, expected MATCH"},
+		{"RETURN a", "unexpected RETURN in query, expected MATCH"},
+		{"MATCH (a)-->(b)", "unexpected end of query, expected WHERE or RETURN"},
+		{"MATCH (a)-->(b) RETURN c", "unknown variable c in query"},
+		{"MATCH (a)-->(b) WHERE c = 1 RETURN a", "unknown variable c in query"},
+		{"MATCH (a)-[a]->(b) RETURN b", "edge variable a is already used"},
+		{"MATCH (a)-->(b) RETURN sum(b)", "sum(b) requires a numeric value"},
+		{"MATCH (a)-->(b) WHERE a 1 RETURN a", "unexpected 1 in query, expected a comparison operator"},
+		{"MATCH (a)-->(b) WHERE a ~ 1 RETURN a", "unexpected character '~' in query"},
+		{"MATCH (a)-->(b) WHERE a = 'x RETURN a", "unterminated string in query"},
+		{"MATCH (a)-->(b) RETURN a ORDER BY b", "order by b must be one of the returned items"},
+		{"MATCH (a)-->(b) RETURN a LIMIT -1", "limit must be a positive integer"},
+		{"MATCH (a)-->(b) RETURN a a", "unexpected a in query, expected the end of the query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := parseQuery(tt.text)
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("parseQuery(%q) error = %v, want %q", tt.text, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRunQueryInbound(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "x", floatWeight(1))
+	m.setLabeledEdge("likes", "b", "x", floatWeight(2))
+	m.setLabeledEdge("likes", "c", "x", floatWeight(3))
+	m.setLabeledEdge("likes", "c", "y", floatWeight(4))
+	m.setLabeledEdge("follows", "d", "x", floatWeight(5))
+
+	tests := []struct {
+		text    string
+		explain string
+		want    []string
+	}{
+		{"MATCH (f)-->(t) WHERE t = 'x' RETURN f ORDER BY f", "expand (t) to (f) by in edges", []string{"a"}},
+		{"MATCH (f)-[:likes]->(t) WHERE t = 'x' RETURN f ORDER BY f", "expand (t) to (f) by in edges with label likes", []string{"b", "c"}},
+		{"MATCH (f)-[:follows]->(t) WHERE t = 'y' RETURN f", "expand (t) to (f) by in edges with label follows", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			q, err := parseQuery(tt.text)
+			if err != nil {
+				t.Fatalf("parseQuery: %v", err)
+			}
+			if lines := m.explainQuery(q); len(lines) < 3 || lines[2] != tt.explain {
+				t.Errorf("explainQuery = %q, want step %q", lines, tt.explain)
+			}
+			rows, err := m.runQuery(q)
+			if err != nil {
+				t.Fatalf("runQuery: %v", err)
+			}
+			var got []string
+			for _, row := range rows {
+				got = append(got, row["f"].(string))
+			}
+			if !sameNames(got, tt.want) {
+				t.Errorf("runQuery = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegerQuery(t *testing.T) {
+	m := integerGraph(t)
+	m.setEdge("a", "b", mustWeight(t, "4503599627370496"))
+	m.setEdge("a", "c", mustWeight(t, "4503599627370496"))
+	m.setEdge("x", "y", mustWeight(t, "9007199254740993"))
+
+	tests := []struct {
+		text string
+		err  error
+	}{
+		{"MATCH (f)-[w]->(t) WHERE f = 'a' RETURN t, w ORDER BY t", nil},
+		{"MATCH (f)-[w]->(t) WHERE f = 'a' RETURN f, sum(w)", errInexactWeight},
+		{"MATCH (f)-[w]->(t) WHERE f = 'x' RETURN t", nil},
+		{"MATCH (f)-[w]->(t) WHERE f = 'x' RETURN w", errInexactWeight},
+		{"MATCH (f)-[w>1]->(t) RETURN t", errInexactWeight},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			q, err := parseQuery(tt.text)
+			if err != nil {
+				t.Fatalf("parseQuery: %v", err)
+			}
+			if _, err := m.runQuery(q); err != tt.err {
+				t.Errorf("runQuery error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,1169 @@
+package bgraph
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// Scripts are written in a small lisp and run atomically against a single
+// graph, no other command runs until the script returns:
+//
+//	(let ((total 0))
+//	  (for (to weight) (edges (get args 0))
+//	    (when (> weight 1) (set total (+ total weight))))
+//	  total)
+//
+// Values are numbers, strings, true, false, nil, lists and dicts. The special
+// forms are quote, if, when, do, let, def, set, fn, while, for, and, or. The
+// script arguments are bound to args as strings.
+
+const (
+	scriptBudget  = 1000000         // number of evaluation steps a script may take
+	scriptDepth   = 1000            // maximum nesting of evaluations, function calls and parsed lists
+	scriptTimeout = 5 * time.Second // wall clock time a script may take
+	scriptClock   = 1024            // number of steps between checks of the wall clock
+	scriptLength  = 1 << 20         // maximum length in bytes of a string built by a script
+	scriptItems   = 1 << 20         // maximum number of items in a list or dict built by a script
+)
+
+// scriptSymbol is a name in a script, as opposed to a string literal
+type scriptSymbol string
+
+// scriptEnv holds the variables of a scope
+type scriptEnv struct {
+	vars   map[string]interface{}
+	parent *scriptEnv
+}
+
+// scriptFn is a function defined by a script
+type scriptFn struct {
+	params []string
+	body   []interface{}
+	env    *scriptEnv
+}
+
+// scriptBuiltin is a function provided to scripts
+type scriptBuiltin func(vm *scriptVM, args []interface{}) (interface{}, error)
+
+// scriptProgram is a parsed script
+type scriptProgram struct {
+	exprs []interface{}
+}
+
+// scriptVM evaluates a script against a graph within the step budget
+type scriptVM struct {
+	db       DB
+	steps    int
+	depth    int
+	deadline time.Time
+	appended []interface{} // list last returned by append, which may grow in place
+}
+
+func (e *scriptEnv) lookup(name string) (interface{}, bool) {
+	for env := e; env != nil; env = env.parent {
+		if value, ok := env.vars[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+func (e *scriptEnv) assign(name string, value interface{}) bool {
+	for env := e; env != nil; env = env.parent {
+		if _, ok := env.vars[name]; ok {
+			env.vars[name] = value
+			return true
+		}
+	}
+	return false
+}
+
+// scriptSha returns the hex encoded sha1 used to cache the script
+func scriptSha(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseScript will parse the expressions of a script
+func parseScript(script string) (*scriptProgram, error) {
+	tokens, err := lexScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	program := &scriptProgram{}
+	for len(tokens) > 0 {
+		var expr interface{}
+		if expr, tokens, err = readScript(tokens, 0); err != nil {
+			return nil, err
+		}
+		program.exprs = append(program.exprs, expr)
+	}
+	return program, nil
+}
+
+// lexScript will split the script into parentheses, quoted strings and atoms,
+// comments run from ; to the end of the line
+func lexScript(script string) ([]string, error) {
+	tokens := make([]string, 0)
+	runes := []rune(script)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == ';':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '(' || r == ')' || r == '\'':
+			tokens = append(tokens, string(r))
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				if runes[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated string in script")
+			}
+			tokens = append(tokens, string(runes[i:j+1]))
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("();\"'", runes[j]) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// readScript will read a single expression from the tokens nested within depth lists
+func readScript(tokens []string, depth int) (interface{}, []string, error) {
+	if depth > scriptDepth {
+		return nil, nil, fmt.Errorf("script exceeded the maximum depth of %d", scriptDepth)
+	}
+	token := tokens[0]
+	tokens = tokens[1:]
+	switch {
+	case token == "(":
+		list := make([]interface{}, 0)
+		for {
+			if len(tokens) == 0 {
+				return nil, nil, errors.New("missing ) in script")
+			}
+			if tokens[0] == ")" {
+				return list, tokens[1:], nil
+			}
+			expr, rest, err := readScript(tokens, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			list = append(list, expr)
+			tokens = rest
+		}
+	case token == ")":
+		return nil, nil, errors.New("unexpected ) in script")
+	case token == "'":
+		if len(tokens) == 0 {
+			return nil, nil, errors.New("missing expression after ' in script")
+		}
+		expr, rest, err := readScript(tokens, depth+1)
+		return []interface{}{scriptSymbol("quote"), expr}, rest, err
+	case strings.HasPrefix(token, "\""):
+		s, err := strconv.Unquote(token)
+		if err != nil {
+			return nil, nil, errors.New("invalid string " + token + " in script")
+		}
+		return s, tokens, nil
+	}
+
+	if number, err := strconv.ParseFloat(token, 64); err == nil {
+		return number, tokens, nil
+	}
+	return scriptSymbol(token), tokens, nil
+}
+
+// run will evaluate every expression of the program returning the last value
+func (vm *scriptVM) run(program *scriptProgram, args []string) (interface{}, error) {
+	env := &scriptEnv{vars: make(map[string]interface{})}
+	for name, fn := range scriptBuiltins {
+		env.vars[name] = fn
+	}
+	env.vars["true"] = true
+	env.vars["false"] = false
+	env.vars["nil"] = nil
+	list := make([]interface{}, len(args))
+	for i, arg := range args {
+		list[i] = arg
+	}
+	env.vars["args"] = list
+
+	var result interface{}
+	var err error
+	for _, expr := range program.exprs {
+		if result, err = vm.eval(expr, env); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func scriptTruthy(value interface{}) bool {
+	return value != nil && value != false
+}
+
+// evalBody will evaluate the expressions in order returning the last value
+func (vm *scriptVM) evalBody(body []interface{}, env *scriptEnv) (interface{}, error) {
+	var result interface{}
+	var err error
+	for _, expr := range body {
+		if result, err = vm.eval(expr, env); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (vm *scriptVM) eval(expr interface{}, env *scriptEnv) (interface{}, error) {
+	vm.steps++
+	if vm.steps > scriptBudget {
+		return nil, fmt.Errorf("script exceeded its budget of %d steps", scriptBudget)
+	}
+	if vm.steps%scriptClock == 0 && !vm.deadline.IsZero() && time.Now().After(vm.deadline) {
+		return nil, fmt.Errorf("script exceeded its timeout of %v", scriptTimeout)
+	}
+	vm.depth++
+	defer func() { vm.depth-- }()
+	if vm.depth > scriptDepth {
+		return nil, fmt.Errorf("script exceeded the maximum depth of %d", scriptDepth)
+	}
+
+	switch e := expr.(type) {
+	case scriptSymbol:
+		value, ok := env.lookup(string(e))
+		if !ok {
+			return nil, errors.New("undefined variable " + string(e))
+		}
+		return value, nil
+	case []interface{}:
+		if len(e) == 0 {
+			return nil, nil
+		}
+		if head, ok := e[0].(scriptSymbol); ok {
+			if form, ok := scriptForms[string(head)]; ok {
+				return form(vm, e[1:], env)
+			}
+		}
+
+		fn, err := vm.eval(e[0], env)
+		if err != nil {
+			return nil, err
+		}
+		args := make([]interface{}, len(e)-1)
+		for i, arg := range e[1:] {
+			if args[i], err = vm.eval(arg, env); err != nil {
+				return nil, err
+			}
+		}
+		return vm.call(fn, args)
+	}
+	return expr, nil
+}
+
+// call will apply a builtin or a script function to the arguments
+func (vm *scriptVM) call(fn interface{}, args []interface{}) (interface{}, error) {
+	switch f := fn.(type) {
+	case scriptBuiltin:
+		return f(vm, args)
+	case *scriptFn:
+		if len(args) != len(f.params) {
+			return nil, fmt.Errorf("function takes %d arguments but was given %d", len(f.params), len(args))
+		}
+		env := &scriptEnv{vars: make(map[string]interface{}, len(args)), parent: f.env}
+		for i, param := range f.params {
+			env.vars[param] = args[i]
+		}
+		return vm.evalBody(f.body, env)
+	}
+	return nil, fmt.Errorf("%v is not a function", scriptString(fn))
+}
+
+// scriptForm is a special form which receives its arguments unevaluated
+type scriptForm func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error)
+
+var scriptForms map[string]scriptForm
+
+func init() {
+	scriptForms = map[string]scriptForm{
+		"quote": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("quote takes 1 argument")
+			}
+			return args[0], nil
+		},
+		"if": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, errors.New("if takes 2 or 3 arguments (if cond then [else])")
+			}
+			cond, err := vm.eval(args[0], env)
+			if err != nil {
+				return nil, err
+			}
+			if scriptTruthy(cond) {
+				return vm.eval(args[1], env)
+			}
+			if len(args) == 3 {
+				return vm.eval(args[2], env)
+			}
+			return nil, nil
+		},
+		"when": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, errors.New("when takes at least 1 argument (when cond body ...)")
+			}
+			cond, err := vm.eval(args[0], env)
+			if err != nil || !scriptTruthy(cond) {
+				return nil, err
+			}
+			return vm.evalBody(args[1:], env)
+		},
+		"do": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			return vm.evalBody(args, env)
+		},
+		"let": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			bindings, ok := scriptList(args, 0)
+			if !ok {
+				return nil, errors.New("let takes a list of bindings (let ((name value) ...) body ...)")
+			}
+			scope := &scriptEnv{vars: make(map[string]interface{}, len(bindings)), parent: env}
+			for _, binding := range bindings {
+				pair, ok := binding.([]interface{})
+				if !ok || len(pair) != 2 {
+					return nil, errors.New("let bindings are (name value) pairs")
+				}
+				name, ok := pair[0].(scriptSymbol)
+				if !ok {
+					return nil, errors.New("let binding names must be symbols")
+				}
+				value, err := vm.eval(pair[1], scope)
+				if err != nil {
+					return nil, err
+				}
+				scope.vars[string(name)] = value
+			}
+			return vm.evalBody(args[1:], scope)
+		},
+		"def": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			name, ok := scriptName(args, 0)
+			if !ok || len(args) != 2 {
+				return nil, errors.New("def takes a name and a value (def name value)")
+			}
+			value, err := vm.eval(args[1], env)
+			if err != nil {
+				return nil, err
+			}
+			env.vars[name] = value
+			return value, nil
+		},
+		"set": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			name, ok := scriptName(args, 0)
+			if !ok || len(args) != 2 {
+				return nil, errors.New("set takes a name and a value (set name value)")
+			}
+			value, err := vm.eval(args[1], env)
+			if err != nil {
+				return nil, err
+			}
+			if !env.assign(name, value) {
+				return nil, errors.New("undefined variable " + name)
+			}
+			return value, nil
+		},
+		"fn": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			params, ok := scriptList(args, 0)
+			if !ok {
+				return nil, errors.New("fn takes a list of parameters (fn (param ...) body ...)")
+			}
+			fn := &scriptFn{params: make([]string, len(params)), body: args[1:], env: env}
+			for i, param := range params {
+				name, ok := param.(scriptSymbol)
+				if !ok {
+					return nil, errors.New("fn parameters must be symbols")
+				}
+				fn.params[i] = string(name)
+			}
+			return fn, nil
+		},
+		"while": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, errors.New("while takes at least 1 argument (while cond body ...)")
+			}
+			for {
+				cond, err := vm.eval(args[0], env)
+				if err != nil {
+					return nil, err
+				}
+				if !scriptTruthy(cond) {
+					return nil, nil
+				}
+				if _, err := vm.evalBody(args[1:], env); err != nil {
+					return nil, err
+				}
+			}
+		},
+		"for": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			// (for x list body ...) binds each item, (for (k v) dict body ...)
+			// binds each key in order along with its value
+			if len(args) < 2 {
+				return nil, errors.New("for takes a name and a collection (for name list body ...) or (for (key value) dict body ...)")
+			}
+			var names []string
+			switch binding := args[0].(type) {
+			case scriptSymbol:
+				names = []string{string(binding)}
+			case []interface{}:
+				for _, b := range binding {
+					name, ok := b.(scriptSymbol)
+					if !ok || len(binding) > 2 {
+						return nil, errors.New("for binds a name or a (key value) pair")
+					}
+					names = append(names, string(name))
+				}
+			default:
+				return nil, errors.New("for binds a name or a (key value) pair")
+			}
+
+			coll, err := vm.eval(args[1], env)
+			if err != nil {
+				return nil, err
+			}
+			scope := &scriptEnv{vars: make(map[string]interface{}, len(names)), parent: env}
+			each := func(first interface{}, second interface{}) error {
+				scope.vars[names[0]] = first
+				if len(names) > 1 {
+					scope.vars[names[1]] = second
+				}
+				_, err := vm.evalBody(args[2:], scope)
+				return err
+			}
+
+			switch c := coll.(type) {
+			case nil:
+			case []interface{}:
+				for i, item := range c {
+					if len(names) > 1 {
+						err = each(float64(i), item)
+					} else {
+						err = each(item, nil)
+					}
+					if err != nil {
+						return nil, err
+					}
+				}
+			case map[string]interface{}:
+				for _, key := range scriptKeys(c) {
+					if err := each(key, c[key]); err != nil {
+						return nil, err
+					}
+				}
+			default:
+				return nil, errors.New("for expects a list or a dict")
+			}
+			return nil, nil
+		},
+		"and": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			var result interface{} = true
+			var err error
+			for _, arg := range args {
+				if result, err = vm.eval(arg, env); err != nil || !scriptTruthy(result) {
+					return result, err
+				}
+			}
+			return result, nil
+		},
+		"or": func(vm *scriptVM, args []interface{}, env *scriptEnv) (interface{}, error) {
+			var result interface{}
+			var err error
+			for _, arg := range args {
+				if result, err = vm.eval(arg, env); err != nil || scriptTruthy(result) {
+					return result, err
+				}
+			}
+			return result, nil
+		},
+	}
+}
+
+func scriptList(args []interface{}, i int) ([]interface{}, bool) {
+	if i >= len(args) {
+		return nil, false
+	}
+	list, ok := args[i].([]interface{})
+	return list, ok
+}
+
+func scriptName(args []interface{}, i int) (string, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+	name, ok := args[i].(scriptSymbol)
+	return string(name), ok
+}
+
+func scriptKeys(dict map[string]interface{}) []string {
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scriptString will format a value as the str builtin does
+func scriptString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case scriptSymbol:
+		return string(v)
+	case *scriptFn, scriptBuiltin:
+		return "<fn>"
+	}
+	return fmt.Sprint(value)
+}
+
+// scriptResult will convert the value returned by a script to a reply
+func scriptResult(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = scriptResult(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var err error
+			if dict[key], err = scriptResult(item); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	case scriptSymbol:
+		return string(v), nil
+	case *scriptFn, scriptBuiltin:
+		return nil, errors.New("script cannot return a function")
+	}
+	return value, nil
+}
+
+func scriptNumbers(name string, args []interface{}) ([]float64, error) {
+	numbers := make([]float64, len(args))
+	for i, arg := range args {
+		number, ok := arg.(float64)
+		if !ok {
+			return nil, fmt.Errorf("%s expects numbers but was given %s", name, scriptString(arg))
+		}
+		numbers[i] = number
+	}
+	return numbers, nil
+}
+
+func scriptStrings(name string, args []interface{}) ([]string, error) {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s expects vertex names but was given %s", name, scriptString(arg))
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+func scriptArity(name string, args []interface{}, min int, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return fmt.Errorf("%s takes %d arguments", name, min)
+		}
+		return fmt.Errorf("%s takes %d or more arguments", name, min)
+	}
+	return nil
+}
+
+func scriptArith(name string, fn func(a float64, b float64) float64) scriptBuiltin {
+	return func(vm *scriptVM, args []interface{}) (interface{}, error) {
+		if err := scriptArity(name, args, 1, -1); err != nil {
+			return nil, err
+		}
+		numbers, err := scriptNumbers(name, args)
+		if err != nil {
+			return nil, err
+		}
+		if len(numbers) == 1 && name == "-" {
+			return -numbers[0], nil
+		}
+		result := numbers[0]
+		for _, n := range numbers[1:] {
+			result = fn(result, n)
+		}
+		return result, nil
+	}
+}
+
+func scriptCompare(name string, fn func(cmp int) bool) scriptBuiltin {
+	return func(vm *scriptVM, args []interface{}) (interface{}, error) {
+		if err := scriptArity(name, args, 2, 2); err != nil {
+			return nil, err
+		}
+		switch args[0].(type) {
+		case float64, string:
+		default:
+			return nil, fmt.Errorf("%s expects numbers or strings", name)
+		}
+		return fn(compareValues(args[0], args[1])), nil
+	}
+}
+
+// scriptEqual compares numbers, strings, booleans and nil by value
+func scriptEqual(a interface{}, b interface{}) bool {
+	switch a.(type) {
+	case nil, bool, float64, string:
+		switch b.(type) {
+		case nil, bool, float64, string:
+			return a == b
+		}
+	}
+	return false
+}
+
+// scriptWeights converts a map of weights to a dict
+func scriptWeights(weights map[string]float64) interface{} {
+	if weights == nil {
+		return nil
+	}
+	dict := make(map[string]interface{}, len(weights))
+	for k, v := range weights {
+		dict[k] = v
+	}
+	return dict
+}
+
+// scriptExactWeights converts a map of exact weights to a dict, failing on
+// weights a script number cannot hold exactly
+func scriptExactWeights(weights map[string]int64, err error) (interface{}, error) {
+	if weights == nil || err != nil {
+		return nil, err
+	}
+	dict := make(map[string]interface{}, len(weights))
+	for k, v := range weights {
+		if v > maxExactFloat || v < -maxExactFloat {
+			return nil, errInexactWeight
+		}
+		dict[k] = float64(v)
+	}
+	return dict, nil
+}
+
+// scriptWeight returns a weight read from the graph, failing on integer
+// weights a script number cannot hold exactly
+func scriptWeight(vm *scriptVM, weight float64) (interface{}, error) {
+	if vm.db.getWeightMode() == weightInteger && math.Abs(weight) > maxExactFloat {
+		return nil, errInexactWeight
+	}
+	return weight, nil
+}
+
+// scriptProps converts properties to a dict
+func scriptProps(props properties) map[string]interface{} {
+	dict := make(map[string]interface{}, len(props))
+	for k, v := range props {
+		dict[k] = v
+	}
+	return dict
+}
+
+// scriptMutation returns a builtin writing the weight of an edge (from to
+// weight [label]) through the graph
+func scriptMutation(name string, write func(db DB, label string, from string, to string, weight weightArg) error) scriptBuiltin {
+	return func(vm *scriptVM, args []interface{}) (interface{}, error) {
+		if err := scriptArity(name, args, 3, 4); err != nil {
+			return nil, err
+		}
+		names, err := scriptStrings(name, append([]interface{}{args[0], args[1]}, args[3:]...))
+		if err != nil {
+			return nil, err
+		}
+		weight, ok := args[2].(float64)
+		if !ok {
+			return nil, errors.New(name + " expects a numeric weight")
+		}
+		label := ""
+		if len(names) > 2 {
+			label = names[2]
+		}
+		return nil, write(vm.db, label, names[0], names[1], floatWeight(weight))
+	}
+}
+
+// scriptVertexMutation returns a builtin writing the weight of a vertex (vertex weight)
+func scriptVertexMutation(name string, write func(db DB, vertex string, weight weightArg) error) scriptBuiltin {
+	return func(vm *scriptVM, args []interface{}) (interface{}, error) {
+		if err := scriptArity(name, args, 2, 2); err != nil {
+			return nil, err
+		}
+		vertex, ok := args[0].(string)
+		weight, w_ok := args[1].(float64)
+		if !ok || !w_ok {
+			return nil, errors.New(name + " expects a vertex name and a numeric weight")
+		}
+		return nil, write(vm.db, vertex, floatWeight(weight))
+	}
+}
+
+var scriptBuiltins map[string]scriptBuiltin
+
+func init() {
+	scriptBuiltins = map[string]scriptBuiltin{
+		"+":   scriptArith("+", func(a, b float64) float64 { return a + b }),
+		"-":   scriptArith("-", func(a, b float64) float64 { return a - b }),
+		"*":   scriptArith("*", func(a, b float64) float64 { return a * b }),
+		"/":   scriptArith("/", func(a, b float64) float64 { return a / b }),
+		"%":   scriptArith("%", math.Mod),
+		"min": scriptArith("min", math.Min),
+		"max": scriptArith("max", math.Max),
+		"<":   scriptCompare("<", func(cmp int) bool { return cmp < 0 }),
+		"<=":  scriptCompare("<=", func(cmp int) bool { return cmp <= 0 }),
+		">":   scriptCompare(">", func(cmp int) bool { return cmp > 0 }),
+		">=":  scriptCompare(">=", func(cmp int) bool { return cmp >= 0 }),
+		"=": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("=", args, 2, 2); err != nil {
+				return nil, err
+			}
+			return scriptEqual(args[0], args[1]), nil
+		},
+		"!=": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("!=", args, 2, 2); err != nil {
+				return nil, err
+			}
+			return !scriptEqual(args[0], args[1]), nil
+		},
+		"not": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("not", args, 1, 1); err != nil {
+				return nil, err
+			}
+			return !scriptTruthy(args[0]), nil
+		},
+		"num": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("num", args, 1, 1); err != nil {
+				return nil, err
+			}
+			if number, ok := args[0].(float64); ok {
+				return number, nil
+			}
+			number, err := strconv.ParseFloat(scriptString(args[0]), 64)
+			if err != nil {
+				return nil, nil
+			}
+			return number, nil
+		},
+		"str": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			parts := make([]string, len(args))
+			size := 0
+			for i, arg := range args {
+				parts[i] = scriptString(arg)
+				if size += len(parts[i]); size > scriptLength {
+					return nil, fmt.Errorf("str exceeded the maximum string length of %d", scriptLength)
+				}
+			}
+			return strings.Join(parts, ""), nil
+		},
+		"list": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			return append([]interface{}{}, args...), nil
+		},
+		"dict": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if len(args)%2 != 0 {
+				return nil, errors.New("dict takes key value pairs")
+			}
+			dict := make(map[string]interface{}, len(args)/2)
+			for i := 0; i < len(args); i += 2 {
+				dict[scriptString(args[i])] = args[i+1]
+			}
+			return dict, nil
+		},
+		"len": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("len", args, 1, 1); err != nil {
+				return nil, err
+			}
+			switch c := args[0].(type) {
+			case nil:
+				return float64(0), nil
+			case string:
+				return float64(len(c)), nil
+			case []interface{}:
+				return float64(len(c)), nil
+			case map[string]interface{}:
+				return float64(len(c)), nil
+			}
+			return nil, errors.New("len expects a string, list or dict")
+		},
+		"get": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("get", args, 2, 2); err != nil {
+				return nil, err
+			}
+			switch c := args[0].(type) {
+			case []interface{}:
+				i, ok := args[1].(float64)
+				if !ok || i < 0 || int(i) >= len(c) {
+					return nil, nil
+				}
+				return c[int(i)], nil
+			case map[string]interface{}:
+				return c[scriptString(args[1])], nil
+			case nil:
+				return nil, nil
+			}
+			return nil, errors.New("get expects a list or dict")
+		},
+		"put": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("put", args, 3, 3); err != nil {
+				return nil, err
+			}
+			dict, ok := args[0].(map[string]interface{})
+			if !ok {
+				return nil, errors.New("put expects a dict")
+			}
+			key := scriptString(args[1])
+			if _, ok := dict[key]; !ok && len(dict) >= scriptItems {
+				return nil, fmt.Errorf("put exceeded the maximum dict size of %d", scriptItems)
+			}
+			dict[key] = args[2]
+			return dict, nil
+		},
+		"keys": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("keys", args, 1, 1); err != nil {
+				return nil, err
+			}
+			dict, _ := args[0].(map[string]interface{})
+			keys := make([]interface{}, 0, len(dict))
+			for _, key := range scriptKeys(dict) {
+				keys = append(keys, key)
+			}
+			return keys, nil
+		},
+		"append": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("append", args, 1, -1); err != nil {
+				return nil, err
+			}
+			list, ok := args[0].([]interface{})
+			if !ok && args[0] != nil {
+				return nil, errors.New("append expects a list")
+			}
+			if len(list)+len(args)-1 > scriptItems {
+				return nil, fmt.Errorf("append exceeded the maximum list size of %d", scriptItems)
+			}
+
+			// only the list last returned by append grows in place, any other
+			// list may share its backing array with a longer list and is copied
+			if len(list) == 0 || len(list) != len(vm.appended) || &list[0] != &vm.appended[0] {
+				list = append(make([]interface{}, 0, 2*len(list)+len(args)), list...)
+			}
+			vm.appended = append(list, args[1:]...)
+			return vm.appended, nil
+		},
+		"sort": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			// (sort list) orders the items, (sort list fn) orders by fn of each item
+			if err := scriptArity("sort", args, 1, 2); err != nil {
+				return nil, err
+			}
+			list, ok := args[0].([]interface{})
+			if !ok {
+				return nil, errors.New("sort expects a list")
+			}
+			keys := append([]interface{}{}, list...)
+			if len(args) == 2 {
+				for i, item := range list {
+					key, err := vm.call(args[1], []interface{}{item})
+					if err != nil {
+						return nil, err
+					}
+					keys[i] = key
+				}
+			}
+			order := make([]int, len(list))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(i, j int) bool { return compareRowValues(keys[order[i]], keys[order[j]]) < 0 })
+			sorted := make([]interface{}, len(list))
+			for i, o := range order {
+				sorted[i] = list[o]
+			}
+			return sorted, nil
+		},
+		"edges": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			// (edges vertex [label]) returns a dict of neighbors and weights
+			if err := scriptArity("edges", args, 1, 2); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("edges", args)
+			if err != nil {
+				return nil, err
+			}
+			if vm.db.getWeightMode() == weightInteger {
+				if len(names) == 2 {
+					return scriptExactWeights(vm.db.findLabeledExactEdges(names[1:], names[0]))
+				}
+				return scriptExactWeights(vm.db.findExactEdges(names[0]))
+			}
+			if len(names) == 2 {
+				return scriptWeights(vm.db.findLabeledEdges(names[1:], names[0])), nil
+			}
+			return scriptWeights(vm.db.findEdges(names[0])), nil
+		},
+		"intersect": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("intersect", args, 1, -1); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("intersect", args)
+			if err != nil {
+				return nil, err
+			}
+			if vm.db.getWeightMode() == weightInteger {
+				return scriptExactWeights(vm.db.sumIntersectExactEdges(names))
+			}
+			return scriptWeights(vm.db.sumIntersectEdges(names)), nil
+		},
+		"edge": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			// (edge from to [label]) returns the weight of the edge or nil
+			if err := scriptArity("edge", args, 2, 3); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("edge", args)
+			if err != nil {
+				return nil, err
+			}
+			label := ""
+			if len(names) == 3 {
+				label = names[2]
+			}
+			if detail, ok := vm.db.getLabeledEdgeProperties(label, names[0], names[1]); ok {
+				return scriptWeight(vm, detail.Weight)
+			}
+			return nil, nil
+		},
+		"vertex": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("vertex", args, 1, 1); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("vertex", args)
+			if err != nil {
+				return nil, err
+			}
+			if weight, ok := vm.db.getVertex(names[0]); ok {
+				return scriptWeight(vm, weight)
+			}
+			return nil, nil
+		},
+		"props": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			// (props vertex) returns the properties along with the label of the vertex
+			if err := scriptArity("props", args, 1, 1); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("props", args)
+			if err != nil {
+				return nil, err
+			}
+			label, props, ok := vm.db.getVertexProperties(names[0])
+			if !ok {
+				return nil, nil
+			}
+			dict := scriptProps(props)
+			if label != "" {
+				dict["label"] = label
+			}
+			return dict, nil
+		},
+		"eprops": func(vm *scriptVM, args []interface{}) (interface{}, error) {
+			if err := scriptArity("eprops", args, 2, 2); err != nil {
+				return nil, err
+			}
+			names, err := scriptStrings("eprops", args)
+			if err != nil {
+				return nil, err
+			}
+			detail, ok := vm.db.getEdgeProperties(names[0], names[1])
+			if !ok {
+				return nil, nil
+			}
+			return scriptProps(detail.Properties), nil
+		},
+		"set-edge": scriptMutation("set-edge", func(db DB, label string, from string, to string, weight weightArg) error {
+			return db.setLabeledEdge(label, from, to, weight)
+		}),
+		"incr-edge": scriptMutation("incr-edge", func(db DB, label string, from string, to string, weight weightArg) error {
+			return db.incrLabeledEdge(label, from, to, weight)
+		}),
+		"decr-edge": scriptMutation("decr-edge", func(db DB, label string, from string, to string, weight weightArg) error {
+			return db.decrLabeledEdge(label, from, to, weight)
+		}),
+		"set-vertex": scriptVertexMutation("set-vertex", func(db DB, vertex string, weight weightArg) error {
+			return db.setVertex(vertex, weight)
+		}),
+		"incr-vertex": scriptVertexMutation("incr-vertex", func(db DB, vertex string, weight weightArg) error {
+			return db.incrVertex(vertex, weight)
+		}),
+		"decr-vertex": scriptVertexMutation("decr-vertex", func(db DB, vertex string, weight weightArg) error {
+			return db.decrVertex(vertex, weight)
+		}),
+	}
+}
+
+// cachedScript will return the cached program with the given sha
+func (b *BGraphBackend) cachedScript(sha string) (*scriptProgram, bool) {
+	b.RLock()
+	defer b.RUnlock()
+
+	program, ok := b.scripts[strings.ToLower(sha)]
+	return program, ok
+}
+
+// loadScript will parse and cache the script returning its sha
+func (b *BGraphBackend) loadScript(script string) (string, *scriptProgram, error) {
+	sha := scriptSha(script)
+	if program, ok := b.cachedScript(sha); ok {
+		return sha, program, nil
+	}
+
+	program, err := parseScript(script)
+	if err != nil {
+		return "", nil, err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.scripts[sha] = program
+	return sha, program, nil
+}
+
+// runScript will run the program against the graph and write its result
+func (b *BGraphBackend) runScript(db DB, program *scriptProgram, d [][]byte, client server.ProtocolClient) error {
+	args := make([]string, len(d))
+	for i, k := range d {
+		args[i] = string(k)
+	}
+
+	vm := &scriptVM{db: db, deadline: time.Now().Add(scriptTimeout)}
+	value, err := vm.run(program, args)
+	if err == nil {
+		value, err = scriptResult(value)
+	}
+
+	if err != nil {
+		client.WriteError(err)
+	} else if value == nil {
+		client.WriteNull()
+	} else {
+		client.WriteJson(value)
+	}
+	client.Flush()
+	return nil
+}
+
+// Eval will run a script against the graph, caching it by its sha
+func (b *BGraphBackend) Eval(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("eval takes at least 1 parameter (eval script [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	_, program, err := b.loadScript(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+	return b.runScript(db, program, d[1:], client)
+}
+
+// EvalSha will run a cached script against the graph
+func (b *BGraphBackend) EvalSha(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 1 {
+		client.WriteError(errors.New("evalsha takes at least 1 parameter (evalsha sha [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	program, ok := b.cachedScript(string(d[0]))
+	if !ok {
+		client.WriteError(errors.New("no script with sha " + string(d[0]) + ", use script load"))
+		client.Flush()
+		return nil
+	}
+	return b.runScript(db, program, d[1:], client)
+}
+
+// Script will load scripts into the cache, check which are cached or flush the cache
+func (b *BGraphBackend) Script(data interface{}, client server.ProtocolClient) error {
+	d, _ := data.([][]byte)
+	if len(d) < 1 {
+		client.WriteError(errors.New("script takes at least 1 parameter (script load script|exists sha [sha ...]|flush)"))
+		client.Flush()
+		return nil
+	}
+
+	switch strings.ToLower(string(d[0])) {
+	case "load":
+		if len(d) != 2 {
+			client.WriteError(errors.New("script load takes 1 parameter (script load script)"))
+			break
+		}
+		sha, _, err := b.loadScript(string(d[1]))
+		if err != nil {
+			client.WriteError(err)
+		} else {
+			client.WriteString(sha)
+		}
+	case "exists":
+		exists := make([]bool, len(d)-1)
+		for i, sha := range d[1:] {
+			_, exists[i] = b.cachedScript(string(sha))
+		}
+		client.WriteJson(exists)
+	case "flush":
+		b.Lock()
+		b.scripts = make(map[string]*scriptProgram)
+		b.Unlock()
+		client.WriteString("OK")
+	default:
+		client.WriteError(errors.New("unknown script subcommand " + string(d[0]) + " (load, exists or flush)"))
+	}
+	client.Flush()
+	return nil
+}
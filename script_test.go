@@ -0,0 +1,162 @@
+package bgraph
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// evalScript will parse and run the script against the graph returning its reply
+func evalScript(db DB, script string, args ...string) (interface{}, error) {
+	program, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+	vm := &scriptVM{db: db}
+	value, err := vm.run(program, args)
+	if err != nil {
+		return nil, err
+	}
+	return scriptResult(value)
+}
+
+func TestScriptEval(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		args   []string
+		want   interface{}
+	}{
+		{"number", "42", nil, float64(42)},
+		{"string", `"a b"`, nil, "a b"},
+		{"arithmetic", "(+ 1 (* 2 3) (- 10 4) (/ 9 3))", nil, float64(16)},
+		{"comparison", "(list (< 1 2) (>= 1 2) (= \"a\" \"a\") (!= 1 1))", nil, []interface{}{true, false, true, false}},
+		{"if", "(if (> 2 1) \"yes\" \"no\")", nil, "yes"},
+		{"let and set", "(let ((x 1)) (set x (+ x 1)) x)", nil, float64(2)},
+		{"def and fn", "(def sq (fn (n) (* n n))) (sq 7)", nil, float64(49)},
+		{"recursion", "(def fact (fn (n) (if (<= n 1) 1 (* n (fact (- n 1)))))) (fact 5)", nil, float64(120)},
+		{"while", "(let ((i 0)) (while (< i 5) (set i (+ i 1))) i)", nil, float64(5)},
+		{"for over list", "(let ((total 0)) (for (i x) '(1 2 3) (set total (+ total x))) total)", nil, float64(6)},
+		{"and or", "(list (and 1 nil) (or nil 2))", nil, []interface{}{nil, float64(2)}},
+		{"str", `(str "a" 1 nil)`, nil, "a1nil"},
+		{"dict", `(let ((d (dict "a" 1))) (put d "b" 2) (keys d))`, nil, []interface{}{"a", "b"}},
+		{"sort", "(sort '(3 1 2))", nil, []interface{}{float64(1), float64(2), float64(3)}},
+		{"args", "(len args)", []string{"x", "y"}, float64(2)},
+		{"quote symbol", "'abc", nil, "abc"},
+		{"comment", "; ignored\n1", nil, float64(1)},
+		{"edges", `(edges (get args 0))`, []string{"u1"}, map[string]interface{}{"i1": float64(10), "i2": float64(3)}},
+		{"edge", `(list (edge "u1" "i2") (edge "x" "y"))`, nil, []interface{}{float64(3), nil}},
+		{"intersect", `(intersect "u1" "u2")`, nil, map[string]interface{}{"i1": float64(17)}},
+		{"props", `(props "i1")`, nil, map[string]interface{}{"price": float64(5)}},
+		{"fold over edges", `(let ((total 0))
+			(for (to weight) (edges "u1")
+				(when (> weight 5) (set total (+ total weight))))
+			total)`, nil, float64(10)},
+		{"mutations", `(incr-edge "u3" "i1" 2) (incr-edge "u3" "i1" 3) (set-vertex "u3" 4) (list (edge "u3" "i1") (vertex "u3"))`, nil, []interface{}{float64(5), float64(4)}},
+		{"labeled mutation", `(set-edge "u3" "i2" 1 "likes") (list (edges "u3" "likes") (edge "u3" "i2"))`, nil, []interface{}{map[string]interface{}{"i2": float64(1)}, nil}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := scriptGraph()
+			got, err := evalScript(db, tt.script, tt.args...)
+			if err != nil {
+				t.Fatalf("eval %s error: %v", tt.script, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("eval %s = %#v, want %#v", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		err    string
+	}{
+		{"unbalanced", "(+ 1 2", "missing ) in script"},
+		{"unexpected close", ")", "unexpected )"},
+		{"undefined", "(missing 1)", "undefined variable missing"},
+		{"type error", `(+ 1 "a")`, "expects numbers"},
+		{"not a function", "(1 2)", "is not a function"},
+		{"arity", "((fn (x) x))", "function takes 1 arguments but was given 0"},
+		{"function result", "(fn (x) x)", "script cannot return a function"},
+		{"budget", "(while true 1)", "exceeded its budget"},
+		{"depth", "(def f (fn (n) (f n))) (f 1)", "exceeded the maximum depth"},
+		{"parse depth", strings.Repeat("(", scriptDepth+1) + strings.Repeat(")", scriptDepth+1), "exceeded the maximum depth"},
+		{"string length", `(let ((s "xxxxxxxxxxxxxxxx")) (while true (set s (str s s))))`, "str exceeded the maximum string length"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := evalScript(scriptGraph(), tt.script)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("eval %s error = %v, want %q", tt.script, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestScriptItemLimits(t *testing.T) {
+	vm := &scriptVM{}
+	full := make([]interface{}, scriptItems)
+	if _, err := scriptBuiltins["append"](vm, []interface{}{full, float64(1)}); err == nil {
+		t.Errorf("append to a full list should fail")
+	}
+	if _, err := scriptBuiltins["append"](vm, []interface{}{full[:scriptItems-1], float64(1)}); err != nil {
+		t.Errorf("append up to the limit error: %v", err)
+	}
+
+	// appending to a list other than the last one returned must not change it
+	a, _ := scriptBuiltins["append"](vm, []interface{}{nil, float64(1)})
+	b, _ := scriptBuiltins["append"](vm, []interface{}{a, float64(2)})
+	c, _ := scriptBuiltins["append"](vm, []interface{}{a, float64(3)})
+	if got := b.([]interface{}); len(got) != 2 || got[1] != float64(2) {
+		t.Errorf("append changed an earlier list to %v", got)
+	}
+	if got := c.([]interface{}); len(got) != 2 || got[1] != float64(3) {
+		t.Errorf("append = %v, want [1 3]", got)
+	}
+}
+
+// scriptGraph returns a small graph of users and the items they rated
+func scriptGraph() *MemoryGraphDb {
+	db, _ := NewMemoryGraphDb()
+	db.setEdge("u1", "i1", floatWeight(10))
+	db.setEdge("u1", "i2", floatWeight(3))
+	db.setEdge("u2", "i1", floatWeight(7))
+	db.setVertexProperties("i1", properties{"price": float64(5)})
+	return db
+}
+
+func TestIntegerScript(t *testing.T) {
+	m := integerGraph(t)
+	m.setEdge("a", "b", mustWeight(t, "9007199254740991"))
+	m.setEdge("a", "c", mustWeight(t, "9007199254740993"))
+	m.setEdge("d", "c", mustWeight(t, "-9007199254740992"))
+	m.setVertex("a", mustWeight(t, "9007199254740993"))
+
+	if got, err := evalScript(m, "(edge \"a\" \"b\")"); err != nil || got != float64(1<<53-1) {
+		t.Errorf("edge = %v, %v, want 2^53 - 1", got, err)
+	}
+	for _, script := range []string{
+		"(edges \"a\")",
+		"(edge \"a\" \"c\")",
+		"(vertex \"a\")",
+	} {
+		if _, err := evalScript(m, script); err != errInexactWeight {
+			t.Errorf("%s error = %v, want %v", script, err, errInexactWeight)
+		}
+	}
+
+	// the float sum of the intersection is 0 but the exact sum is 1
+	got, err := evalScript(m, "(intersect \"a\" \"d\")")
+	if err != nil {
+		t.Fatalf("intersect error: %v", err)
+	}
+	if want := map[string]interface{}{"c": float64(1)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("intersect = %v, want %v", got, want)
+	}
+}
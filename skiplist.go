@@ -0,0 +1,150 @@
+package bgraph
+
+import "math/rand"
+
+// skipMaxLevel is the number of levels of a skip list, enough for 4^32 entries
+const skipMaxLevel = 32
+
+// skipList is an indexable skip list of index entries ordered by value then
+// vertex, holding the entries of sorted indexes and the vertex names. Each
+// link records how many entries it skips so that the rank of an entry and the
+// entry at a rank are both found in O(log n).
+type skipList struct {
+	head   *skipNode
+	level  int
+	length int
+}
+
+// skipNode is a single entry of a skip list along with its links on each level
+type skipNode struct {
+	entry indexEntry
+	next  []skipLink
+}
+
+// skipLink is a link to the next node on a level and the number of entries it skips
+type skipLink struct {
+	node *skipNode
+	span int
+}
+
+func newSkipList() *skipList {
+	return &skipList{head: &skipNode{next: make([]skipLink, skipMaxLevel)}, level: 1}
+}
+
+// entryLess orders index entries by value then vertex
+func entryLess(a indexEntry, b indexEntry) bool {
+	if cmp := compareValues(a.value, b.value); cmp != 0 {
+		return cmp < 0
+	}
+	return a.vertex < b.vertex
+}
+
+// randomLevel returns the level of a new node, each level is a quarter as likely
+func randomLevel() int {
+	level := 1
+	for level < skipMaxLevel && rand.Intn(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// insert will add the entry in order
+func (s *skipList) insert(e indexEntry) {
+	var update [skipMaxLevel]*skipNode
+	var rank [skipMaxLevel]int
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		if i < s.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i].node != nil && entryLess(x.next[i].node.entry, e) {
+			rank[i] += x.next[i].span
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > s.level {
+		for i := s.level; i < level; i++ {
+			update[i] = s.head
+			s.head.next[i].span = s.length
+		}
+		s.level = level
+	}
+
+	n := &skipNode{entry: e, next: make([]skipLink, level)}
+	for i := 0; i < level; i++ {
+		n.next[i].node = update[i].next[i].node
+		update[i].next[i].node = n
+		n.next[i].span = update[i].next[i].span - (rank[0] - rank[i])
+		update[i].next[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < s.level; i++ {
+		update[i].next[i].span++
+	}
+	s.length++
+}
+
+// remove will delete the entry if it is in the list
+func (s *skipList) remove(e indexEntry) {
+	var update [skipMaxLevel]*skipNode
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && entryLess(x.next[i].node.entry, e) {
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+
+	x = x.next[0].node
+	if x == nil || x.entry.vertex != e.vertex || compareValues(x.entry.value, e.value) != 0 {
+		return
+	}
+	for i := 0; i < s.level; i++ {
+		if update[i].next[i].node == x {
+			update[i].next[i].span += x.next[i].span - 1
+			update[i].next[i].node = x.next[i].node
+		} else {
+			update[i].next[i].span--
+		}
+	}
+	for s.level > 1 && s.head.next[s.level-1].node == nil {
+		s.level--
+	}
+	s.length--
+}
+
+// countBefore returns the number of entries at the start of the list that
+// satisfy before, which must hold for a prefix of the list
+func (s *skipList) countBefore(before func(e indexEntry) bool) int {
+	rank := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && before(x.next[i].node.entry) {
+			rank += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	return rank
+}
+
+// at returns the node at the zero based rank, or nil when out of range
+func (s *skipList) at(rank int) *skipNode {
+	if rank < 0 || rank >= s.length {
+		return nil
+	}
+
+	traversed := 0
+	x := s.head
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && traversed+x.next[i].span <= rank+1 {
+			traversed += x.next[i].span
+			x = x.next[i].node
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package bgraph
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkSkipList will compare every rank of the list against the expected entries
+func checkSkipList(t *testing.T, s *skipList, want []indexEntry) {
+	t.Helper()
+	if s.length != len(want) {
+		t.Fatalf("length = %d, want %d", s.length, len(want))
+	}
+	for rank, e := range want {
+		x := s.at(rank)
+		if x == nil || x.entry != e {
+			t.Fatalf("at(%d) = %v, want %v", rank, x, e)
+		}
+	}
+	if s.at(-1) != nil || s.at(len(want)) != nil {
+		t.Fatalf("at out of range returned a node")
+	}
+
+	// the spans of every level add up to the entries they skip
+	for i := 0; i < s.level; i++ {
+		rank := 0
+		for x := s.head; x.next[i].node != nil; x = x.next[i].node {
+			rank += x.next[i].span
+			if s.at(rank-1) != x.next[i].node {
+				t.Fatalf("span on level %d reaches rank %d at the wrong node", i, rank-1)
+			}
+		}
+	}
+}
+
+func TestSkipListRanks(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	s := newSkipList()
+	entries := make([]indexEntry, 0)
+	sortEntries := func() {
+		sort.Slice(entries, func(i, j int) bool { return entryLess(entries[i], entries[j]) })
+	}
+
+	for i := 0; i < 500; i++ {
+		e := indexEntry{float64(r.Intn(50)), int64(i)}
+		s.insert(e)
+		entries = append(entries, e)
+	}
+	sortEntries()
+	checkSkipList(t, s, entries)
+
+	// remove every third entry, along with entries that are not in the list
+	kept := entries[:0]
+	for i, e := range entries {
+		if i%3 == 0 {
+			s.remove(e)
+		} else {
+			kept = append(kept, e)
+		}
+	}
+	entries = kept
+	s.remove(indexEntry{float64(1000), 1})
+	s.remove(indexEntry{entries[0].value, -1})
+	checkSkipList(t, s, entries)
+
+	for _, e := range append([]indexEntry(nil), entries...) {
+		s.remove(e)
+	}
+	checkSkipList(t, s, nil)
+	if s.level != 1 {
+		t.Errorf("level of an empty list = %d, want 1", s.level)
+	}
+}
+
+func TestSkipListCountBefore(t *testing.T) {
+	s := newSkipList()
+	for i, value := range []interface{}{float64(3), float64(1), "b", float64(2), "a", float64(2)} {
+		s.insert(indexEntry{value, int64(i)})
+	}
+
+	tests := []struct {
+		name   string
+		before func(e indexEntry) bool
+		want   int
+	}{
+		{"none", func(e indexEntry) bool { return false }, 0},
+		{"below 2", func(e indexEntry) bool { return compareValues(e.value, float64(2)) < 0 }, 1},
+		{"up to 2", func(e indexEntry) bool { return compareValues(e.value, float64(2)) <= 0 }, 3},
+		{"numbers before strings", func(e indexEntry) bool { return compareValues(e.value, "a") < 0 }, 4},
+		{"every entry", func(e indexEntry) bool { return true }, 6},
+	}
+
+	for _, tt := range tests {
+		if got := s.countBefore(tt.before); got != tt.want {
+			t.Errorf("countBefore %s = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRankedPage(t *testing.T) {
+	s := newSkipList()
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		s.insert(indexEntry{name, int64(i)})
+	}
+
+	tests := []struct {
+		lower  int
+		upper  int
+		offset int
+		count  int
+		want   []string
+		total  int
+	}{
+		{0, 5, 0, -1, []string{"a", "b", "c", "d", "e"}, 5},
+		{1, 4, 0, -1, []string{"b", "c", "d"}, 3},
+		{1, 4, 1, 1, []string{"c"}, 3},
+		{1, 4, 2, 10, []string{"d"}, 3},
+		{1, 4, 3, 10, []string{}, 3},
+		{0, 5, 0, 0, []string{}, 5},
+		{3, 3, 0, -1, []string{}, 0},
+		{4, 2, 0, -1, []string{}, 0},
+	}
+
+	for _, tt := range tests {
+		page := rankedPage(s, tt.lower, tt.upper, tt.offset, tt.count, entryString)
+		if page.Total != tt.total || !sameNames(page.Vertices, tt.want) {
+			t.Errorf("rankedPage(%d, %d, %d, %d) = %+v, want %v of %d", tt.lower, tt.upper, tt.offset, tt.count, page, tt.want, tt.total)
+		}
+	}
+}
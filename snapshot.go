@@ -0,0 +1,277 @@
+package bgraph
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// snapshotVersion is the serialized form of an edgeVersion
+type snapshotVersion struct {
+	At      int64
+	Weight  float64
+	Removed bool
+}
+
+// snapshotRemoved is the serialized history of a removed edge
+type snapshotRemoved struct {
+	Label    string
+	From     string
+	To       string
+	Versions []snapshotVersion
+}
+
+// snapshotWindow is the serialized form of a windowCounter
+type snapshotWindow struct {
+	Buckets []float64
+	Head    int64
+}
+
+// graphSnapshot is the serialized form of a MemoryGraphDb, expirations are
+// kept as absolute deadlines so they still apply once the graph is restored
+type graphSnapshot struct {
+	Vertices       map[string]int64
+	VertexWeights  map[int64]float64
+	VertexInts     map[int64]int64
+	VertexLabels   map[int64]string
+	VertexProps    map[int64]properties
+	Edges          map[int64]map[int64]int64
+	EdgeWeights    map[int64]float64
+	EdgeInts       map[int64]int64
+	LabelEdges     map[string]edgeMap
+	EdgeProps      map[int64]properties
+	VertexExpires  map[int64]int64
+	EdgeExpires    map[int64]int64
+	VertexTimes    map[int64]int64
+	EdgeTimes      map[int64]int64
+	EdgeHistory    map[int64][]snapshotVersion
+	RemovedHistory []snapshotRemoved
+	EdgeWindows    map[int64]snapshotWindow
+	FreeVertices   []int64
+	FreeEdges      []int64
+	TotalVertices  int64
+	TotalEdges     int64
+	Negative       negativePolicy
+	Prune          bool
+	Epsilon        float64
+	Mode           weightMode
+	HalfLife       time.Duration
+	HistoryLimit   int
+	HistoryAge     time.Duration
+	WindowSpan     time.Duration
+	WindowBuckets  int
+	Indexes        map[string]bool
+}
+
+// snapshot will encode the entire graph so that it can be persisted
+func (m *MemoryGraphDb) snapshot() ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	indexes := make(map[string]bool, len(m.indexes))
+	for key, idx := range m.indexes {
+		indexes[key] = idx.sorted
+	}
+	s := graphSnapshot{
+		Vertices:      m.vertices,
+		VertexWeights: m.vertexWeights,
+		VertexInts:    m.vertexInts,
+		VertexLabels:  m.vertexLabels,
+		VertexProps:   m.vertexProps,
+		Edges:         m.edges,
+		EdgeWeights:   m.edgeWeights,
+		EdgeInts:      m.edgeInts,
+		LabelEdges:    m.labelEdges,
+		EdgeProps:     m.edgeProps,
+		VertexExpires: m.vertexExpires,
+		EdgeExpires:   m.edgeExpires,
+		VertexTimes:   m.vertexTimes,
+		EdgeTimes:     m.edgeTimes,
+		EdgeHistory:   make(map[int64][]snapshotVersion, len(m.edgeHistory)),
+		EdgeWindows:   make(map[int64]snapshotWindow, len(m.edgeWindows)),
+		FreeVertices:  m.freeVertices,
+		FreeEdges:     m.freeEdges,
+		TotalVertices: m.totalVertices,
+		TotalEdges:    m.totalEdges,
+		Negative:      m.negative,
+		Prune:         m.prune,
+		Epsilon:       m.epsilon,
+		Mode:          m.mode,
+		HalfLife:      m.halfLife,
+		HistoryLimit:  m.historyLimit,
+		HistoryAge:    m.historyAge,
+		WindowSpan:    m.windowSpan,
+		WindowBuckets: m.windowBuckets,
+		Indexes:       indexes,
+	}
+	for edgeIndex, versions := range m.edgeHistory {
+		s.EdgeHistory[edgeIndex] = snapshotVersions(versions)
+	}
+	for _, removed := range m.removedHistory {
+		for key, versions := range removed {
+			s.RemovedHistory = append(s.RemovedHistory, snapshotRemoved{key.label, key.from, key.to, snapshotVersions(versions)})
+		}
+	}
+	for edgeIndex, counter := range m.edgeWindows {
+		s.EdgeWindows[edgeIndex] = snapshotWindow{counter.buckets, counter.head}
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// restore will replace the entire graph with a previously encoded snapshot
+func (m *MemoryGraphDb) restore(data []byte) error {
+	var s graphSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+
+	// gob omits empty maps, start from a fresh graph so none are left nil
+	mem, _ := NewMemoryGraphDb()
+	for name, index := range s.Vertices {
+		mem.vertices[name] = index
+		mem.r_vertices[index] = name
+		mem.vertexNames.insert(indexEntry{name, index})
+	}
+	copyWeights(mem.vertexWeights, s.VertexWeights)
+	for f, label := range s.VertexLabels {
+		mem.vertexLabels[f] = label
+	}
+	for f, props := range s.VertexProps {
+		mem.vertexProps[f] = props
+	}
+	copyWeights(mem.edgeWeights, s.EdgeWeights)
+	copyTimes(mem.vertexInts, s.VertexInts)
+	copyTimes(mem.edgeInts, s.EdgeInts)
+	copyTimes(mem.vertexExpires, s.VertexExpires)
+	copyTimes(mem.edgeExpires, s.EdgeExpires)
+	copyTimes(mem.vertexTimes, s.VertexTimes)
+	copyTimes(mem.edgeTimes, s.EdgeTimes)
+	for f, vertexEdges := range s.Edges {
+		mem.edges[f] = vertexEdges
+	}
+	for edgeIndex, props := range s.EdgeProps {
+		mem.edgeProps[edgeIndex] = props
+	}
+	for label, adj := range s.LabelEdges {
+		mem.labelEdges[label] = adj
+	}
+	mem.rebuildInbound()
+	for edgeIndex, sv := range s.EdgeHistory {
+		mem.edgeHistory[edgeIndex] = restoreVersions(sv)
+	}
+	for _, sr := range s.RemovedHistory {
+		key := historyKey{sr.Label, sr.From, sr.To}
+		removed, ok := mem.removedHistory[key.from]
+		if !ok {
+			removed = make(map[historyKey][]edgeVersion)
+			mem.removedHistory[key.from] = removed
+		}
+		removed[key] = restoreVersions(sr.Versions)
+	}
+	for edgeIndex, sw := range s.EdgeWindows {
+		mem.edgeWindows[edgeIndex] = &windowCounter{sw.Buckets, sw.Head}
+	}
+	mem.freeVertices = s.FreeVertices
+	mem.freeEdges = s.FreeEdges
+	mem.totalVertices = s.TotalVertices
+	mem.totalEdges = s.TotalEdges
+	if s.Negative != negativeDefault {
+		mem.negative = s.Negative
+	}
+	mem.prune = s.Prune
+	mem.epsilon = s.Epsilon
+	if s.Mode != "" {
+		mem.mode = s.Mode
+	}
+	mem.halfLife = s.HalfLife
+	mem.historyLimit = s.HistoryLimit
+	mem.historyAge = s.HistoryAge
+	mem.orderRemovedHistory()
+	if s.WindowBuckets > 0 {
+		mem.windowSpan = s.WindowSpan
+		mem.windowBuckets = s.WindowBuckets
+	}
+	for key, sorted := range s.Indexes {
+		mem.buildIndex(key, sorted)
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.replace(mem)
+	return nil
+}
+
+// replace will swap the entire state of the graph for the state of another
+func (m *MemoryGraphDb) replace(mem *MemoryGraphDb) {
+	m.vertices = mem.vertices
+	m.r_vertices = mem.r_vertices
+	m.vertexNames = mem.vertexNames
+	m.vertexWeights = mem.vertexWeights
+	m.vertexInts = mem.vertexInts
+	m.vertexLabels = mem.vertexLabels
+	m.vertexProps = mem.vertexProps
+	m.indexes = mem.indexes
+	m.edges = mem.edges
+	m.edgeWeights = mem.edgeWeights
+	m.edgeInts = mem.edgeInts
+	m.labelEdges = mem.labelEdges
+	m.inbound = mem.inbound
+	m.edgeProps = mem.edgeProps
+	m.vertexExpires = mem.vertexExpires
+	m.edgeExpires = mem.edgeExpires
+	m.vertexTimes = mem.vertexTimes
+	m.edgeTimes = mem.edgeTimes
+	m.edgeHistory = mem.edgeHistory
+	m.removedHistory = mem.removedHistory
+	m.removedOrder = mem.removedOrder
+	m.edgeWindows = mem.edgeWindows
+	m.freeVertices = mem.freeVertices
+	m.freeEdges = mem.freeEdges
+	m.totalVertices = mem.totalVertices
+	m.totalEdges = mem.totalEdges
+	m.negative = mem.negative
+	m.prune = mem.prune
+	m.epsilon = mem.epsilon
+	m.mode = mem.mode
+	m.halfLife = mem.halfLife
+	m.historyLimit = mem.historyLimit
+	m.historyAge = mem.historyAge
+	m.windowSpan = mem.windowSpan
+	m.windowBuckets = mem.windowBuckets
+}
+
+func copyWeights(dst map[int64]float64, src map[int64]float64) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+func copyTimes(dst map[int64]int64, src map[int64]int64) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// snapshotVersions returns the serialized form of the edge versions
+func snapshotVersions(versions []edgeVersion) []snapshotVersion {
+	sv := make([]snapshotVersion, len(versions))
+	for i, v := range versions {
+		sv[i] = snapshotVersion{v.at, v.weight, v.removed}
+	}
+	return sv
+}
+
+// restoreVersions returns the edge versions from their serialized form
+func restoreVersions(sv []snapshotVersion) []edgeVersion {
+	versions := make([]edgeVersion, len(sv))
+	for i, v := range sv {
+		versions[i] = edgeVersion{v.At, v.Weight, v.Removed}
+	}
+	return versions
+}
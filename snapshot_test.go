@@ -0,0 +1,208 @@
+package bgraph
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// decodeSnapshot will decode a snapshot with its removed edge history in a
+// stable order so that two snapshots of the same graph compare equal
+func decodeSnapshot(t *testing.T, data []byte) graphSnapshot {
+	var s graphSnapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	sort.Slice(s.RemovedHistory, func(i, j int) bool {
+		a, b := s.RemovedHistory[i], s.RemovedHistory[j]
+		return a.Label+"\x00"+a.From+"\x00"+a.To < b.Label+"\x00"+b.From+"\x00"+b.To
+	})
+	return s
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(m *MemoryGraphDb)
+		check func(t *testing.T, m *MemoryGraphDb)
+	}{
+		{
+			name:  "empty graph",
+			build: func(m *MemoryGraphDb) {},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				if info := m.info(); info.Vertices != 0 || info.Edges != 0 {
+					t.Errorf("info = %+v, want an empty graph", info)
+				}
+			},
+		},
+		{
+			name: "weights and labels",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("a", "b", floatWeight(1.5))
+				m.setEdge("a", "c", floatWeight(2))
+				m.setLabeledEdge("likes", "a", "b", floatWeight(3))
+				m.setVertex("a", floatWeight(4))
+			},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				if got, want := m.findEdges("a"), map[string]float64{"b": 1.5, "c": 2}; !reflect.DeepEqual(got, want) {
+					t.Errorf("findEdges = %v, want %v", got, want)
+				}
+				if got, want := m.edgeLabels("a", "b"), map[string]float64{"": 1.5, "likes": 3}; !reflect.DeepEqual(got, want) {
+					t.Errorf("edgeLabels = %v, want %v", got, want)
+				}
+				if weight, ok := m.getVertex("a"); !ok || weight != 4 {
+					t.Errorf("getVertex = %v %v, want 4", weight, ok)
+				}
+			},
+		},
+		{
+			name: "vertex names and reclaimed indices",
+			build: func(m *MemoryGraphDb) {
+				m.setEdge("user:1", "item:1", floatWeight(1))
+				m.setEdge("user:2", "item:2", floatWeight(1))
+				m.setEdge("user:3", "item:1", floatWeight(1))
+				m.removeVertex(m.vertices["user:2"])
+			},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				page := m.rangeVertices("user:", "user:~", 0, 10)
+				if want := []string{"user:1", "user:3"}; page.Total != 2 || !reflect.DeepEqual(page.Vertices, want) {
+					t.Errorf("rangeVertices = %+v, want %v", page, want)
+				}
+				m.setEdge("user:4", "item:4", floatWeight(1))
+				if info := m.info(); info.Vertices != 6 || info.Edges != 3 {
+					t.Errorf("info after reuse = %+v, want 6 vertices and 3 edges", info)
+				}
+			},
+		},
+		{
+			name: "properties and indexes",
+			build: func(m *MemoryGraphDb) {
+				m.setVertexLabel("item", "i1")
+				m.setVertexProperties("i1", properties{"price": float64(5), "name": "lamp"})
+				m.setVertexProperties("i2", properties{"price": float64(7)})
+				m.setEdgeProperties("u", "i1", properties{"source": "import"})
+				m.createIndex("price", true)
+			},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				label, props, ok := m.getVertexProperties("i1")
+				if !ok || label != "item" || !reflect.DeepEqual(props, properties{"price": float64(5), "name": "lamp"}) {
+					t.Errorf("getVertexProperties = %v %v %v", label, props, ok)
+				}
+				page, err := m.rangeIndexed("price", float64(6), float64(8), 0, 10)
+				if err != nil || !reflect.DeepEqual(page.Vertices, []string{"i2"}) {
+					t.Errorf("rangeIndexed = %+v %v, want [i2]", page, err)
+				}
+				detail, ok := m.getEdgeProperties("u", "i1")
+				if !ok || detail.Properties["source"] != "import" {
+					t.Errorf("getEdgeProperties = %+v %v", detail, ok)
+				}
+			},
+		},
+		{
+			name: "expiry, history and windows",
+			build: func(m *MemoryGraphDb) {
+				m.setHistory(3, 0)
+				m.setEdge("a", "b", floatWeight(1))
+				m.setEdge("a", "b", floatWeight(2))
+				m.setEdge("a", "c", floatWeight(1))
+				m.removeEdge("", m.vertices["a"], m.vertices["c"])
+				m.expireEdge("a", "b", time.Hour)
+				m.expireVertex("b", time.Hour)
+				m.setWindow(time.Minute, 6)
+				m.incrWindowEdge("a", "w", floatWeight(3))
+			},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				if _, ok := m.edgeTTL("a", "b"); !ok {
+					t.Errorf("edgeTTL lost the expiry of a b")
+				}
+				if _, ok := m.vertexTTL("b"); !ok {
+					t.Errorf("vertexTTL lost the expiry of b")
+				}
+				if limit, _ := m.getHistory(); limit != 3 {
+					t.Errorf("getHistory limit = %d, want 3", limit)
+				}
+				if versions := m.edgeHistory[m.edgeIndexOf("a", "b")]; len(versions) != 2 {
+					t.Errorf("history of a b = %v, want 2 versions", versions)
+				}
+				if len(m.removedHistory["a"]) != 1 {
+					t.Errorf("removed history = %v, want the history of a c", m.removedHistory)
+				}
+				if span, buckets := m.getWindow(); span != time.Minute || buckets != 6 {
+					t.Errorf("getWindow = %v %d", span, buckets)
+				}
+				if got := m.findEdges("a")["w"]; got != 3 {
+					t.Errorf("windowed weight = %v, want 3", got)
+				}
+			},
+		},
+		{
+			name: "settings and exact integer weights",
+			build: func(m *MemoryGraphDb) {
+				m.setNegative(negativeClamp)
+				m.setEpsilon(true, 0.5)
+				m.setWeightMode(weightInteger)
+				m.setEdge("a", "b", weightArg{exact: 1<<53 + 1, integer: true, value: 1 << 53})
+				m.setVertex("a", weightArg{exact: 1<<62 + 1, integer: true, value: 1 << 62})
+			},
+			check: func(t *testing.T, m *MemoryGraphDb) {
+				if m.getNegative() != negativeClamp || m.getWeightMode() != weightInteger {
+					t.Errorf("settings = %v %v", m.getNegative(), m.getWeightMode())
+				}
+				if epsilon, prune := m.getEpsilon(); !prune || epsilon != 0.5 {
+					t.Errorf("getEpsilon = %v %v", epsilon, prune)
+				}
+				edges, err := m.findExactEdges("a")
+				if err != nil || edges["b"] != 1<<53+1 {
+					t.Errorf("findExactEdges = %v %v, want b 2^53 + 1", edges, err)
+				}
+				if got := m.vertexExact(m.vertices["a"]); got != 1<<62+1 {
+					t.Errorf("vertexExact = %d, want 2^62 + 1", got)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.build(m)
+			data, err := m.snapshot()
+			if err != nil {
+				t.Fatalf("snapshot: %v", err)
+			}
+
+			restored, _ := NewMemoryGraphDb()
+			if err := restored.restore(data); err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+			again, err := restored.snapshot()
+			if err != nil {
+				t.Fatalf("snapshot of the restored graph: %v", err)
+			}
+			if got, want := decodeSnapshot(t, again), decodeSnapshot(t, data); !reflect.DeepEqual(got, want) {
+				t.Errorf("restored snapshot = %+v, want %+v", got, want)
+			}
+			tt.check(t, restored)
+		})
+	}
+}
+
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("a", "b", floatWeight(1))
+	if err := m.restore([]byte("not a snapshot")); err == nil {
+		t.Fatalf("restore of an invalid snapshot should fail")
+	}
+	if got := m.findEdges("a"); got["b"] != 1 {
+		t.Errorf("failed restore changed the graph to %v", got)
+	}
+}
+
+// edgeIndexOf returns the index of the unlabeled edge between the two vertices
+func (m *MemoryGraphDb) edgeIndexOf(from string, to string) int64 {
+	edgeIndex, _ := m.existingEdge("", from, to)
+	return edgeIndex
+}
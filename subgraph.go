@@ -0,0 +1,122 @@
+package bgraph
+
+import "encoding/json"
+
+// subgraph is a set of vertices with their weights, labels and properties
+// along with the edges between them
+type subgraph struct {
+	Vertices   map[string]float64    `json:"vertices"`
+	Labels     map[string]string     `json:"labels,omitempty"`
+	Properties map[string]properties `json:"properties,omitempty"`
+	Edges      []weightedEdge        `json:"edges"`
+	exact      map[string]int64      // exact vertex weights of a graph with integer weights
+}
+
+// MarshalJSON will write the exact vertex weights in place of the floats when
+// the subgraph is in a graph with integer weights
+func (g subgraph) MarshalJSON() ([]byte, error) {
+	var vertices interface{} = g.Vertices
+	if g.exact != nil {
+		exact := make(map[string]int64, len(g.Vertices))
+		for name := range g.Vertices {
+			exact[name] = g.exact[name]
+		}
+		vertices = exact
+	}
+	return json.Marshal(struct {
+		Vertices   interface{}           `json:"vertices"`
+		Labels     map[string]string     `json:"labels,omitempty"`
+		Properties map[string]properties `json:"properties,omitempty"`
+		Edges      []weightedEdge        `json:"edges"`
+	}{vertices, g.Labels, g.Properties, g.Edges})
+}
+
+// induce will build the subgraph made of the given vertex indices and every
+// edge whose endpoints are both part of that set
+func (m *MemoryGraphDb) induce(members map[int64]bool) *subgraph {
+	result := &subgraph{
+		Vertices:   make(map[string]float64),
+		Labels:     make(map[string]string),
+		Properties: make(map[string]properties),
+		Edges:      make([]weightedEdge, 0),
+	}
+	if m.mode == weightInteger {
+		result.exact = make(map[string]int64)
+	}
+	for f := range members {
+		from := m.r_vertices[f]
+		result.Vertices[from] = m.vertexWeight(f)
+		if result.exact != nil {
+			result.exact[from] = m.vertexExact(f)
+		}
+		if label, ok := m.vertexLabels[f]; ok {
+			result.Labels[from] = label
+		}
+		if props, ok := m.vertexProps[f]; ok {
+			copied := make(properties, len(props))
+			for key, value := range props {
+				copied[key] = value
+			}
+			result.Properties[from] = copied
+		}
+		for t, edgeIndex := range m.edges[f] {
+			if members[t] {
+				detail := m.edgeDetail(edgeIndex)
+				result.Edges = append(result.Edges, weightedEdge{from, m.r_vertices[t], detail.Weight, detail.Properties, detail.exact, detail.integer})
+			}
+		}
+	}
+	return result
+}
+
+// inducedSubgraph will return the subgraph induced by the specified vertices,
+// vertices that do not exist are ignored
+func (m *MemoryGraphDb) inducedSubgraph(vertices []string) *subgraph {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	members := make(map[int64]bool)
+	for _, name := range vertices {
+		if index, ok := m.vertices[name]; ok {
+			members[index] = true
+		}
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+	return m.induce(members)
+}
+
+// egoSubgraph will return the subgraph induced by the vertices reachable
+// from the vertex over at most radius outgoing edges
+func (m *MemoryGraphDb) egoSubgraph(vertex string, radius int) *subgraph {
+	m.Lock()
+	defer m.Unlock()
+
+	m.purgeExpired()
+
+	start, ok := m.vertices[vertex]
+	if !ok {
+		return nil
+	}
+
+	members := map[int64]bool{start: true}
+	frontier := []int64{start}
+	for depth := 0; depth < radius && len(frontier) > 0; depth++ {
+		next := make([]int64, 0)
+		for _, f := range frontier {
+			for t := range m.edges[f] {
+				if !members[t] {
+					members[t] = true
+					next = append(next, t)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return m.induce(members)
+}
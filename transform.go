@@ -0,0 +1,247 @@
+package bgraph
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// transformBatch is the number of edges transformed before the lock is released
+// so that other commands can run while a whole graph is transformed
+const transformBatch = 1000
+
+// edgeTransform returns the new weight of an edge and whether it is kept, as
+// a float or as an exact integer when the graph holds integer weights
+type edgeTransform struct {
+	float func(weight float64) (float64, bool)
+	exact func(weight int64) (int64, bool, error)
+}
+
+// transformResult is the number of edges updated and removed by a transform,
+// along with the edges left unchanged as their weight would be rejected or is
+// counted by a sliding window
+type transformResult struct {
+	Updated  int `json:"updated"`
+	Removed  int `json:"removed"`
+	Rejected int `json:"rejected"`
+	Windowed int `json:"windowed"`
+}
+
+func (m *MemoryGraphDb) transformEdges(vertices []string, transform edgeTransform) transformResult {
+	return m.transformLabeledEdges([]string{""}, vertices, transform)
+}
+
+// transformLabeledEdges will apply the transform to the labeled edges from the
+// vertices, or from every vertex when none are given. The edges are processed
+// in batches, releasing the lock in between, so edges written while the
+// transform runs may or may not be transformed. Edges with a sliding window
+// are left alone as their weight is the sum of the counts in the window.
+func (m *MemoryGraphDb) transformLabeledEdges(labels []string, vertices []string, transform edgeTransform) transformResult {
+	m.Lock()
+	if len(vertices) == 0 {
+		vertices = make([]string, 0, len(m.vertices))
+		for name := range m.vertices {
+			vertices = append(vertices, name)
+		}
+	}
+
+	selected := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		selected[label] = true
+	}
+
+	var result transformResult
+	batch := 0
+	for _, name := range vertices {
+		if batch >= transformBatch {
+			m.Unlock()
+			runtime.Gosched()
+			m.Lock()
+			batch = 0
+		}
+
+		f, ok := m.liveVertex(name)
+		if !ok {
+			continue
+		}
+
+		m.purgeEdges(f)
+		m.eachAdjacency(func(label string, adj edgeMap) {
+			if !selected[label] && !selected["*"] {
+				return
+			}
+			for t, edgeIndex := range adj[f] {
+				batch++
+				if _, ok := m.edgeWindows[edgeIndex]; ok {
+					result.Windowed++
+					continue
+				}
+				weight, exact := m.edgeWeight(edgeIndex), m.edgeExact(edgeIndex)
+				var transformed float64
+				var transformedExact int64
+				var keep bool
+				var err error
+				if m.mode == weightInteger {
+					transformedExact, keep, err = transform.exact(exact)
+					transformed = float64(transformedExact)
+				} else {
+					transformed, keep = transform.float(weight)
+					transformedExact = int64(transformed)
+				}
+				var remove bool
+				if err == nil {
+					transformed, remove, err = applyNegative(m.negative, transformed)
+				}
+				if err != nil {
+					result.Rejected++
+					continue
+				}
+				if transformed == 0 {
+					transformedExact = 0
+				}
+				if !keep || remove || m.negligibleEdge(edgeIndex, transformed) {
+					m.removeEdge(label, f, t)
+					result.Removed++
+					continue
+				}
+				if transformed != weight || transformedExact != exact {
+					m.setEdgeWeight(edgeIndex, transformed, transformedExact)
+					m.touchEdge(edgeIndex)
+					result.Updated++
+				}
+			}
+		})
+	}
+	m.Unlock()
+
+	return result
+}
+
+// parseTransformArgs will parse the numeric parameters of a transform
+// followed by the optional vertices to transform
+func parseTransformArgs(d [][]byte, n int) ([]weightArg, []string, error) {
+	values := make([]weightArg, n)
+	for i := range values {
+		value, err := parseWeight(string(d[i]))
+		if err != nil || math.IsNaN(value.value) {
+			return nil, nil, errors.New("invalid number " + string(d[i]))
+		}
+		values[i] = value
+	}
+
+	vertices := make([]string, len(d)-n)
+	for i, k := range d[n:] {
+		vertices[i] = string(k)
+	}
+	return values, vertices, nil
+}
+
+// runTransform will apply the transform and write the number of edges updated and removed
+func (b *BGraphBackend) runTransform(db DB, usage string, d [][]byte, n int, client server.ProtocolClient, build func(values []weightArg) (edgeTransform, error)) error {
+	if len(d) < n {
+		name, plural := strings.Fields(usage)[0], "s"
+		if n == 1 {
+			plural = ""
+		}
+		client.WriteError(fmt.Errorf("%s takes at least %d parameter%s (%s)", name, n, plural, usage))
+		client.Flush()
+		return nil
+	}
+
+	values, vertices, err := parseTransformArgs(d, n)
+	var transform edgeTransform
+	if err == nil {
+		transform, err = build(values)
+	}
+	if err != nil {
+		client.WriteError(errors.New(err.Error() + " (" + usage + ")"))
+		client.Flush()
+		return nil
+	}
+
+	client.WriteJson(db.transformEdges(vertices, transform))
+	client.Flush()
+	return nil
+}
+
+// ScaleEdges will multiply the weight of every edge, or of the edges from the vertices, by a factor
+func (b *BGraphBackend) ScaleEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	return b.runTransform(db, "scale factor [vertex ...]", d, 1, client, scaleTransform)
+}
+
+// scaleTransform multiplies the weights by the factor, which must be finite as
+// an infinite factor would turn weights of zero into NaN
+func scaleTransform(values []weightArg) (edgeTransform, error) {
+	factor := values[0]
+	if math.IsInf(factor.value, 0) {
+		return edgeTransform{}, errors.New("scale factor must be finite")
+	}
+	return edgeTransform{
+		float: func(weight float64) (float64, bool) { return weight * factor.value, true },
+		exact: func(weight int64) (int64, bool, error) {
+			// integer factors scale exactly while fractions round to the nearest integer
+			if factor.integer {
+				scaled, err := mulExact(weight, factor.exact)
+				return scaled, true, err
+			}
+			scaled, err := scaleExact(weight, factor.value)
+			return scaled, true, err
+		},
+	}, nil
+}
+
+// ClampEdges will limit the weight of every edge, or of the edges from the vertices, to a range
+func (b *BGraphBackend) ClampEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	return b.runTransform(db, "clamp min max [vertex ...]", d, 2, client, clampTransform)
+}
+
+// clampTransform limits the weights to the range from min to max
+func clampTransform(values []weightArg) (edgeTransform, error) {
+	min, max := values[0].value, values[1].value
+	if min > max {
+		return edgeTransform{}, errors.New("min must not be greater than max")
+	}
+	lower, upper := boundExact(min, true), boundExact(max, false)
+	if values[0].integer {
+		lower = values[0].exact
+	}
+	if values[1].integer {
+		upper = values[1].exact
+	}
+	return edgeTransform{
+		float: func(weight float64) (float64, bool) { return math.Max(min, math.Min(max, weight)), true },
+		exact: func(weight int64) (int64, bool, error) {
+			if lower > upper {
+				return weight, true, errors.New("no integer between " + values[0].text + " and " + values[1].text)
+			}
+			switch {
+			case weight < lower:
+				return lower, true, nil
+			case weight > upper:
+				return upper, true, nil
+			}
+			return weight, true, nil
+		},
+	}, nil
+}
+
+// PruneEdges will remove every edge, or the edges from the vertices, whose weight is below a threshold
+func (b *BGraphBackend) PruneEdges(db DB, d [][]byte, client server.ProtocolClient) error {
+	return b.runTransform(db, "prune threshold [vertex ...]", d, 1, client, pruneTransform)
+}
+
+// pruneTransform removes the edges whose weight is below the threshold
+func pruneTransform(values []weightArg) (edgeTransform, error) {
+	threshold, exactThreshold := values[0].value, boundExact(values[0].value, true)
+	if values[0].integer {
+		exactThreshold = values[0].exact
+	}
+	return edgeTransform{
+		float: func(weight float64) (float64, bool) { return weight, weight >= threshold },
+		exact: func(weight int64) (int64, bool, error) { return weight, weight >= exactThreshold, nil },
+	}, nil
+}
@@ -0,0 +1,133 @@
+package bgraph
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// transformGraph returns a graph with edges from a and b, one of them labeled
+// and one of them counted by a sliding window
+func transformGraph() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	m.setWindow(time.Hour, 4)
+	m.setEdge("a", "b", floatWeight(2))
+	m.setEdge("a", "c", floatWeight(-4))
+	m.setEdge("b", "c", floatWeight(8))
+	m.setLabeledEdge("likes", "a", "b", floatWeight(3))
+	m.incrWindowEdge("a", "d", floatWeight(5))
+	return m
+}
+
+func TestTransforms(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func([]weightArg) (edgeTransform, error)
+		values   []float64
+		vertices []string
+		setup    func(m *MemoryGraphDb)
+		result   transformResult
+		a        map[string]float64
+		b        map[string]float64
+	}{
+		{
+			name: "scale", build: scaleTransform, values: []float64{2},
+			result: transformResult{Updated: 3, Windowed: 1},
+			a:      map[string]float64{"b": 4, "c": -8, "d": 5}, b: map[string]float64{"c": 16},
+		},
+		{
+			name: "scale from a vertex", build: scaleTransform, values: []float64{0.5}, vertices: []string{"b"},
+			result: transformResult{Updated: 1},
+			a:      map[string]float64{"b": 2, "c": -4, "d": 5}, b: map[string]float64{"c": 4},
+		},
+		{
+			name: "scale to zero with pruning", build: scaleTransform, values: []float64{0},
+			setup:  func(m *MemoryGraphDb) { m.setEpsilon(true, 0) },
+			result: transformResult{Removed: 3, Windowed: 1},
+			a:      map[string]float64{"d": 5}, b: nil,
+		},
+		{
+			name: "scale with negative weights rejected", build: scaleTransform, values: []float64{2},
+			setup:  func(m *MemoryGraphDb) { m.setNegative(negativeReject) },
+			result: transformResult{Updated: 2, Rejected: 1, Windowed: 1},
+			a:      map[string]float64{"b": 4, "c": -4, "d": 5}, b: map[string]float64{"c": 16},
+		},
+		{
+			name: "clamp", build: clampTransform, values: []float64{0, 3},
+			result: transformResult{Updated: 2, Windowed: 1},
+			a:      map[string]float64{"b": 2, "c": 0, "d": 5}, b: map[string]float64{"c": 3},
+		},
+		{
+			name: "prune", build: pruneTransform, values: []float64{3},
+			result: transformResult{Removed: 2, Windowed: 1},
+			a:      map[string]float64{"d": 5}, b: map[string]float64{"c": 8},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := transformGraph()
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+			values := make([]weightArg, len(tt.values))
+			for i, value := range tt.values {
+				values[i] = floatWeight(value)
+			}
+			transform, err := tt.build(values)
+			if err != nil {
+				t.Fatalf("build: %v", err)
+			}
+
+			if got := m.transformEdges(tt.vertices, transform); got != tt.result {
+				t.Errorf("transformEdges = %+v, want %+v", got, tt.result)
+			}
+			if got := m.findEdges("a"); !reflect.DeepEqual(got, tt.a) {
+				t.Errorf("edges from a = %v, want %v", got, tt.a)
+			}
+			if got := m.findEdges("b"); !reflect.DeepEqual(got, tt.b) {
+				t.Errorf("edges from b = %v, want %v", got, tt.b)
+			}
+			if got := m.edgeLabels("a", "b")["likes"]; got != 3 {
+				t.Errorf("labeled edge = %v, want it unchanged at 3", got)
+			}
+			if _, buckets := m.getWindow(); m.info().WindowedEdges != 1 || buckets != 4 {
+				t.Errorf("windowed edges = %d, want the window of a d kept", m.info().WindowedEdges)
+			}
+		})
+	}
+}
+
+func TestLabeledTransforms(t *testing.T) {
+	m := transformGraph()
+	v := labelView{m, []string{"likes"}}
+	transform, _ := scaleTransform([]weightArg{floatWeight(3)})
+	if got, want := v.transformEdges(nil, transform), (transformResult{Updated: 1}); got != want {
+		t.Errorf("transformEdges through the view = %+v, want %+v", got, want)
+	}
+	if got, want := m.edgeLabels("a", "b"), map[string]float64{"": 2, "likes": 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("edgeLabels = %v, want %v", got, want)
+	}
+}
+
+func TestTransformArgs(t *testing.T) {
+	if _, err := clampTransform([]weightArg{floatWeight(3), floatWeight(1)}); err == nil {
+		t.Errorf("clamp with min above max should fail")
+	}
+	for _, factor := range []string{"inf", "-inf", "1e400"} {
+		values, _, err := parseTransformArgs([][]byte{[]byte(factor)}, 1)
+		if err == nil {
+			_, err = scaleTransform(values)
+		}
+		if err == nil {
+			t.Errorf("scale by %s should fail", factor)
+		}
+	}
+	if _, _, err := parseTransformArgs([][]byte{[]byte("NaN")}, 1); err == nil {
+		t.Errorf("parseTransformArgs accepted NaN")
+	}
+	values, vertices, err := parseTransformArgs([][]byte{[]byte("1"), []byte("2"), []byte("a")}, 2)
+	if err != nil || len(values) != 2 || values[1].value != 2 || !reflect.DeepEqual(vertices, []string{"a"}) {
+		t.Errorf("parseTransformArgs = %v %v %v", values, vertices, err)
+	}
+}
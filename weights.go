@@ -0,0 +1,419 @@
+package bgraph
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// weightMode is the kind of number the weights of a graph hold
+type weightMode string
+
+const (
+	weightFloat   weightMode = "float"   // any float64
+	weightInteger weightMode = "integer" // exact int64 integers
+)
+
+// maxExactFloat is the largest integer up to which a float64 holds every
+// integer exactly (2^53 - 1)
+const maxExactFloat = 1<<53 - 1
+
+// maxWindowWeight bounds the sliding window counters of graphs with integer
+// weights as they are held as floats
+const maxWindowWeight = maxExactFloat
+
+// errWeightOverflow is returned when an integer weight would overflow
+var errWeightOverflow = errors.New("integer weight overflow")
+
+// errInexactWeight is returned by the commands computing over floats when a
+// graph with integer weights would need a weight beyond 2^53 - 1
+var errInexactWeight = errors.New("integer weight beyond 2^53 - 1 cannot be held exactly as a float")
+
+// weightArg is a weight given to a write, along with the exact integer it was
+// written as so that integer weights never pass through a float64
+type weightArg struct {
+	value   float64
+	exact   int64
+	integer bool // the weight is an integer that fits in an int64
+	text    string
+}
+
+// parseWeight will parse the weight of a write, integers are parsed exactly
+// while anything else, including a fraction that rounds to an integer, is not
+// an integer
+func parseWeight(text string) (weightArg, error) {
+	value, err := strconv.ParseFloat(text, 64)
+	exact, intErr := strconv.ParseInt(text, 10, 64)
+	if intErr == nil {
+		err = nil
+	}
+	return weightArg{value: value, exact: exact, integer: intErr == nil, text: text}, err
+}
+
+// floatWeight returns the weight of a float64, an integer when the float
+// holds an integer within the range of an int64
+func floatWeight(value float64) weightArg {
+	w := weightArg{value: value, text: strconv.FormatFloat(value, 'g', -1, 64)}
+	if value == math.Trunc(value) && value >= math.MinInt64 && value < math.MaxInt64 {
+		w.exact, w.integer = int64(value), true
+	}
+	return w
+}
+
+// exactFloat reports whether the float is held exactly, which every float is
+// unless the graph holds integer weights and it lies beyond 2^53 - 1
+func (m *MemoryGraphDb) exactFloat(weight float64) bool {
+	return m.mode != weightInteger || math.Abs(weight) <= maxExactFloat
+}
+
+// addExact returns the sum of the integers or errWeightOverflow
+func addExact(a int64, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, errWeightOverflow
+	}
+	return sum, nil
+}
+
+// mulExact returns the product of the integers or errWeightOverflow
+func mulExact(a int64, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, errWeightOverflow
+	}
+	return product, nil
+}
+
+// scaleExact returns the integer multiplied by a fractional factor and rounded
+// half away from zero, or errWeightOverflow when it lies beyond the range of
+// an int64. The product of an int64 and a float64 needs at most 117 bits so
+// it is computed exactly before rounding.
+func scaleExact(weight int64, factor float64) (int64, error) {
+	if math.IsInf(factor, 0) || math.IsNaN(factor) {
+		return 0, errWeightOverflow
+	}
+	product := new(big.Float).SetPrec(128).SetInt64(weight)
+	product.Mul(product, big.NewFloat(factor))
+	if product.Sign() < 0 {
+		product.Sub(product, big.NewFloat(0.5))
+	} else {
+		product.Add(product, big.NewFloat(0.5))
+	}
+	rounded, _ := product.Int(nil)
+	if !rounded.IsInt64() {
+		return 0, errWeightOverflow
+	}
+	return rounded.Int64(), nil
+}
+
+// boundExact returns the float as an integer bound, rounded up for a lower
+// bound or down for an upper bound and saturated to the range of an int64
+func boundExact(value float64, lower bool) int64 {
+	if lower {
+		value = math.Ceil(value)
+	} else {
+		value = math.Floor(value)
+	}
+	switch {
+	case value < math.MinInt64:
+		return math.MinInt64
+	case value >= math.MaxInt64:
+		return math.MaxInt64
+	}
+	return int64(value)
+}
+
+// applyExact returns the exact weight to write given the current exact weight
+func (w weightWrite) applyExact(current int64) (int64, error) {
+	if !w.weight.integer {
+		return 0, errors.New("weight " + w.weight.text + " is not an integer")
+	}
+
+	weight := w.weight.exact
+	if w.negate {
+		if weight == math.MinInt64 {
+			return 0, errWeightOverflow
+		}
+		weight = -weight
+	}
+	if w.incr {
+		return addExact(current, weight)
+	}
+	return weight, nil
+}
+
+// edgeExact returns the exact weight of the edge in a graph with integer
+// weights, windowed edges hold the total of their counter
+func (m *MemoryGraphDb) edgeExact(edgeIndex int64) int64 {
+	if _, ok := m.edgeWindows[edgeIndex]; ok || m.mode != weightInteger {
+		return int64(m.edgeWeight(edgeIndex))
+	}
+	return m.edgeInts[edgeIndex]
+}
+
+// vertexExact returns the exact weight of the vertex in a graph with integer weights
+func (m *MemoryGraphDb) vertexExact(f int64) int64 {
+	if m.mode != weightInteger {
+		return int64(m.vertexWeight(f))
+	}
+	return m.vertexInts[f]
+}
+
+// setEdgeWeight will store the weight of the edge along with its exact
+// weight when the graph holds integer weights
+func (m *MemoryGraphDb) setEdgeWeight(edgeIndex int64, weight float64, exact int64) {
+	m.edgeWeights[edgeIndex] = weight
+	if m.mode == weightInteger {
+		m.edgeInts[edgeIndex] = exact
+	}
+}
+
+// setVertexWeight will store the weight of the vertex along with its exact
+// weight when the graph holds integer weights
+func (m *MemoryGraphDb) setVertexWeight(f int64, weight float64, exact int64) {
+	m.vertexWeights[f] = weight
+	if m.mode == weightInteger {
+		m.vertexInts[f] = exact
+	}
+}
+
+// resolveWrite will apply the write to the current weight, exactly when the
+// graph holds integer weights, followed by the negative weight policy. It
+// returns the weight and exact weight to write along with whether the edge or
+// vertex weight should be removed instead.
+func (m *MemoryGraphDb) resolveWrite(policy negativePolicy, write weightWrite, current float64, currentExact int64) (float64, int64, bool, error) {
+	var weight float64
+	var exact int64
+	if m.mode == weightInteger {
+		var err error
+		if exact, err = write.applyExact(currentExact); err != nil {
+			return 0, 0, false, err
+		}
+		weight = float64(exact)
+	} else {
+		weight = write.apply(current)
+	}
+
+	weight, remove, err := applyNegative(m.resolveNegative(policy), weight)
+	if weight == 0 {
+		exact = 0
+	}
+	return weight, exact, remove, err
+}
+
+// setWeightMode will change the kind of number the weights of the graph hold,
+// integer weights require every current weight to be an integer within the
+// range of an int64 and decay to be disabled as decayed weights are fractions
+func (m *MemoryGraphDb) setWeightMode(mode weightMode) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if mode == m.mode {
+		return nil
+	}
+	if mode == weightFloat {
+		m.edgeInts = make(map[int64]int64)
+		m.vertexInts = make(map[int64]int64)
+		m.mode = mode
+		return nil
+	}
+
+	if m.halfLife > 0 {
+		return errors.New("integer weights require decay to be disabled")
+	}
+	edgeInts := make(map[int64]int64, len(m.edgeWeights))
+	for edgeIndex := range m.edgeWeights {
+		weight := floatWeight(m.edgeWeight(edgeIndex))
+		if !weight.integer {
+			return errors.New("integer weights require every weight to be an integer: edge weight " + weight.text)
+		}
+		edgeInts[edgeIndex] = weight.exact
+	}
+	vertexInts := make(map[int64]int64, len(m.vertexWeights))
+	for f := range m.vertexWeights {
+		weight := floatWeight(m.vertexWeight(f))
+		if !weight.integer {
+			return errors.New("integer weights require every weight to be an integer: vertex weight " + weight.text)
+		}
+		vertexInts[f] = weight.exact
+	}
+
+	m.edgeInts = edgeInts
+	m.vertexInts = vertexInts
+	m.mode = mode
+	return nil
+}
+
+// getWeightMode returns the kind of number the weights of the graph hold
+func (m *MemoryGraphDb) getWeightMode() weightMode {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.mode
+}
+
+func (m *MemoryGraphDb) findExactEdges(vertex string) (map[string]int64, error) {
+	return m.findLabeledExactEdges([]string{""}, vertex)
+}
+
+// findLabeledExactEdges will return the exact weights of the edges from the
+// vertex with the given labels, summing the weights of the edges to the same
+// vertex across labels
+func (m *MemoryGraphDb) findLabeledExactEdges(labels []string, vertex string) (map[string]int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	f, ok := m.liveVertex(vertex)
+	if !ok {
+		return nil, nil
+	}
+
+	m.purgeEdges(f)
+	weights, err := m.neighborExact(m.selectAdjacency(labels), f)
+	if weights == nil || err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]int64, len(weights))
+	for vertexIndex, weight := range weights {
+		result[m.r_vertices[vertexIndex]] = weight
+	}
+	return result, nil
+}
+
+// neighborExact will return the sum of the exact edge weights from the vertex
+// to each of its neighbors across the edge maps, or nil when there are none
+func (m *MemoryGraphDb) neighborExact(adjs []edgeMap, f int64) (map[int64]int64, error) {
+	var weights map[int64]int64
+	for _, adj := range adjs {
+		vertexEdges, ok := adj[f]
+		if !ok {
+			continue
+		}
+		if weights == nil {
+			weights = make(map[int64]int64, len(vertexEdges))
+		}
+		for vertexIndex, edgeIndex := range vertexEdges {
+			sum, err := addExact(weights[vertexIndex], m.edgeExact(edgeIndex))
+			if err != nil {
+				return nil, err
+			}
+			weights[vertexIndex] = sum
+		}
+	}
+	return weights, nil
+}
+
+func (m *MemoryGraphDb) sumIntersectExactEdges(vertices []string) (map[string]int64, error) {
+	return m.sumIntersectLabeledExactEdges([]string{""}, vertices)
+}
+
+// sumIntersectLabeledExactEdges will return the intersection of the edges
+// with the given labels from all of the vertices along with the exact sum of
+// their weights
+func (m *MemoryGraphDb) sumIntersectLabeledExactEdges(labels []string, vertices []string) (map[string]int64, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	adjs := m.selectAdjacency(labels)
+	var sums map[int64]int64
+	for _, k := range vertices {
+		f, ok := m.liveVertex(k)
+		if !ok {
+			return nil, nil
+		}
+		m.purgeEdges(f)
+		weights, err := m.neighborExact(adjs, f)
+		if weights == nil || err != nil {
+			return nil, err
+		}
+		if sums == nil {
+			sums = weights
+			continue
+		}
+		for t, sum := range sums {
+			weight, ok := weights[t]
+			if !ok {
+				delete(sums, t)
+				continue
+			}
+			if sums[t], err = addExact(sum, weight); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	results := make(map[string]int64, len(sums))
+	for t, sum := range sums {
+		results[m.r_vertices[t]] = sum
+	}
+	return results, nil
+}
+
+// exactEdgeLabels will return the exact weight of the edge between the two
+// vertices for each label it exists with
+func (m *MemoryGraphDb) exactEdgeLabels(from string, to string) map[string]int64 {
+	m.Lock()
+	defer m.Unlock()
+
+	f, f_ok := m.liveVertex(from)
+	t, t_ok := m.liveVertex(to)
+	if !f_ok || !t_ok {
+		return nil
+	}
+
+	m.purgeEdges(f)
+	results := make(map[string]int64)
+	m.eachAdjacency(func(label string, adj edgeMap) {
+		if edgeIndex, ok := adj[f][t]; ok {
+			results[label] = m.edgeExact(edgeIndex)
+		}
+	})
+	return results
+}
+
+// WeightMode will set or return whether the weights of the graph are floats
+// or exact integers
+func (b *BGraphBackend) WeightMode(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) == 0 {
+		client.WriteString(string(db.getWeightMode()))
+		client.Flush()
+		return nil
+	}
+
+	if len(d) > 1 {
+		client.WriteError(errors.New("weights takes at most 1 parameter (weights [float|integer])"))
+		client.Flush()
+		return nil
+	}
+
+	mode, err := parseWeightMode(string(d[0]))
+	if err == nil {
+		err = db.setWeightMode(mode)
+	}
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	client.WriteString("OK")
+	client.Flush()
+	return nil
+}
+
+// parseWeightMode returns the weight mode with the given name
+func parseWeightMode(name string) (weightMode, error) {
+	switch mode := weightMode(strings.ToLower(name)); mode {
+	case weightFloat, weightInteger:
+		return mode, nil
+	}
+	return weightFloat, errors.New("invalid weight mode " + name + " (float or integer)")
+}
@@ -0,0 +1,401 @@
+package bgraph
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWeight(t *testing.T) {
+	tests := []struct {
+		text    string
+		value   float64
+		exact   int64
+		integer bool
+		err     bool
+	}{
+		{"3", 3, 3, true, false},
+		{"-3", -3, -3, true, false},
+		{"9007199254740993", 9007199254740992, 9007199254740993, true, false},
+		{"9223372036854775807", math.MaxInt64, math.MaxInt64, true, false},
+		{"-9223372036854775808", math.MinInt64, math.MinInt64, true, false},
+		{"9223372036854775808", 9223372036854775808, 0, false, false},
+		{"3.0000000000000001", 3, 0, false, false},
+		{"3.0", 3, 0, false, false},
+		{"1e3", 1000, 0, false, false},
+		{"0.5", 0.5, 0, false, false},
+		{"abc", 0, 0, false, true},
+	}
+
+	for _, tt := range tests {
+		w, err := parseWeight(tt.text)
+		if (err != nil) != tt.err {
+			t.Errorf("parseWeight(%q) error = %v, want error %v", tt.text, err, tt.err)
+			continue
+		}
+		if w.value != tt.value || w.integer != tt.integer || (tt.integer && w.exact != tt.exact) {
+			t.Errorf("parseWeight(%q) = %+v, want value %v exact %d integer %v", tt.text, w, tt.value, tt.exact, tt.integer)
+		}
+	}
+}
+
+func TestFloatWeight(t *testing.T) {
+	tests := []struct {
+		value   float64
+		exact   int64
+		integer bool
+	}{
+		{2, 2, true},
+		{-2, -2, true},
+		{0.5, 0, false},
+		{1 << 62, 1 << 62, true},
+		{math.MinInt64, math.MinInt64, true},
+		{math.MaxInt64, 0, false}, // 2^63 is beyond an int64
+		{math.Inf(1), 0, false},
+		{math.NaN(), 0, false},
+	}
+
+	for _, tt := range tests {
+		w := floatWeight(tt.value)
+		if w.integer != tt.integer || (tt.integer && w.exact != tt.exact) {
+			t.Errorf("floatWeight(%v) = %+v, want exact %d integer %v", tt.value, w, tt.exact, tt.integer)
+		}
+	}
+}
+
+func TestExactArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func() (int64, error)
+		want int64
+		err  bool
+	}{
+		{"add", func() (int64, error) { return addExact(1<<53, 1) }, 1<<53 + 1, false},
+		{"add to max", func() (int64, error) { return addExact(math.MaxInt64-1, 1) }, math.MaxInt64, false},
+		{"add overflow", func() (int64, error) { return addExact(math.MaxInt64, 1) }, 0, true},
+		{"add underflow", func() (int64, error) { return addExact(math.MinInt64, -1) }, 0, true},
+		{"add opposite signs", func() (int64, error) { return addExact(math.MaxInt64, math.MinInt64) }, -1, false},
+		{"mul", func() (int64, error) { return mulExact(1<<31, 1<<31) }, 1 << 62, false},
+		{"mul zero", func() (int64, error) { return mulExact(0, math.MinInt64) }, 0, false},
+		{"mul overflow", func() (int64, error) { return mulExact(1<<32, 1<<31) }, 0, true},
+		{"mul negative overflow", func() (int64, error) { return mulExact(-(1 << 32), 1<<32) }, 0, true},
+		{"mul to min by halves", func() (int64, error) { return mulExact(-(1 << 32), 1<<31) }, math.MinInt64, false},
+		{"mul min by minus one", func() (int64, error) { return mulExact(math.MinInt64, -1) }, 0, true},
+		{"mul minus one by min", func() (int64, error) { return mulExact(-1, math.MinInt64) }, 0, true},
+		{"mul to min", func() (int64, error) { return mulExact(-(1 << 62), 2) }, math.MinInt64, false},
+		{"scale exactly", func() (int64, error) { return scaleExact(18014398509481986, 0.5) }, 9007199254740993, false},
+		{"scale rounds half away from zero", func() (int64, error) { return scaleExact(3, 0.5) }, 2, false},
+		{"scale rounds negative half away from zero", func() (int64, error) { return scaleExact(-3, 0.5) }, -2, false},
+		{"scale overflow", func() (int64, error) { return scaleExact(math.MaxInt64, 1.5) }, 0, true},
+		{"scale by infinity", func() (int64, error) { return scaleExact(1, math.Inf(1)) }, 0, true},
+		{"scale zero by infinity", func() (int64, error) { return scaleExact(0, math.Inf(-1)) }, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.fn()
+		if (err != nil) != tt.err {
+			t.Errorf("%s error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyExact(t *testing.T) {
+	tests := []struct {
+		name    string
+		write   weightWrite
+		current int64
+		want    int64
+		err     bool
+	}{
+		{"set", setWeight(floatWeight(5)), 100, 5, false},
+		{"incr", incrWeight(floatWeight(5)), 100, 105, false},
+		{"decr", decrWeight(floatWeight(5)), 100, 95, false},
+		{"fraction", incrWeight(floatWeight(0.5)), 100, 0, true},
+		{"incr overflow", incrWeight(floatWeight(1)), math.MaxInt64, 0, true},
+		{"decr underflow", decrWeight(floatWeight(1)), math.MinInt64, 0, true},
+		{"decr by min", decrWeight(weightArg{exact: math.MinInt64, integer: true}), 0, 0, true},
+		{"incr by min", incrWeight(weightArg{exact: math.MinInt64, integer: true}), 0, math.MinInt64, false},
+	}
+
+	for _, tt := range tests {
+		got, err := tt.write.applyExact(tt.current)
+		if (err != nil) != tt.err {
+			t.Errorf("%s error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("%s = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// integerGraph returns an empty graph holding integer weights
+func integerGraph(t *testing.T) *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	if err := m.setWeightMode(weightInteger); err != nil {
+		t.Fatalf("setWeightMode: %v", err)
+	}
+	return m
+}
+
+// mustWeight returns the parsed weight of a write
+func mustWeight(t *testing.T, text string) weightArg {
+	w, err := parseWeight(text)
+	if err != nil {
+		t.Fatalf("parseWeight(%q): %v", text, err)
+	}
+	return w
+}
+
+func TestIntegerWrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes func(m *MemoryGraphDb, w func(string) weightArg) error
+		want   int64
+		err    bool
+	}{
+		{"above 2^53", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			return m.setEdge("a", "b", w("9007199254740993"))
+		}, 9007199254740993, false},
+		{"increments above 2^53", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("9007199254740992"))
+			m.incrEdge("a", "b", w("1"))
+			return m.incrEdge("a", "b", w("1"))
+		}, 9007199254740994, false},
+		{"up to max", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("9223372036854775806"))
+			return m.incrEdge("a", "b", w("1"))
+		}, math.MaxInt64, false},
+		{"overflow leaves the weight", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("9223372036854775807"))
+			return m.incrEdge("a", "b", w("1"))
+		}, math.MaxInt64, true},
+		{"underflow leaves the weight", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("-9223372036854775808"))
+			return m.decrEdge("a", "b", w("1"))
+		}, math.MinInt64, true},
+		{"fraction close to an integer", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("3"))
+			return m.setEdge("a", "b", w("3.0000000000000001"))
+		}, 3, true},
+		{"beyond an int64", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			m.setEdge("a", "b", w("3"))
+			return m.setEdge("a", "b", w("9223372036854775808"))
+		}, 3, true},
+		{"labeled", func(m *MemoryGraphDb, w func(string) weightArg) error {
+			return m.setLabeledEdge("likes", "a", "b", w("9007199254740993"))
+		}, 9007199254740993, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := integerGraph(t)
+			err := tt.writes(m, func(text string) weightArg { return mustWeight(t, text) })
+			if (err != nil) != tt.err {
+				t.Fatalf("write error = %v, want error %v", err, tt.err)
+			}
+			labels := m.exactEdgeLabels("a", "b")
+			var got int64
+			for _, weight := range labels {
+				got = weight
+			}
+			if len(labels) != 1 || got != tt.want {
+				t.Errorf("exact weight = %v, want %d", labels, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntegerVertexWrites(t *testing.T) {
+	m := integerGraph(t)
+	if err := m.setVertex("a", mustWeight(t, "9223372036854775807")); err != nil {
+		t.Fatalf("setVertex error: %v", err)
+	}
+	if err := m.incrVertex("a", mustWeight(t, "1")); err == nil || err.Error() != "integer weight overflow for the vertex a" {
+		t.Errorf("incrVertex error = %v, want an overflow of the vertex a", err)
+	}
+	if got := m.vertexExact(m.vertices["a"]); got != math.MaxInt64 {
+		t.Errorf("vertexExact = %d, want %d", got, int64(math.MaxInt64))
+	}
+}
+
+func TestIntegerReadOverflow(t *testing.T) {
+	m := integerGraph(t)
+	m.setEdge("a", "c", mustWeight(t, "9223372036854775807"))
+	m.setLabeledEdge("likes", "a", "c", mustWeight(t, "1"))
+	m.setEdge("b", "c", mustWeight(t, "1"))
+
+	if edges, err := m.findExactEdges("a"); err != nil || edges["c"] != math.MaxInt64 {
+		t.Errorf("findExactEdges = %v %v", edges, err)
+	}
+	if _, err := m.findLabeledExactEdges([]string{"", "likes"}, "a"); err != errWeightOverflow {
+		t.Errorf("findLabeledExactEdges across labels error = %v, want %v", err, errWeightOverflow)
+	}
+	if _, err := m.findLabeledEdgesWithProperties([]string{"*"}, "a"); err != errWeightOverflow {
+		t.Errorf("findLabeledEdgesWithProperties across labels error = %v, want %v", err, errWeightOverflow)
+	}
+	if _, err := m.sumIntersectExactEdges([]string{"a", "b"}); err != errWeightOverflow {
+		t.Errorf("sumIntersectExactEdges error = %v, want %v", err, errWeightOverflow)
+	}
+	if sums, err := m.sumIntersectLabeledExactEdges([]string{"likes"}, []string{"a"}); err != nil || sums["c"] != 1 {
+		t.Errorf("sumIntersectLabeledExactEdges = %v %v", sums, err)
+	}
+}
+
+func TestIntegerEdgeDetailJSON(t *testing.T) {
+	m := integerGraph(t)
+	m.setEdge("a", "b", mustWeight(t, "9007199254740993"))
+	m.setEdgeProperties("a", "b", properties{"source": "import"})
+
+	detail, _ := m.getEdgeProperties("a", "b")
+	data, err := json.Marshal(detail)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"weight":9007199254740993,"properties":{"source":"import"}}`; string(data) != want {
+		t.Errorf("edge detail = %s, want %s", data, want)
+	}
+}
+
+func TestIntegerAggregates(t *testing.T) {
+	edges := map[string]int64{"b": 9007199254740993, "c": 9007199254740995, "d": 2}
+	stats := aggregateExactWeights(edges)
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var got map[string]json.Number
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	decoder.Decode(&got)
+	if got["sum"] != "18014398509481990" || got["min"] != "2" || got["max"] != "9007199254740995" || got["count"] != "3" {
+		t.Errorf("exact aggregates = %s", data)
+	}
+	if stats.Mean != 6004799503160663 || stats.P50 != 9007199254740993 || stats.P75 != 9007199254740994 {
+		t.Errorf("mean %v p50 %v p75 %v", stats.Mean, stats.P50, stats.P75)
+	}
+	if want := math.Sqrt(2.0 / 9 * 9007199254740991 * 9007199254740991); math.Abs(stats.StdDev-want) > want*1e-15 {
+		t.Errorf("stddev = %v, want %v", stats.StdDev, want)
+	}
+
+	// weights at the ends of an int64 neither overflow the sum nor the range
+	stats = aggregateExactWeights(map[string]int64{"a": math.MaxInt64, "b": math.MaxInt64, "c": math.MinInt64})
+	if stats.sum.String() != "9223372036854775806" || stats.P50 != math.MaxInt64 {
+		t.Errorf("aggregates at the ends of an int64 = %v %v", stats.sum, stats.P50)
+	}
+
+	probabilities := exactTransitionProbabilities(map[string]int64{"b": 9007199254740993, "c": 9007199254740993, "d": 2})
+	if probabilities["b"] != probabilities["c"] || probabilities["d"] != 2.0/18014398509481988 {
+		t.Errorf("transition probabilities = %v", probabilities)
+	}
+	if exactTransitionProbabilities(map[string]int64{"b": 1, "c": -1}) != nil {
+		t.Errorf("transition probabilities without a positive total should be nil")
+	}
+}
+
+func TestIntegerForestAndSubgraphJSON(t *testing.T) {
+	m := integerGraph(t)
+	for _, e := range [][3]string{{"b", "c", "1152921504606846976"}, {"a", "b", "1152921504606846977"}, {"a", "c", "1152921504606846978"}} {
+		m.setEdge(e[0], e[1], mustWeight(t, e[2]))
+		m.setEdge(e[1], e[0], mustWeight(t, e[2]))
+	}
+	m.setVertex("a", mustWeight(t, "9007199254740993"))
+
+	// the weights differ only beyond 2^53 so their floats would tie
+	data, _ := json.Marshal(m.spanningForest(false))
+	want := `{"edges":[{"from":"b","to":"c","weight":1152921504606846976},{"from":"a","to":"b","weight":1152921504606846977}],"total":2305843009213693953}`
+	if string(data) != want {
+		t.Errorf("spanning forest = %s, want %s", data, want)
+	}
+	data, _ = json.Marshal(m.spanningForest(true))
+	want = `{"edges":[{"from":"a","to":"c","weight":1152921504606846978},{"from":"a","to":"b","weight":1152921504606846977}],"total":2305843009213693955}`
+	if string(data) != want {
+		t.Errorf("maximum spanning forest = %s, want %s", data, want)
+	}
+
+	g := m.inducedSubgraph([]string{"a", "b"})
+	sort.Slice(g.Edges, func(i, j int) bool { return g.Edges[i].From < g.Edges[j].From })
+	data, _ = json.Marshal(g)
+	want = `{"vertices":{"a":9007199254740993,"b":0},"edges":[{"from":"a","to":"b","weight":1152921504606846977},{"from":"b","to":"a","weight":1152921504606846977}]}`
+	if string(data) != want {
+		t.Errorf("subgraph = %s, want %s", data, want)
+	}
+}
+
+func TestIntegerWindowOverflow(t *testing.T) {
+	m := integerGraph(t)
+	m.setWindow(time.Hour, 4)
+	if err := m.incrWindowEdge("a", "b", mustWeight(t, "9007199254740991")); err != nil {
+		t.Fatalf("incrWindowEdge up to 2^53 - 1 error: %v", err)
+	}
+	if err := m.incrWindowEdge("a", "b", mustWeight(t, "1")); err == nil {
+		t.Errorf("incrWindowEdge beyond 2^53 - 1 should overflow")
+	}
+	if err := m.incrWindowEdge("a", "c", mustWeight(t, "0.5")); err == nil {
+		t.Errorf("incrWindowEdge of a fraction should fail")
+	}
+}
+
+func TestIntegerTransforms(t *testing.T) {
+	scale := func(factor string) edgeTransform {
+		transform, _ := scaleTransform([]weightArg{mustWeight(t, factor)})
+		return transform
+	}
+
+	m := integerGraph(t)
+	m.setEdge("a", "b", mustWeight(t, "4611686018427387904"))
+	m.setEdge("a", "c", mustWeight(t, "9007199254740993"))
+
+	if got, want := m.transformEdges(nil, scale("2")), (transformResult{Updated: 1, Rejected: 1}); got != want {
+		t.Errorf("scale 2 = %+v, want %+v", got, want)
+	}
+	edges, _ := m.findExactEdges("a")
+	if edges["b"] != 4611686018427387904 || edges["c"] != 18014398509481986 {
+		t.Errorf("edges after scale 2 = %v", edges)
+	}
+
+	m.transformEdges(nil, scale("0.5"))
+	edges, _ = m.findExactEdges("a")
+	if edges["b"] != 2305843009213693952 || edges["c"] != 9007199254740993 {
+		t.Errorf("edges after scale 0.5 = %v", edges)
+	}
+}
+
+func TestSetWeightMode(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(m *MemoryGraphDb)
+		err   bool
+	}{
+		{"integer weights", func(m *MemoryGraphDb) { m.setEdge("a", "b", floatWeight(3)) }, false},
+		{"fractional edge", func(m *MemoryGraphDb) { m.setEdge("a", "b", floatWeight(0.5)) }, true},
+		{"fractional vertex", func(m *MemoryGraphDb) { m.setVertex("a", floatWeight(0.5)) }, true},
+		{"beyond an int64", func(m *MemoryGraphDb) { m.setEdge("a", "b", floatWeight(1e19)) }, true},
+		{"decay", func(m *MemoryGraphDb) { m.setHalfLife(time.Hour) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.build(m)
+			err := m.setWeightMode(weightInteger)
+			if (err != nil) != tt.err {
+				t.Fatalf("setWeightMode error = %v, want error %v", err, tt.err)
+			}
+			want := weightInteger
+			if tt.err {
+				want = weightFloat
+			}
+			if mode := m.getWeightMode(); mode != want {
+				t.Errorf("mode = %s, want %s", mode, want)
+			}
+		})
+	}
+}
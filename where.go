@@ -0,0 +1,322 @@
+package bgraph
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/nyxtom/broadcast/server"
+)
+
+// whereCommands are the graph commands that can be run through WHERE
+var whereCommands = map[string]bool{
+	"*e": true, "&e": true, "*e@": true, "&e@": true, "*ep": true, "eagg": true, "*en": true,
+	"subgraph": true, "ego": true, "label": true, "like": true,
+}
+
+// predicateOps are the comparison operators of a vertex predicate, two
+// character operators come first so that they are matched before = < >
+var predicateOps = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// vertexPredicate is a single condition on the label or a property of a
+// vertex, a predicate without an operator only requires the key to exist
+type vertexPredicate struct {
+	key   string
+	op    string
+	value string
+}
+
+// parsePredicates will parse a comma separated list of predicates such as
+// label=item,price>=10 which must all hold for a vertex to match
+func parsePredicates(spec string) ([]vertexPredicate, error) {
+	parts := strings.Split(spec, ",")
+	predicates := make([]vertexPredicate, 0, len(parts))
+	for _, part := range parts {
+		p := vertexPredicate{key: part}
+		at := -1
+		for _, op := range predicateOps {
+			if i := strings.Index(part, op); i >= 0 && (at < 0 || i < at) {
+				at = i
+				p = vertexPredicate{part[:i], op, part[i+len(op):]}
+			}
+		}
+		if p.key == "" {
+			return nil, errors.New("invalid predicate " + part + " (expected key, key=value, key>value ...)")
+		}
+		predicates = append(predicates, p)
+	}
+	return predicates, nil
+}
+
+// matches returns true when the property value satisfies the predicate,
+// numbers are compared numerically and everything else as strings
+func (p vertexPredicate) matches(value interface{}, ok bool) bool {
+	if !ok {
+		return p.op == "!="
+	}
+	if p.op == "" {
+		return true
+	}
+
+	cmp := 0
+	number, isNumber := value.(float64)
+	if expected, err := strconv.ParseFloat(p.value, 64); isNumber && err == nil {
+		if number < expected {
+			cmp = -1
+		} else if number > expected {
+			cmp = 1
+		}
+	} else {
+		var s string
+		if isNumber {
+			s = strconv.FormatFloat(number, 'f', -1, 64)
+		} else {
+			s, _ = value.(string)
+		}
+		cmp = strings.Compare(s, p.value)
+	}
+
+	switch p.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// matchVertex returns true when the vertex satisfies every predicate, the
+// key label refers to the label of the vertex rather than a property
+func (m *MemoryGraphDb) matchVertex(f int64, predicates []vertexPredicate) bool {
+	for _, p := range predicates {
+		if !p.matches(m.vertexProperty(f, p.key)) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterVertices will return the set of vertex names that satisfy the predicates
+func (m *MemoryGraphDb) filterVertices(vertices []string, predicates []vertexPredicate) map[string]bool {
+	m.Lock()
+	defer m.Unlock()
+
+	results := make(map[string]bool)
+	for _, name := range vertices {
+		if f, ok := m.vertices[name]; ok && m.matchVertex(f, predicates) {
+			results[name] = true
+		}
+	}
+	return results
+}
+
+// filterView is a graph whose neighbor and traversal queries only return the
+// vertices accepted by matches, such as those satisfying WHERE predicates
+type filterView struct {
+	DB
+	matches func(names []string) map[string]bool
+}
+
+// filterEdges will remove the neighbors that are not accepted
+func (v filterView) filterEdges(edges map[string]float64) map[string]float64 {
+	if edges == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(edges))
+	for name := range edges {
+		names = append(names, name)
+	}
+	matches := v.matches(names)
+	for name := range edges {
+		if !matches[name] {
+			delete(edges, name)
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	return edges
+}
+
+// filterExact will remove the neighbors that are not accepted
+func (v filterView) filterExact(edges map[string]int64, err error) (map[string]int64, error) {
+	if edges == nil || err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(edges))
+	for name := range edges {
+		names = append(names, name)
+	}
+	matches := v.matches(names)
+	for name := range edges {
+		if !matches[name] {
+			delete(edges, name)
+		}
+	}
+	if len(edges) == 0 {
+		return nil, nil
+	}
+	return edges, nil
+}
+
+// filterDetails will remove the neighbors that are not accepted
+func (v filterView) filterDetails(edges map[string]edgeDetail, err error) (map[string]edgeDetail, error) {
+	if edges == nil || err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(edges))
+	for name := range edges {
+		names = append(names, name)
+	}
+	matches := v.matches(names)
+	for name := range edges {
+		if !matches[name] {
+			delete(edges, name)
+		}
+	}
+	if len(edges) == 0 {
+		return nil, nil
+	}
+	return edges, nil
+}
+
+// filterSubgraph will remove the vertices that are not accepted, along with
+// their edges, keeping the vertices in keep regardless
+func (v filterView) filterSubgraph(g *subgraph, keep ...string) *subgraph {
+	if g == nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(g.Vertices))
+	for name := range g.Vertices {
+		names = append(names, name)
+	}
+	matches := v.matches(names)
+	for _, name := range keep {
+		matches[name] = true
+	}
+
+	for name := range g.Vertices {
+		if !matches[name] {
+			delete(g.Vertices, name)
+			delete(g.Labels, name)
+			delete(g.Properties, name)
+		}
+	}
+	edges := g.Edges[:0]
+	for _, e := range g.Edges {
+		if matches[e.From] && matches[e.To] {
+			edges = append(edges, e)
+		}
+	}
+	g.Edges = edges
+	return g
+}
+
+func (v filterView) findEdges(vertex string) map[string]float64 {
+	return v.filterEdges(v.DB.findEdges(vertex))
+}
+
+func (v filterView) sumIntersectEdges(vertices []string) map[string]float64 {
+	return v.filterEdges(v.DB.sumIntersectEdges(vertices))
+}
+
+func (v filterView) findLabeledEdges(labels []string, vertex string) map[string]float64 {
+	return v.filterEdges(v.DB.findLabeledEdges(labels, vertex))
+}
+
+func (v filterView) sumIntersectLabeledEdges(labels []string, vertices []string) map[string]float64 {
+	return v.filterEdges(v.DB.sumIntersectLabeledEdges(labels, vertices))
+}
+
+func (v filterView) findExactEdges(vertex string) (map[string]int64, error) {
+	return v.filterExact(v.DB.findExactEdges(vertex))
+}
+
+func (v filterView) sumIntersectExactEdges(vertices []string) (map[string]int64, error) {
+	return v.filterExact(v.DB.sumIntersectExactEdges(vertices))
+}
+
+func (v filterView) findLabeledExactEdges(labels []string, vertex string) (map[string]int64, error) {
+	return v.filterExact(v.DB.findLabeledExactEdges(labels, vertex))
+}
+
+func (v filterView) sumIntersectLabeledExactEdges(labels []string, vertices []string) (map[string]int64, error) {
+	return v.filterExact(v.DB.sumIntersectLabeledExactEdges(labels, vertices))
+}
+
+func (v filterView) findEdgesAt(vertex string, at timeSpec) (map[string]float64, error) {
+	edges, err := v.DB.findEdgesAt(vertex, at)
+	return v.filterEdges(edges), err
+}
+
+func (v filterView) sumIntersectEdgesAt(vertices []string, at timeSpec) (map[string]float64, error) {
+	edges, err := v.DB.sumIntersectEdgesAt(vertices, at)
+	return v.filterEdges(edges), err
+}
+
+func (v filterView) findLabeledEdgesAt(labels []string, vertex string, at timeSpec) (map[string]float64, error) {
+	edges, err := v.DB.findLabeledEdgesAt(labels, vertex, at)
+	return v.filterEdges(edges), err
+}
+
+func (v filterView) sumIntersectLabeledEdgesAt(labels []string, vertices []string, at timeSpec) (map[string]float64, error) {
+	edges, err := v.DB.sumIntersectLabeledEdgesAt(labels, vertices, at)
+	return v.filterEdges(edges), err
+}
+
+func (v filterView) findEdgesWithProperties(vertex string) (map[string]edgeDetail, error) {
+	return v.filterDetails(v.DB.findEdgesWithProperties(vertex))
+}
+
+func (v filterView) findLabeledEdgesWithProperties(labels []string, vertex string) (map[string]edgeDetail, error) {
+	return v.filterDetails(v.DB.findLabeledEdgesWithProperties(labels, vertex))
+}
+
+func (v filterView) inducedSubgraph(vertices []string) *subgraph {
+	return v.filterSubgraph(v.DB.inducedSubgraph(vertices))
+}
+
+func (v filterView) egoSubgraph(vertex string, radius int) *subgraph {
+	return v.filterSubgraph(v.DB.egoSubgraph(vertex, radius), vertex)
+}
+
+// Where will run a neighbor or traversal query returning only the vertices
+// whose label and properties satisfy the predicates
+func (b *BGraphBackend) Where(db DB, d [][]byte, client server.ProtocolClient) error {
+	if len(d) < 2 {
+		client.WriteError(errors.New("where takes at least 2 parameters (where predicate[,predicate ...] command [arg ...])"))
+		client.Flush()
+		return nil
+	}
+
+	cmd := strings.ToLower(string(d[1]))
+	if !whereCommands[cmd] {
+		client.WriteError(errors.New("where does not support the command " + cmd))
+		client.Flush()
+		return nil
+	}
+
+	predicates, err := parsePredicates(string(d[0]))
+	if err != nil {
+		client.WriteError(err)
+		client.Flush()
+		return nil
+	}
+
+	matches := func(names []string) map[string]bool {
+		return db.filterVertices(names, predicates)
+	}
+	return b.dispatch(filterView{db, matches}, d[1:], client)
+}
@@ -0,0 +1,160 @@
+package bgraph
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParsePredicates(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []vertexPredicate
+		err  bool
+	}{
+		{"price", []vertexPredicate{{"price", "", ""}}, false},
+		{"label=item", []vertexPredicate{{"label", "=", "item"}}, false},
+		{"price>=10,price<20", []vertexPredicate{{"price", ">=", "10"}, {"price", "<", "20"}}, false},
+		{"name!=a=b", []vertexPredicate{{"name", "!=", "a=b"}}, false},
+		{"a<=b>c", []vertexPredicate{{"a", "<=", "b>c"}}, false},
+		{"price>", []vertexPredicate{{"price", ">", ""}}, false},
+		{"=item", nil, true},
+		{"price,", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePredicates(tt.spec)
+		if (err != nil) != tt.err {
+			t.Errorf("parsePredicates(%q) error = %v, want error %v", tt.spec, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePredicates(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestPredicateMatches(t *testing.T) {
+	tests := []struct {
+		predicate vertexPredicate
+		value     interface{}
+		ok        bool
+		want      bool
+	}{
+		{vertexPredicate{"price", "", ""}, float64(1), true, true},
+		{vertexPredicate{"price", "", ""}, nil, false, false},
+		{vertexPredicate{"price", "!=", "5"}, nil, false, true},
+		{vertexPredicate{"price", "=", "5"}, float64(5), true, true},
+		{vertexPredicate{"price", "=", "5.0"}, float64(5), true, true},
+		{vertexPredicate{"price", ">", "9"}, float64(10), true, true},
+		{vertexPredicate{"price", "<", "9"}, float64(10), true, false},
+		{vertexPredicate{"price", ">=", "10"}, float64(10), true, true},
+		{vertexPredicate{"price", "<=", "10"}, float64(10.5), true, false},
+		{vertexPredicate{"price", "!=", "10"}, float64(10), true, false},
+		{vertexPredicate{"name", "=", "lamp"}, "lamp", true, true},
+		{vertexPredicate{"name", "<", "m"}, "lamp", true, true},
+		{vertexPredicate{"name", ">", "9"}, "lamp", true, true},
+		{vertexPredicate{"price", "=", "cheap"}, float64(5), true, false},
+		{vertexPredicate{"price", "<", "a"}, float64(5), true, true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.predicate.matches(tt.value, tt.ok); got != tt.want {
+			t.Errorf("%+v matches(%v, %v) = %v, want %v", tt.predicate, tt.value, tt.ok, got, tt.want)
+		}
+	}
+}
+
+// whereGraph returns a user with edges to items and other vertices with labels and properties
+func whereGraph() *MemoryGraphDb {
+	m, _ := NewMemoryGraphDb()
+	m.setEdge("u", "i1", floatWeight(1))
+	m.setEdge("u", "i2", floatWeight(2))
+	m.setEdge("u", "x", floatWeight(3))
+	m.setVertexLabel("item", "i1")
+	m.setVertexLabel("item", "i2")
+	m.setVertexProperties("i1", properties{"price": float64(5), "name": "lamp"})
+	m.setVertexProperties("i2", properties{"price": float64(20)})
+	return m
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		spec  string
+		edges map[string]float64
+		ego   []string
+	}{
+		{"label=item", map[string]float64{"i1": 1, "i2": 2}, []string{"i1", "i2", "u"}},
+		{"label=item,price>=10", map[string]float64{"i2": 2}, []string{"i2", "u"}},
+		{"name", map[string]float64{"i1": 1}, []string{"i1", "u"}},
+		{"price!=5", map[string]float64{"i2": 2, "x": 3}, []string{"i2", "u", "x"}},
+		{"label=user", nil, []string{"u"}},
+	}
+
+	for _, tt := range tests {
+		predicates, err := parsePredicates(tt.spec)
+		if err != nil {
+			t.Fatalf("parsePredicates(%q): %v", tt.spec, err)
+		}
+		m := whereGraph()
+		v := filterView{m, func(names []string) map[string]bool { return m.filterVertices(names, predicates) }}
+
+		if got := v.findEdges("u"); !reflect.DeepEqual(got, tt.edges) {
+			t.Errorf("where %s findEdges = %v, want %v", tt.spec, got, tt.edges)
+		}
+		ego := v.egoSubgraph("u", 1)
+		names := make([]string, 0, len(ego.Vertices))
+		for name := range ego.Vertices {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.ego) || len(ego.Edges) != len(tt.ego)-1 {
+			t.Errorf("where %s ego = %v with edges %v, want %v", tt.spec, names, ego.Edges, tt.ego)
+		}
+	}
+}
+
+func TestVertexProperties(t *testing.T) {
+	m := whereGraph()
+	m.setVertexProperties("i1", properties{"price": float64(6), "color": "red"})
+	m.delVertexProperties("i1", []string{"name", "missing"})
+	label, props, ok := m.getVertexProperties("i1")
+	if !ok || label != "item" || !reflect.DeepEqual(props, properties{"price": float64(6), "color": "red"}) {
+		t.Errorf("getVertexProperties = %v %v %v", label, props, ok)
+	}
+
+	// the copy returned cannot change the vertex
+	props["price"] = float64(100)
+	if _, again, _ := m.getVertexProperties("i1"); again["price"] != float64(6) {
+		t.Errorf("changing the returned properties changed the vertex to %v", again)
+	}
+
+	m.setVertexLabel("", "i1")
+	m.delVertexProperties("i1", []string{"price", "color"})
+	if label, props, ok := m.getVertexProperties("i1"); !ok || label != "" || len(props) != 0 {
+		t.Errorf("getVertexProperties after removal = %v %v %v", label, props, ok)
+	}
+	if _, _, ok := m.getVertexProperties("missing"); ok {
+		t.Errorf("getVertexProperties of a missing vertex should not exist")
+	}
+}
+
+func TestParsePropertyValue(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{"5", float64(5)},
+		{"-1.5", float64(-1.5)},
+		{"lamp", "lamp"},
+		{"NaN", "NaN"},
+		{"inf", "inf"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := parsePropertyValue(tt.text); got != tt.want {
+			t.Errorf("parsePropertyValue(%q) = %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
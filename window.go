@@ -0,0 +1,167 @@
+package bgraph
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWindowSpan    = 10 * time.Minute
+	defaultWindowBuckets = 60
+
+	// maxWindowBuckets bounds the memory of each windowed edge to 28.8 KB
+	maxWindowBuckets = 3600
+)
+
+// windowCounter is a ring of fixed size buckets summing the increments of an
+// edge within the sliding window, each edge uses exactly len(buckets) floats
+type windowCounter struct {
+	buckets []float64
+	head    int64 // absolute number of the newest bucket written
+}
+
+func newWindowCounter(size int) *windowCounter {
+	return &windowCounter{buckets: make([]float64, size)}
+}
+
+// add will increment the bucket for the absolute bucket number, clearing the
+// buckets that fell out of the window since the last increment
+func (w *windowCounter) add(bucket int64, weight float64) {
+	size := int64(len(w.buckets))
+	if bucket > w.head {
+		if bucket-w.head >= size {
+			for i := range w.buckets {
+				w.buckets[i] = 0
+			}
+		} else {
+			for b := w.head + 1; b <= bucket; b++ {
+				w.buckets[b%size] = 0
+			}
+		}
+		w.head = bucket
+	} else if w.head-bucket >= size {
+		return
+	}
+	w.buckets[bucket%size] += weight
+}
+
+// sum will return the total of the buckets within the window ending at bucket
+func (w *windowCounter) sum(bucket int64) float64 {
+	size := int64(len(w.buckets))
+	total := float64(0)
+	for k := int64(0); k < size; k++ {
+		b := w.head - k
+		if b >= 0 && b > bucket-size && b <= bucket {
+			total += w.buckets[b%size]
+		}
+	}
+	return total
+}
+
+// windowBucket returns the absolute bucket number for the given time
+func (m *MemoryGraphDb) windowBucket(now int64) int64 {
+	width := int64(m.windowSpan) / int64(m.windowBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	return now / width
+}
+
+// incrWindowEdge will increment the sliding window counter of the edge, the
+// weight of the edge is then the sum of the increments within the window.
+// The negative weight policy and pruning apply to that sum as they do to
+// other writes, a clamped sum is reached by adding less than the increment.
+func (m *MemoryGraphDb) incrWindowEdge(from string, to string, weight weightArg) error {
+	m.Lock()
+	defer m.Unlock()
+
+	bucket := m.windowBucket(time.Now().UnixNano())
+	current := float64(0)
+	edgeIndex, exists := m.existingEdge("", from, to)
+	if exists {
+		if counter, ok := m.edgeWindows[edgeIndex]; ok {
+			current = counter.sum(bucket)
+		}
+	}
+
+	total, _, remove, err := m.resolveWrite(negativeDefault, incrWeight(weight), current, int64(current))
+	if err == nil {
+		err = m.windowRange(weight, total)
+	}
+	if err != nil {
+		return errors.New(err.Error() + " for the edge " + from + " " + to)
+	}
+	if remove || (m.negligible(total) && (!exists || m.negligibleEdge(edgeIndex, total))) {
+		if exists {
+			m.removeEdge("", m.vertices[from], m.vertices[to])
+		}
+		return nil
+	}
+
+	ef_t := m.getEdgeIndex("", from, to)
+	counter, ok := m.edgeWindows[ef_t]
+	if !ok {
+		counter = newWindowCounter(m.windowBuckets)
+		m.edgeWindows[ef_t] = counter
+	}
+
+	increment := weight.value
+	if total != current+increment {
+		increment = total - current
+	}
+	counter.add(bucket, increment)
+	m.edgeWeights[ef_t] = counter.sum(bucket)
+	delete(m.edgeInts, ef_t)
+	m.touchEdge(ef_t)
+	return nil
+}
+
+// windowRange returns an error when the graph holds integer weights and the
+// increment or the sum of the counter is out of range, as the buckets of a
+// counter are floats they only hold integers up to maxWindowWeight
+func (m *MemoryGraphDb) windowRange(weight weightArg, total float64) error {
+	if m.mode != weightInteger {
+		return nil
+	}
+	if weight.exact > maxWindowWeight || weight.exact < -maxWindowWeight || total > maxWindowWeight || total < -maxWindowWeight {
+		return errWeightOverflow
+	}
+	return nil
+}
+
+// setWindow will change the span and number of buckets of the sliding
+// windows. Existing counters keep their current total in their newest bucket.
+func (m *MemoryGraphDb) setWindow(span time.Duration, buckets int) error {
+	if span <= 0 || buckets < 1 || buckets > maxWindowBuckets {
+		return errors.New("window seconds must be positive and buckets between 1 and " + strconv.Itoa(maxWindowBuckets))
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	bucket := m.windowBucket(time.Now().UnixNano())
+	totals := make(map[int64]float64, len(m.edgeWindows))
+	for edgeIndex, counter := range m.edgeWindows {
+		totals[edgeIndex] = counter.sum(bucket)
+	}
+
+	m.windowSpan = span
+	m.windowBuckets = buckets
+	bucket = m.windowBucket(time.Now().UnixNano())
+	for edgeIndex, total := range totals {
+		counter := newWindowCounter(buckets)
+		counter.head = bucket
+		counter.add(bucket, total)
+		m.edgeWindows[edgeIndex] = counter
+	}
+	return nil
+}
+
+// getWindow returns the span and number of buckets of the sliding windows
+func (m *MemoryGraphDb) getWindow() (time.Duration, int) {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.windowSpan, m.windowBuckets
+}
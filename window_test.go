@@ -0,0 +1,149 @@
+package bgraph
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowCounter(t *testing.T) {
+	type increment struct {
+		bucket int64
+		weight float64
+	}
+	tests := []struct {
+		name   string
+		size   int
+		adds   []increment
+		bucket int64
+		sum    float64
+	}{
+		{"empty", 4, nil, 0, 0},
+		{"single bucket", 4, []increment{{0, 1}, {0, 2}}, 0, 3},
+		{"within the window", 4, []increment{{0, 1}, {1, 2}, {3, 4}}, 3, 7},
+		{"oldest bucket slides out", 4, []increment{{0, 1}, {1, 2}, {3, 4}}, 4, 6},
+		{"whole window slides out", 4, []increment{{0, 1}, {1, 2}}, 9, 0},
+		{"bucket reused after wrapping", 4, []increment{{1, 5}, {5, 1}}, 5, 1},
+		{"jump clears every bucket", 4, []increment{{0, 1}, {1, 2}, {2, 3}, {20, 4}}, 20, 4},
+		{"late increment within the window", 4, []increment{{3, 1}, {1, 2}}, 3, 3},
+		{"late increment outside the window", 4, []increment{{5, 1}, {1, 2}}, 5, 1},
+		{"negative increments", 4, []increment{{0, 5}, {1, -2}}, 1, 3},
+		{"sum before the newest bucket", 4, []increment{{0, 1}, {2, 2}}, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWindowCounter(tt.size)
+			for _, add := range tt.adds {
+				w.add(add.bucket, add.weight)
+			}
+			if len(w.buckets) != tt.size {
+				t.Errorf("counter holds %d buckets, want %d", len(w.buckets), tt.size)
+			}
+			if got := w.sum(tt.bucket); got != tt.sum {
+				t.Errorf("sum(%d) = %v, want %v", tt.bucket, got, tt.sum)
+			}
+		})
+	}
+}
+
+func TestIncrWindowEdge(t *testing.T) {
+	m, _ := NewMemoryGraphDb()
+	m.setWindow(time.Hour, 4)
+	m.incrWindowEdge("a", "b", floatWeight(2))
+	m.incrWindowEdge("a", "b", floatWeight(3))
+	if got := m.findEdges("a")["b"]; got != 5 {
+		t.Errorf("windowed weight = %v, want 5", got)
+	}
+	if info := m.info(); info.WindowedEdges != 1 {
+		t.Errorf("windowed edges = %d, want 1", info.WindowedEdges)
+	}
+
+	// setting the weight replaces the counter with a plain weight
+	m.setEdge("a", "b", floatWeight(1))
+	if got := m.findEdges("a")["b"]; got != 1 {
+		t.Errorf("weight after set = %v, want 1", got)
+	}
+	if info := m.info(); info.WindowedEdges != 0 {
+		t.Errorf("windowed edges after set = %d, want 0", info.WindowedEdges)
+	}
+}
+
+func TestSetWindow(t *testing.T) {
+	tests := []struct {
+		span    time.Duration
+		buckets int
+		err     bool
+	}{
+		{time.Minute, 1, false},
+		{time.Minute, maxWindowBuckets, false},
+		{time.Minute, maxWindowBuckets + 1, true},
+		{time.Minute, 1000000000, true},
+		{time.Minute, 0, true},
+		{0, 10, true},
+		{-time.Minute, 10, true},
+	}
+
+	for _, tt := range tests {
+		m, _ := NewMemoryGraphDb()
+		m.incrWindowEdge("a", "b", floatWeight(3))
+		err := m.setWindow(tt.span, tt.buckets)
+		if (err != nil) != tt.err {
+			t.Errorf("setWindow(%v, %d) error = %v, want error %v", tt.span, tt.buckets, err, tt.err)
+			continue
+		}
+
+		span, buckets := m.getWindow()
+		counter := m.edgeWindows[m.edgeIndexOf("a", "b")]
+		if tt.err && (span != defaultWindowSpan || buckets != defaultWindowBuckets || len(counter.buckets) != defaultWindowBuckets) {
+			t.Errorf("rejected setWindow(%v, %d) changed the window to %v %d", tt.span, tt.buckets, span, buckets)
+		}
+		if !tt.err && len(counter.buckets) != tt.buckets {
+			t.Errorf("setWindow(%v, %d) left counters with %d buckets", tt.span, tt.buckets, len(counter.buckets))
+		}
+		if got := m.findEdges("a")["b"]; got != 3 {
+			t.Errorf("windowed weight after setWindow(%v, %d) = %v, want 3", tt.span, tt.buckets, got)
+		}
+	}
+}
+
+func TestIncrWindowEdgePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(m *MemoryGraphDb)
+		weights []float64
+		want    float64
+		exists  bool
+		err     bool
+	}{
+		{"allow", func(m *MemoryGraphDb) {}, []float64{2, -5}, -3, true, false},
+		{"clamp", func(m *MemoryGraphDb) { m.setNegative(negativeClamp) }, []float64{2, -5}, 0, true, false},
+		{"clamp then increment", func(m *MemoryGraphDb) { m.setNegative(negativeClamp) }, []float64{2, -5, 1}, 1, true, false},
+		{"reject", func(m *MemoryGraphDb) { m.setNegative(negativeReject) }, []float64{2, -5}, 2, true, true},
+		{"delete", func(m *MemoryGraphDb) { m.setNegative(negativeDelete) }, []float64{2, -2}, 0, false, false},
+		{"pruned at epsilon", func(m *MemoryGraphDb) { m.setEpsilon(true, 0.5) }, []float64{2, -1.5}, 0, false, false},
+		{"never created at epsilon", func(m *MemoryGraphDb) { m.setEpsilon(true, 0.5) }, []float64{0.25}, 0, false, false},
+		{"kept above epsilon", func(m *MemoryGraphDb) { m.setEpsilon(true, 0.5) }, []float64{2, -1}, 1, true, false},
+		{"kept with properties", func(m *MemoryGraphDb) {
+			m.setEpsilon(true, 0.5)
+			m.setEdgeProperties("a", "b", properties{"source": "import"})
+		}, []float64{2, -2}, 0, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := NewMemoryGraphDb()
+			tt.setup(m)
+			var err error
+			for _, weight := range tt.weights {
+				err = m.incrWindowEdge("a", "b", floatWeight(weight))
+			}
+			if (err != nil) != tt.err {
+				t.Fatalf("incrWindowEdge error = %v, want error %v", err, tt.err)
+			}
+			weight, exists := m.findEdges("a")["b"]
+			if exists != tt.exists || weight != tt.want {
+				t.Errorf("windowed edge = %v %v, want %v %v", weight, exists, tt.want, tt.exists)
+			}
+		})
+	}
+}